@@ -0,0 +1,3246 @@
+package map1_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"unsafe"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+)
+
+// TestPrecedence checks the §6.2 ranking is exposed consistently.
+func TestPrecedence(t *testing.T) {
+	hdr, ok := map1.Precedence(map1.ErrCanonHdr)
+	if !ok || hdr != 0 {
+		t.Errorf("ERR_CANON_HDR: got rank=%d ok=%v, want 0 true", hdr, ok)
+	}
+	size, _ := map1.Precedence(map1.ErrLimitSize)
+	if size <= hdr {
+		t.Errorf("ERR_LIMIT_SIZE should rank after ERR_CANON_HDR")
+	}
+	if _, ok := map1.Precedence("ERR_NOPE"); ok {
+		t.Error("unknown code should not have a rank")
+	}
+
+	e := &map1.MapError{Code: map1.ErrUTF8}
+	want, _ := map1.Precedence(map1.ErrUTF8)
+	if e.Precedence() != want {
+		t.Errorf("method rank %d != function rank %d", e.Precedence(), want)
+	}
+	unknown := &map1.MapError{Code: "ERR_NOPE"}
+	if unknown.Precedence() <= size {
+		t.Error("unknown code should rank after every spec code")
+	}
+}
+
+// TestErrorPrecedence pairs up overlapping violations and checks every
+// entry point reports the §6.2 winner, whichever one it meets first.
+func TestErrorPrecedence(t *testing.T) {
+	u32 := func(n int) []byte { return []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)} }
+	str := func(s string) []byte { return append(append([]byte{0x01}, u32(len(s))...), s...) }
+	list := func(n int, items ...[]byte) []byte {
+		return append(append([]byte{0x03}, u32(n)...), bytes.Join(items, nil)...)
+	}
+	mp := func(n int, kvs ...[]byte) []byte {
+		return append(append([]byte{0x04}, u32(n)...), bytes.Join(kvs, nil)...)
+	}
+	canon := func(body ...[]byte) []byte { return append([]byte("MAP1\x00"), bytes.Join(body, nil)...) }
+	tru := []byte{0x05, 0x01}
+	deep := bytes.Repeat([]byte{0x03, 0, 0, 0, 1}, map1.MaxDepth+1)
+	bad := "\xc0\xaf"
+
+	canonCases := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"hdr beats mcf", append([]byte("MAP0\x00"), 0x99), map1.ErrCanonHdr},
+		{"hdr beats size", append([]byte("XXXX\x00"), make([]byte, map1.MaxCanonBytes)...), map1.ErrCanonHdr},
+		{"mcf beats utf8", canon(list(2, str(bad))), map1.ErrCanonMCF},
+		{"mcf beats dup", canon(mp(3, str("a"), tru, str("a"), tru)), map1.ErrCanonMCF},
+		{"mcf trailing beats key order", canon(mp(2, str("b"), tru, str("a"), tru), []byte{0}), map1.ErrCanonMCF},
+		{"schema beats utf8", canon(mp(2, str("a"), str(bad), tru, tru)), map1.ErrSchema},
+		{"utf8 beats key order", canon(mp(2, str("b"), tru, str("a"), str(bad))), map1.ErrUTF8},
+		{"dup beats key order", canon(mp(3, str("b"), tru, str("a"), tru, str("a"), tru)), map1.ErrDupKey},
+		{"utf8 beats depth", canon(list(2, str(bad), deep)), map1.ErrUTF8},
+		{"key order beats size", canon(mp(2, str("b"), tru, str("a"), list(map1.MaxListEntries+1))), map1.ErrKeyOrder},
+	}
+	for _, tc := range canonCases {
+		for name, fn := range map[string]func([]byte) (string, error){
+			"decode": map1.MIDFromCanonBytes,
+			"scan":   map1.MIDFromCanonBytesFast,
+		} {
+			if _, err := fn(tc.in); err == nil || err.(*map1.MapError).Code != tc.want {
+				t.Errorf("canon %s (%s): got %v, want %s", tc.name, name, err, tc.want)
+			}
+		}
+	}
+
+	deepJSON := strings.Repeat("[", map1.MaxDepth+1) + strings.Repeat("]", map1.MaxDepth+1)
+	jsonCases := []struct {
+		name string
+		in   string
+		ptrs []string // nil: FULL
+		want string
+	}{
+		{"mcf beats type", `{"a":1.5,`, nil, map1.ErrCanonMCF},
+		{"mcf beats schema", "\xef\xbb\xbf{\"a\":", nil, map1.ErrCanonMCF},
+		{"schema beats type", "\xef\xbb\xbf{\"a\":null}", nil, map1.ErrSchema},
+		{"type beats utf8", `{"a":"\uD800","b":null}`, nil, map1.ErrType},
+		{"type beats dup", `{"a":1,"a":2.5}`, nil, map1.ErrType},
+		{"utf8 beats dup", `{"a":1,"a":"\uD800"}`, nil, map1.ErrUTF8},
+		{"dup beats depth", `{"a":1,"a":2,"b":` + deepJSON + `}`, nil, map1.ErrDupKey},
+		{"bind schema beats type", `{"a":1,"b":1.5}`, []string{"/a", "/zz"}, map1.ErrSchema},
+		{"bind schema beats dup", `{"a":1,"a":2}`, []string{"/a", "/zz"}, map1.ErrSchema},
+	}
+	for _, tc := range jsonCases {
+		var err error
+		if tc.ptrs == nil {
+			_, err = map1.MIDFullJSON([]byte(tc.in))
+		} else {
+			_, err = map1.MIDBindJSON([]byte(tc.in), tc.ptrs)
+		}
+		if err == nil || err.(*map1.MapError).Code != tc.want {
+			t.Errorf("json %s: got %v, want %s", tc.name, err, tc.want)
+		}
+	}
+
+	var deepVal map1.Value = map1.List{}
+	for i := 0; i < map1.MaxDepth; i++ {
+		deepVal = map1.List{deepVal}
+	}
+	valueCases := []struct {
+		name string
+		in   map1.Value
+		want string
+	}{
+		{"schema beats utf8", map1.List{map1.String(bad), nil}, map1.ErrSchema},
+		{"utf8 beats dup", &map1.Map{Keys: []string{"a", "a"}, Values: []map1.Value{map1.Bool(true), map1.String(bad)}}, map1.ErrUTF8},
+		{"dup under a wrong Sorted mark", &map1.Map{Keys: []string{"b", "a", "a"}, Values: []map1.Value{map1.Bool(true), map1.Bool(true), map1.Bool(true)}, Sorted: true}, map1.ErrDupKey},
+		{"utf8 beats depth", map1.List{deepVal, map1.String(bad)}, map1.ErrUTF8},
+	}
+	for _, tc := range valueCases {
+		if _, err := map1.MIDFull(tc.in); err == nil || err.(*map1.MapError).Code != tc.want {
+			t.Errorf("value %s: got %v, want %s", tc.name, err, tc.want)
+		}
+	}
+}
+
+// TestValidateAll checks every violation is reported with its path.
+func TestValidateAll(t *testing.T) {
+	if me := map1.ValidateAll(map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)})); me != nil {
+		t.Fatalf("valid input reported: %v", me)
+	}
+	var empty map1.MultiError
+	if empty.Code() != "" || empty.Error() != "no violations" || map1.ChooseReportedError(nil) != "" {
+		t.Errorf("empty MultiError: code %q, error %q", empty.Code(), empty.Error())
+	}
+
+	v := map1.NewMap(
+		map1.MapEntry{Key: "b", Value: map1.String("\xff")},
+		map1.MapEntry{Key: "a", Value: map1.Integer(1)},
+		map1.MapEntry{Key: "a", Value: map1.Integer(2)},
+		map1.MapEntry{Key: "c/d", Value: map1.List{map1.String("ok"), map1.String("\xfe")}},
+	)
+	me := map1.ValidateAll(v)
+	if me == nil {
+		t.Fatal("expected violations")
+	}
+	got := map[string]string{}
+	for _, e := range me.Errors() {
+		got[e.Path] = e.Code
+	}
+	want := map[string]string{
+		"/a":      map1.ErrDupKey,
+		"/b":      map1.ErrUTF8,
+		"/c~1d/1": map1.ErrUTF8,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for p, c := range want {
+		if got[p] != c {
+			t.Errorf("%s: got %q, want %q", p, got[p], c)
+		}
+	}
+	if me.Code() != map1.ErrUTF8 {
+		t.Errorf("reported code: got %s, want %s", me.Code(), map1.ErrUTF8)
+	}
+}
+
+// TestCanonBytesAndMID checks the combined call matches the separate ones.
+func TestCanonBytesAndMID(t *testing.T) {
+	m := map1.NewMap(
+		map1.MapEntry{Key: "b", Value: map1.NewMap(map1.MapEntry{Key: "x", Value: map1.Integer(7)})},
+		map1.MapEntry{Key: "a", Value: map1.String("1")},
+	)
+	canon, mid, err := map1.CanonBytesAndMIDFull(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCanon, _ := map1.CanonBytesFull(m)
+	wantMID, _ := map1.MIDFull(m)
+	if string(canon) != string(wantCanon) || mid != wantMID {
+		t.Errorf("full: got %s, want %s", mid, wantMID)
+	}
+
+	ptrs := []string{"/b/x"}
+	canon, mid, err = map1.CanonBytesAndMIDBind(m, ptrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCanon, _ = map1.CanonBytesBind(m, ptrs)
+	wantMID, _ = map1.MIDBind(m, ptrs)
+	if string(canon) != string(wantCanon) || mid != wantMID {
+		t.Errorf("bind: got %s, want %s", mid, wantMID)
+	}
+}
+
+// TestMIDEach checks per-element and per-value MIDs match standalone roots.
+func TestMIDEach(t *testing.T) {
+	a := map1.NewMap(map1.MapEntry{Key: "id", Value: map1.Integer(1)})
+	b := map1.String("row")
+	wantA, _ := map1.MIDFull(a)
+	wantB, _ := map1.MIDFull(b)
+
+	mids, err := map1.MIDEachListElement(map1.List{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mids) != 2 || mids[0] != wantA || mids[1] != wantB {
+		t.Errorf("list: got %v", mids)
+	}
+	if _, err := map1.MIDEachListElement(a); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("list: expected ERR_SCHEMA for MAP root, got %v", err)
+	}
+
+	byKey, err := map1.MIDEachMapValue(map1.NewMap(
+		map1.MapEntry{Key: "a", Value: a},
+		map1.MapEntry{Key: "b", Value: b},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byKey["a"] != wantA || byKey["b"] != wantB {
+		t.Errorf("map: got %v", byKey)
+	}
+	if _, err := map1.MIDEachMapValue(map1.List{}); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("map: expected ERR_SCHEMA for LIST root, got %v", err)
+	}
+}
+
+// TestMCFBytesRoundtrip checks the headerless encode/decode pair.
+func TestMCFBytesRoundtrip(t *testing.T) {
+	m := map1.NewMap(
+		map1.MapEntry{Key: "b", Value: map1.List{map1.Bool(true), map1.Bytes{0x00}}},
+		map1.MapEntry{Key: "a", Value: map1.Integer(-1)},
+	)
+	body, err := map1.MCFBytes(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canon, _ := map1.CanonBytesFull(m)
+	if string(canon[5:]) != string(body) {
+		t.Fatal("MCF body differs from CANON_BYTES minus header")
+	}
+
+	v, n, err := map1.DecodeMCF(append(body, 0xAA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(body) {
+		t.Errorf("consumed %d, want %d", n, len(body))
+	}
+	again, _ := map1.MCFBytes(v)
+	if string(again) != string(body) {
+		t.Error("re-encoded MCF differs")
+	}
+}
+
+// TestDecodeMCFStream checks concatenated values and truncation.
+func TestDecodeMCFStream(t *testing.T) {
+	a, _ := map1.MCFBytes(map1.String("x"))
+	b, _ := map1.MCFBytes(map1.Integer(5))
+	stream := append(append([]byte{}, a...), b...)
+
+	vals, err := map1.DecodeMCFStream(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 2 || vals[0] != map1.String("x") || vals[1] != map1.Integer(5) {
+		t.Errorf("got %v", vals)
+	}
+
+	_, err = map1.DecodeMCFStream(stream[:len(stream)-1])
+	if err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("truncated: expected ERR_CANON_MCF, got %v", err)
+	}
+	_, err = map1.DecodeMCFStream(append(stream, 0xFF))
+	if err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("garbage: expected ERR_CANON_MCF, got %v", err)
+	}
+}
+
+// TestSkipUnknownTags checks the opt-in extension skipping.
+func TestSkipUnknownTags(t *testing.T) {
+	// LIST[ INTEGER 1, ext(0x40, "zz"), STRING "a" ]
+	buf := []byte{0x03, 0, 0, 0, 3,
+		0x06, 0, 0, 0, 0, 0, 0, 0, 1,
+		0x40, 0, 0, 0, 2, 'z', 'z',
+		0x01, 0, 0, 0, 1, 'a'}
+
+	if _, _, err := map1.DecodeMCF(buf); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("strict: expected ERR_CANON_MCF, got %v", err)
+	}
+
+	v, n, err := map1.DecodeMCFWithOptions(buf, map1.DecodeOptions{SkipUnknownTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(buf) {
+		t.Errorf("consumed %d, want %d", n, len(buf))
+	}
+	l, ok := v.(map1.List)
+	if !ok || len(l) != 2 || l[0] != map1.Integer(1) || l[1] != map1.String("a") {
+		t.Errorf("got %v", v)
+	}
+
+	// Tags below the extension range are never skipped.
+	low := []byte{0x03, 0, 0, 0, 1, 0x07, 0, 0, 0, 0}
+	if _, _, err := map1.DecodeMCFWithOptions(low, map1.DecodeOptions{SkipUnknownTags: true}); err == nil {
+		t.Error("tag 0x07 should still be rejected")
+	}
+}
+
+// TestCanonVersion checks header version parsing.
+func TestCanonVersion(t *testing.T) {
+	canon, _ := map1.CanonBytesFull(map1.EmptyMap())
+	if v, err := map1.CanonVersion(canon); err != nil || v != 1 {
+		t.Errorf("got %d, %v; want 1", v, err)
+	}
+	if v, err := map1.CanonVersion([]byte("MAP2\x00")); err != nil || v != 2 {
+		t.Errorf("MAP2: got %d, %v; want 2", v, err)
+	}
+	for _, bad := range []string{"", "MAP1", "MAPX\x00", "MAP1\x01", "MAQ1\x00"} {
+		if _, err := map1.CanonVersion([]byte(bad)); err == nil || err.(*map1.MapError).Code != map1.ErrCanonHdr {
+			t.Errorf("%q: expected ERR_CANON_HDR, got %v", bad, err)
+		}
+	}
+}
+
+// TestOversizedPayload checks a single oversized payload fails before
+// it is written, for both value and key positions.  The final
+// total-size check would also give ERR_LIMIT_SIZE, so the message pins
+// the per-payload guard.
+func TestOversizedPayload(t *testing.T) {
+	big := make([]byte, map1.MaxCanonBytes+1)
+	cases := map[string]map1.Value{
+		"bytes":  map1.Bytes(big),
+		"string": map1.String(big),
+		"key":    map1.NewMap(map1.MapEntry{Key: string(big), Value: map1.Bool(true)}),
+	}
+	for name, v := range cases {
+		_, err := map1.MCFBytes(v)
+		if err == nil || err.(*map1.MapError).Code != map1.ErrLimitSize || !strings.Contains(err.Error(), "payload exceeds") {
+			t.Errorf("%s: expected ERR_LIMIT_SIZE from the payload guard, got %v", name, err)
+		}
+	}
+}
+
+// TestGzippedCanonBytes checks the MID survives compression and that
+// decompression is bounded.
+func TestGzippedCanonBytes(t *testing.T) {
+	m := map1.NewMap(map1.MapEntry{Key: "k", Value: map1.String("v")})
+	gz, err := map1.CanonBytesGzipped(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid, err := map1.MIDFromGzippedCanonBytes(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := map1.MIDFull(m); mid != want {
+		t.Errorf("got %s, want %s", mid, want)
+	}
+
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	zw.Write([]byte("MAP1\x00"))
+	zw.Write(make([]byte, map1.MaxCanonBytes+10))
+	zw.Close()
+	if _, err := map1.MIDFromGzippedCanonBytes(bomb.Bytes()); err == nil || err.(*map1.MapError).Code != map1.ErrLimitSize {
+		t.Errorf("expected ERR_LIMIT_SIZE, got %v", err)
+	}
+}
+
+// TestConcurrentMID exercises the concurrency contract.  Run with -race.
+func TestConcurrentMID(t *testing.T) {
+	shared := map1.NewMap(
+		map1.MapEntry{Key: "b", Value: map1.List{map1.Integer(1), map1.String("x")}},
+		map1.MapEntry{Key: "a", Value: map1.NewMap(map1.MapEntry{Key: "z", Value: map1.Bool(false)})},
+	)
+	want, _ := map1.MIDFull(shared)
+
+	sm := map1.NewSafeMap(map1.MapEntry{Key: "n", Value: map1.Integer(0)})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if mid, err := map1.MIDFull(shared); err != nil || mid != want {
+					t.Errorf("shared tree: got %s, %v", mid, err)
+					return
+				}
+				sm.Set("n", map1.Integer(g*1000+i))
+				if _, err := sm.MID(); err != nil {
+					t.Errorf("SafeMap: %v", err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if snap := sm.Snapshot(); len(snap.Keys) != 1 {
+		t.Errorf("expected 1 key after concurrent Set, got %d", len(snap.Keys))
+	}
+}
+
+// TestMIDCache checks LRU bounds and concurrent sharded use.  Run with -race.
+func TestMIDCache(t *testing.T) {
+	c := map1.NewMIDCache(2)
+	c.Put("a", "1")
+	c.Put("b", "2")
+	c.Get("a")
+	c.Put("c", "3") // evicts b
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if mid, ok := c.Get("a"); !ok || mid != "1" {
+		t.Error("a should have survived")
+	}
+
+	sc := map1.NewShardedMIDCache(4, 16)
+	raw := []byte(`{"k":"v"}`)
+	want, _ := map1.MIDFullJSON(raw)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if mid, err := sc.MIDFullJSON(raw); err != nil || mid != want {
+					t.Errorf("got %s, %v", mid, err)
+					return
+				}
+				sc.Put(fmt.Sprintf("%d/%d", g, i), "x")
+			}
+		}(g)
+	}
+	wg.Wait()
+	if sc.Len() > 4*16 {
+		t.Errorf("sharded cache exceeded bound: %d", sc.Len())
+	}
+	if _, err := sc.MIDFullJSON([]byte(`{"a":1.5}`)); err == nil {
+		t.Error("expected error for float input")
+	}
+}
+
+func benchmarkCacheParallel(b *testing.B, c interface{ MIDFullJSON([]byte) (string, error) }) {
+	inputs := make([][]byte, 256)
+	for i := range inputs {
+		inputs[i] = []byte(fmt.Sprintf(`{"id":%d}`, i))
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.MIDFullJSON(inputs[i%len(inputs)])
+			i++
+		}
+	})
+}
+
+func BenchmarkMIDCacheParallel(b *testing.B) {
+	benchmarkCacheParallel(b, map1.NewMIDCache(1024))
+}
+
+func BenchmarkShardedMIDCacheParallel(b *testing.B) {
+	benchmarkCacheParallel(b, map1.NewShardedMIDCache(16, 64))
+}
+
+// TestFileStore checks put/get, dedup and corruption detection.
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := map1.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := map1.NewMap(map1.MapEntry{Key: "k", Value: map1.Bytes{1, 2, 3}})
+	mid, err := fs.Put(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := fs.Put(v); err != nil || again != mid {
+		t.Fatalf("second put: %s, %v", again, err)
+	}
+	path := filepath.Join(dir, mid[5:7], mid[7:])
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("object not at git-style path: %v", err)
+	}
+
+	got, err := fs.Get(mid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := map1.MIDFull(got); m != mid {
+		t.Errorf("round-trip MID %s != %s", m, mid)
+	}
+	if ok, _ := fs.Has(mid); !ok {
+		t.Error("Has should report stored object")
+	}
+
+	other, _ := map1.CanonBytesFull(map1.String("other"))
+	os.WriteFile(path, other, 0o644)
+	if _, err := fs.Get(mid); err == nil {
+		t.Error("expected corruption error")
+	}
+
+	missing := "map1:" + strings.Repeat("0", 64)
+	if _, err := fs.Get(missing); !errors.Is(err, iofs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if _, err := fs.Get("map1:../../etc"); err == nil {
+		t.Error("expected malformed MID error")
+	}
+}
+
+// TestMIDFromCanonBytesFastAgrees mutates valid CANON_BYTES and checks
+// the scanner and the decoder agree on every result.
+func TestMIDFromCanonBytesFastAgrees(t *testing.T) {
+	v := map1.NewMap(
+		map1.MapEntry{Key: "a", Value: map1.List{map1.Integer(-2), map1.Bool(true), map1.Bytes("xy")}},
+		map1.MapEntry{Key: "b", Value: map1.NewMap(map1.MapEntry{Key: "c", Value: map1.String("d")})},
+		map1.MapEntry{Key: "bb", Value: map1.String("é")},
+	)
+	canon, _ := map1.CanonBytesFull(v)
+	check := func(in []byte) {
+		m1, e1 := map1.MIDFromCanonBytes(in)
+		m2, e2 := map1.MIDFromCanonBytesFast(in)
+		c1, c2 := "", ""
+		if e1 != nil {
+			c1 = e1.(*map1.MapError).Code
+		}
+		if e2 != nil {
+			c2 = e2.(*map1.MapError).Code
+		}
+		if m1 != m2 || c1 != c2 {
+			t.Errorf("%x: decoder %s %s, scanner %s %s", in, m1, c1, m2, c2)
+		}
+	}
+	check(canon)
+	for i := range canon {
+		for _, b := range []byte{0x00, 0x01, 0x02, 0x04, 0x06, 0x7F, 0x80, 0xFF} {
+			mut := append([]byte(nil), canon...)
+			mut[i] = b
+			check(mut)
+		}
+		check(canon[:i])
+	}
+}
+
+func BenchmarkMIDFromCanonBytes(b *testing.B) {
+	canon := benchCanon()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		map1.MIDFromCanonBytes(canon)
+	}
+}
+
+func BenchmarkMIDFromCanonBytesFast(b *testing.B) {
+	canon := benchCanon()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		map1.MIDFromCanonBytesFast(canon)
+	}
+}
+
+func benchCanon() []byte {
+	entries := make([]map1.MapEntry, 200)
+	for i := range entries {
+		entries[i] = map1.MapEntry{
+			Key:   fmt.Sprintf("key%04d", i),
+			Value: map1.List{map1.String("v"), map1.Integer(int64(i)), map1.Bool(i%2 == 0)},
+		}
+	}
+	canon, err := map1.CanonBytesFull(map1.NewMap(entries...))
+	if err != nil {
+		panic(err)
+	}
+	return canon
+}
+
+// TestSortedMapMark checks the Sorted mark never changes the output,
+// even when it is wrong.
+func TestSortedMapMark(t *testing.T) {
+	plain := map1.NewMap(
+		map1.MapEntry{Key: "a", Value: map1.Integer(1)},
+		map1.MapEntry{Key: "b", Value: map1.Integer(2)},
+	)
+	want, _ := map1.MIDFull(plain)
+	marked := &map1.Map{Keys: plain.Keys, Values: plain.Values, Sorted: true}
+	if got, err := map1.MIDFull(marked); err != nil || got != want {
+		t.Errorf("marked: got %s, %v; want %s", got, err, want)
+	}
+
+	wrong := &map1.Map{Keys: []string{"b", "a"}, Values: []map1.Value{map1.Integer(2), map1.Integer(1)}, Sorted: true}
+	if got, err := map1.MIDFull(wrong); err != nil || got != want {
+		t.Errorf("wrongly marked: got %s, %v; want %s", got, err, want)
+	}
+	for _, bad := range []*map1.Map{
+		{Keys: []string{"a", "a"}, Values: []map1.Value{map1.Integer(1), map1.Integer(2)}, Sorted: true},
+		{Keys: []string{"a", "b\xff"}, Values: []map1.Value{map1.Integer(1), map1.Integer(2)}, Sorted: true},
+	} {
+		want := map1.ValidateAll(bad).Code()
+		if _, err := map1.MIDFull(bad); err == nil || err.(*map1.MapError).Code != want {
+			t.Errorf("marked %q: got %v, want %s", bad.Keys, err, want)
+		}
+	}
+
+	// A decoded map is marked Sorted; adding a key to it must not break
+	// encoding.
+	dec, err := map1.DecodeCanonBytes(map1.MustCanonBytesFull(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := dec.(*map1.Map)
+	m.Keys = append(m.Keys, "0")
+	m.Values = append(m.Values, map1.Integer(0))
+	plain.Keys = append(plain.Keys, "0")
+	plain.Values = append(plain.Values, map1.Integer(0))
+	if got, err := map1.MIDFull(m); err != nil || got != map1.MustMIDFull(plain) {
+		t.Errorf("decoded then extended: got %s, %v", got, err)
+	}
+	if me := map1.ValidateAll(m); me != nil {
+		t.Errorf("decoded then extended: ValidateAll: %v", me)
+	}
+}
+
+func benchMap(shuffled bool) *map1.Map {
+	m := &map1.Map{}
+	for i := 0; i < 1000; i++ {
+		m.Keys = append(m.Keys, fmt.Sprintf("k%05d", i))
+		m.Values = append(m.Values, map1.Integer(int64(i)))
+	}
+	if shuffled {
+		for i := range m.Keys {
+			j := (i * 7919) % len(m.Keys)
+			m.Keys[i], m.Keys[j] = m.Keys[j], m.Keys[i]
+		}
+	}
+	return m
+}
+
+func BenchmarkEncodePresortedMap(b *testing.B) {
+	m := benchMap(false)
+	for i := 0; i < b.N; i++ {
+		map1.CanonBytesFull(m)
+	}
+}
+
+func BenchmarkEncodeMarkedMap(b *testing.B) {
+	m := benchMap(false)
+	m.Sorted = true
+	for i := 0; i < b.N; i++ {
+		map1.CanonBytesFull(m)
+	}
+}
+
+func BenchmarkEncodeShuffledMap(b *testing.B) {
+	m := benchMap(true)
+	for i := 0; i < b.N; i++ {
+		map1.CanonBytesFull(m)
+	}
+}
+
+// TestBindValidate checks BindValidate and compiled pointers agree
+// with BindProject.
+func TestBindValidate(t *testing.T) {
+	d := map1.NewMap(
+		map1.MapEntry{Key: "a", Value: map1.NewMap(map1.MapEntry{Key: "b", Value: map1.Bool(true)})},
+		map1.MapEntry{Key: "l", Value: map1.List{map1.Integer(1)}},
+	)
+	cases := [][]string{
+		{"/a/b"},
+		{"/a", "/a/b"},
+		{"/nope"},
+		{"/a", "/nope"},
+		{"/l/0"},
+		{"/a", "/a"},
+		{"a"},
+		{"/a~2"},
+		{""},
+	}
+	for _, ptrs := range cases {
+		_, projErr := map1.BindProject(d, ptrs)
+		valErr := map1.BindValidate(d, ptrs)
+		if (projErr == nil) != (valErr == nil) ||
+			(projErr != nil && projErr.(*map1.MapError).Code != valErr.(*map1.MapError).Code) {
+			t.Errorf("%q: BindProject %v, BindValidate %v", ptrs, projErr, valErr)
+		}
+
+		// Compiled pointers must give the same answer as MIDBind.
+		wantMID, wantErr := map1.MIDBind(d, ptrs)
+		var gotMID string
+		cp, gotErr := map1.CompilePointers(ptrs)
+		if gotErr == nil {
+			gotMID, gotErr = map1.MIDBindCompiled(d, cp)
+		}
+		if gotMID != wantMID || (gotErr == nil) != (wantErr == nil) {
+			t.Errorf("%q: MIDBindCompiled %s %v, MIDBind %s %v", ptrs, gotMID, gotErr, wantMID, wantErr)
+		}
+	}
+	if err := map1.BindValidate(map1.List{}, nil); err == nil {
+		t.Error("expected ERR_SCHEMA for LIST root")
+	}
+}
+
+// TestParseURIFragmentPointer checks fragment decoding and errors.
+func TestParseURIFragmentPointer(t *testing.T) {
+	good := map[string]string{
+		"#":              "",
+		"#/a%20b/c":      "/a b/c",
+		"#/m~0n":         "/m~0n",
+		"#/%E2%82%AC":    "/€",
+		"#/a%2Fb":        "/a/b",
+		"#/users/0/name": "/users/0/name",
+	}
+	for in, want := range good {
+		if got, err := map1.ParseURIFragmentPointer(in); err != nil || got != want {
+			t.Errorf("%q: got %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"/a", "#/a%zz", "#/a%", "#a", "#/%FF", "#/~2"} {
+		if _, err := map1.ParseURIFragmentPointer(bad); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+			t.Errorf("%q: expected ERR_SCHEMA, got %v", bad, err)
+		}
+	}
+}
+
+// TestMIDBindMulti checks the multi-descriptor LIST framing.
+func TestMIDBindMulti(t *testing.T) {
+	a := map1.NewMap(map1.MapEntry{Key: "x", Value: map1.Integer(1)}, map1.MapEntry{Key: "y", Value: map1.Integer(2)})
+	b := map1.NewMap(map1.MapEntry{Key: "z", Value: map1.String("s")})
+
+	got, err := map1.MIDBindMulti([]map1.Value{a, b}, [][]string{{"/x"}, {"/nope"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := map1.MIDFull(map1.List{
+		map1.NewMap(map1.MapEntry{Key: "x", Value: map1.Integer(1)}),
+		map1.EmptyMap(),
+	})
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, err := map1.MIDBindMulti([]map1.Value{a}, nil); err == nil {
+		t.Error("expected error for mismatched lengths")
+	}
+}
+
+// TestMerkleUpdate checks incremental updates match a full rebuild and
+// that Merkle identity is distinct from the flat MID.
+func TestMerkleUpdate(t *testing.T) {
+	build := func(leaf map1.Value) map1.Value {
+		return map1.NewMap(
+			map1.MapEntry{Key: "cfg", Value: map1.NewMap(
+				map1.MapEntry{Key: "items", Value: map1.List{map1.Integer(1), leaf}},
+				map1.MapEntry{Key: "name", Value: map1.String("n")},
+			)},
+			map1.MapEntry{Key: "v", Value: map1.Bool(true)},
+		)
+	}
+	tree, err := map1.NewMerkleTree(build(map1.Integer(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat, _ := map1.MIDFull(build(map1.Integer(2)))
+	if string(tree.MID()) == flat || !strings.HasPrefix(string(tree.MID()), "map1merkle:") {
+		t.Fatalf("merkle MID %s must be distinct from flat %s", tree.MID(), flat)
+	}
+
+	newLeaf := map1.NewMap(map1.MapEntry{Key: "x", Value: map1.Bytes("y")})
+	got, err := map1.UpdateMID(tree, "/cfg/items/1", newLeaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := map1.NewMerkleTree(build(newLeaf))
+	if got != fresh.MID() {
+		t.Errorf("incremental %s != rebuilt %s", got, fresh.MID())
+	}
+
+	for _, bad := range []string{"/nope", "/cfg/items/2", "/cfg/items/01", "/v/x"} {
+		if _, err := map1.UpdateMID(tree, bad, map1.Integer(0)); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+	if tree.MID() != got {
+		t.Error("failed updates must leave the tree unchanged")
+	}
+}
+
+// TestValueFromRawMessage checks fragments compose to the same MID as
+// the whole document and stay strict.
+func TestValueFromRawMessage(t *testing.T) {
+	user, err := map1.RawEntry("user", json.RawMessage(`{"id": 7, "tags": ["a"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := map1.RawEntry("ok", json.RawMessage(`true`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := map1.MIDFull(map1.NewMap(user, ok))
+	want, _ := map1.MIDFullJSON([]byte(`{"ok":true,"user":{"id":7,"tags":["a"]}}`))
+	if got != want {
+		t.Errorf("composed %s != whole %s", got, want)
+	}
+
+	for raw, code := range map[string]string{
+		`1.5`:           map1.ErrType,
+		`null`:          map1.ErrType,
+		`{"a":1,"a":2}`: map1.ErrDupKey,
+		`"\ud800"`:      map1.ErrUTF8,
+		`{"a":1} {}`:    map1.ErrCanonMCF,
+	} {
+		if _, err := map1.ValueFromRawMessage(json.RawMessage(raw)); err == nil || err.(*map1.MapError).Code != code {
+			t.Errorf("%s: expected %s, got %v", raw, code, err)
+		}
+	}
+}
+
+// TestCSV checks row mapping, padding, ragged-row rejection and that
+// rows share no Keys slice with each other or the caller.
+func TestCSV(t *testing.T) {
+	mids, err := map1.MIDsFromCSV(strings.NewReader("name,role\nann,admin\nbob\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := map1.MIDFull(map1.NewMap(
+		map1.MapEntry{Key: "name", Value: map1.String("bob")},
+		map1.MapEntry{Key: "role", Value: map1.String("")},
+	))
+	if len(mids) != 2 || mids[1] != want {
+		t.Errorf("got %v, want second %s", mids, want)
+	}
+
+	all, err := map1.MIDFromCSV(strings.NewReader("ann,admin\n"), []string{"name", "role"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAll, _ := map1.MIDFullJSON([]byte(`[{"name":"ann","role":"admin"}]`))
+	if all != wantAll {
+		t.Errorf("got %s, want %s", all, wantAll)
+	}
+
+	if _, err := map1.MIDFromCSV(strings.NewReader("a,b,c\n"), []string{"x", "y"}); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("ragged: expected ERR_SCHEMA, got %v", err)
+	}
+	if _, err := map1.MIDFromCSV(strings.NewReader("\"a\n"), []string{"x"}); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("malformed: expected ERR_CANON_MCF, got %v", err)
+	}
+	_, err = map1.MIDFromCSV(strings.NewReader("x,y\na\nb,c,d\n"), nil)
+	if err == nil || !strings.Contains(err.Error(), "record 2 ") {
+		t.Errorf("ragged record number: got %v, want record 2", err)
+	}
+
+	hdr := []string{"name", "role"}
+	rows, err := map1.ValuesFromCSV(strings.NewReader("ann,admin\nbob,dev\n"), hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows[0].(*map1.Map).Keys[0] = "zzz"
+	if k := rows[1].(*map1.Map).Keys[0]; k != "name" || hdr[0] != "name" {
+		t.Errorf("Keys shared: row 1 key %q, header %q", k, hdr[0])
+	}
+}
+
+// TestXML checks the XML convention and its insensitivities.
+func TestXML(t *testing.T) {
+	a, err := map1.MIDFullXML([]byte(`<cfg env="prod" id="1"><item>a</item><item>b</item><name>x</name></cfg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := map1.MIDFullXML([]byte("<?xml version=\"1.0\"?>\n<cfg  id=\"1\" env=\"prod\">\n  <item>a</item>\n  <item>b</item>\n  <!-- c -->\n  <name>x</name>\n</cfg>\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := map1.MIDFullJSON([]byte(`{"cfg":{"@attrs":{"env":"prod","id":"1"},"item":["a","b"],"name":"x"}}`))
+	if a != want || b != want {
+		t.Errorf("got %s / %s, want %s", a, b, want)
+	}
+
+	for raw, code := range map[string]string{
+		`<a>text<b/></a>`:        map1.ErrSchema,
+		`<a xmlns="urn:x"/>`:     map1.ErrSchema,
+		`<x:a xmlns:x="urn:x"/>`: map1.ErrSchema,
+		`<!DOCTYPE a><a/>`:       map1.ErrSchema,
+		`<a><b></a>`:             map1.ErrCanonMCF,
+		`<a/><b/>`:               map1.ErrCanonMCF,
+		`<a k="1" k="2"/>`:       map1.ErrDupKey,
+	} {
+		if _, err := map1.MIDFullXML([]byte(raw)); err == nil || err.(*map1.MapError).Code != code {
+			t.Errorf("%s: expected %s, got %v", raw, code, err)
+		}
+	}
+}
+
+// TestMustWrappers checks the Must* helpers agree with their checked
+// forms and panic on bad input.
+func TestMustWrappers(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)})
+	want, _ := map1.MIDFull(v)
+	canon := map1.MustCanonBytesFull(v)
+	if got := map1.MustMIDFull(v); got != want {
+		t.Errorf("MustMIDFull: got %s, want %s", got, want)
+	}
+	if got := map1.MustMIDFromCanonBytes(canon); got != want {
+		t.Errorf("MustMIDFromCanonBytes: got %s, want %s", got, want)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic on bad CANON_BYTES")
+		} else if me, ok := r.(*map1.MapError); !ok || me.Code != map1.ErrCanonHdr {
+			t.Errorf("panic value %v, want ERR_CANON_HDR", r)
+		}
+	}()
+	map1.MustMIDFromCanonBytes([]byte("nope"))
+}
+
+// TestMIDType covers parsing and the MID methods.
+func TestMIDType(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)})
+	m, err := map1.MIDFullTyped(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := map1.MIDFull(v); string(m) != s {
+		t.Errorf("typed %s != untyped %s", m, s)
+	}
+	if !m.Valid() || len(m.Digest()) != 32 {
+		t.Errorf("valid=%v digest=%x", m.Valid(), m.Digest())
+	}
+	if got := m.Short(); len(got) != len("map1:")+12 || !strings.HasPrefix(string(m), got) {
+		t.Errorf("Short: %q", got)
+	}
+	p, err := map1.ParseMIDString(string(m))
+	if err != nil || !p.Equal(m) {
+		t.Errorf("ParseMIDString: %v %v", p, err)
+	}
+	if m.Equal(map1.MID(strings.ToUpper(string(m)))) {
+		t.Error("Equal must be exact")
+	}
+
+	for _, bad := range []string{"", "map1:", "map2:" + strings.Repeat("0", 64), "map1:" + strings.Repeat("A", 64), "map1:" + strings.Repeat("0", 63)} {
+		if _, err := map1.ParseMIDString(bad); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+			t.Errorf("%q: got %v, want ERR_SCHEMA", bad, err)
+		}
+		if map1.MID(bad).Digest() != nil {
+			t.Errorf("%q: Digest should be nil", bad)
+		}
+	}
+}
+
+// TestFingerprint checks equal values share a fingerprint regardless
+// of construction order and errors match the encoder.
+func TestFingerprint(t *testing.T) {
+	a := map1.NewMap(map1.MapEntry{Key: "x", Value: map1.Integer(1)}, map1.MapEntry{Key: "y", Value: map1.Bool(true)})
+	b := map1.NewMap(map1.MapEntry{Key: "y", Value: map1.Bool(true)}, map1.MapEntry{Key: "x", Value: map1.Integer(1)})
+	c := map1.NewMap(map1.MapEntry{Key: "x", Value: map1.Integer(2)})
+	fa, err := map1.Fingerprint(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fb, _ := map1.Fingerprint(b); fa != fb {
+		t.Errorf("equal values: %x != %x", fa, fb)
+	}
+	if fc, _ := map1.Fingerprint(c); fa == fc {
+		t.Errorf("distinct values collided: %x", fa)
+	}
+	if _, err := map1.Fingerprint(map1.String("\xff")); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("got %v, want ERR_UTF8", err)
+	}
+}
+
+// TestDump checks rendering order and the DumpN limits.
+func TestDump(t *testing.T) {
+	v := map1.NewMap(
+		map1.MapEntry{Key: "b", Value: map1.List{map1.Integer(1), map1.Integer(2), map1.Integer(3)}},
+		map1.MapEntry{Key: "a", Value: map1.NewMap(map1.MapEntry{Key: "c", Value: map1.Bytes{0xab}})},
+		map1.MapEntry{Key: "d", Value: map1.Bool(true)},
+	)
+	want := `MAP {
+  "a": MAP {
+    "c": BYTES 0xab
+  }
+  "b": LIST [
+    INTEGER 1
+    INTEGER 2
+    INTEGER 3
+  ]
+  "d": BOOLEAN true
+}
+`
+	if got := map1.Dump(v); got != want {
+		t.Errorf("Dump:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = `MAP {
+  "a": MAP {… 1 entries}
+  "b": LIST [… 3 items]
+  … 1 more
+}
+`
+	if got := map1.DumpN(v, 1, 2); got != want {
+		t.Errorf("DumpN:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestBindLint checks each kind of finding and that clean sets pass.
+func TestBindLint(t *testing.T) {
+	if w := map1.BindLint([]string{"/a/b", "/c", "/d"}); len(w) != 0 {
+		t.Errorf("clean set flagged: %v", w)
+	}
+	for _, tc := range []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"/a/b/", "/x//y", "nope", "/a", "/a/c"}, []string{"/a/b/", "/x//y", "nope", "/a/b/", "/a/c"}},
+		// Rule (d) drops everything the root pointer covers.
+		{[]string{"/a/b", "/c", ""}, []string{"/a/b", "/c"}},
+	} {
+		got := map1.BindLint(tc.in)
+		if len(got) != len(tc.want) {
+			t.Errorf("%q: got %v", tc.in, got)
+			continue
+		}
+		for i, w := range got {
+			if w.Pointer != tc.want[i] || w.Reason == "" {
+				t.Errorf("%q finding %d: got %+v, want pointer %q", tc.in, i, w, tc.want[i])
+			}
+		}
+	}
+}
+
+// TestScalarRoots pins the top-level scalar contract: any value is a
+// FULL root, BIND needs a MAP, and a bare null is ERR_TYPE.
+func TestScalarRoots(t *testing.T) {
+	for in, v := range map[string]map1.Value{
+		`42`:      map1.Integer(42),
+		`-7`:      map1.Integer(-7),
+		`"hello"`: map1.String("hello"),
+		`true`:    map1.Bool(true),
+		` false `: map1.Bool(false),
+	} {
+		got, err := map1.MIDFullJSON([]byte(in))
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if want := map1.MustMIDFull(v); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+		if _, err := map1.MIDBindJSON([]byte(in), []string{""}); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+			t.Errorf("%s BIND: got %v, want ERR_SCHEMA", in, err)
+		}
+	}
+	if _, err := map1.MIDFullJSON([]byte(`null`)); err == nil || err.(*map1.MapError).Code != map1.ErrType {
+		t.Errorf("null: got %v, want ERR_TYPE", err)
+	}
+}
+
+// TestJSONTrailingContent checks trailing whitespace is accepted and
+// any other trailing byte is ERR_CANON_MCF.
+func TestJSONTrailingContent(t *testing.T) {
+	ok := []string{`{}  `, "{}\n\t\r ", "  {}\n", `42 `, `"a"` + "\n", `[1] `}
+	for _, in := range ok {
+		if _, err := map1.MIDFullJSON([]byte(in)); err != nil {
+			t.Errorf("%q: %v", in, err)
+		}
+	}
+	bad := []string{
+		`{} {}`, `{}{}`, `{} x`, `{}x`, `{}}`, `{}]`, `{},`, `{}:`, "{}\x00", "{} \xef\xbb\xbf",
+		`{} "a"`, `{} 1`, `{} null`, `42 43`, `42x`, `"a""b"`, `true false`, `[1]]`, `{}/`, "{}\x0b", "{}\u00a0",
+	}
+	for _, in := range bad {
+		if _, err := map1.MIDFullJSON([]byte(in)); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+			t.Errorf("%q: got %v, want ERR_CANON_MCF", in, err)
+		}
+	}
+}
+
+// TestMIDFromMerged checks the lazy merged MID against materializing
+// Merge, including the error cases.
+func TestMIDFromMerged(t *testing.T) {
+	base := map1.NewMap(
+		map1.MapEntry{Key: "name", Value: map1.String("svc")},
+		map1.MapEntry{Key: "limits", Value: map1.NewMap(
+			map1.MapEntry{Key: "cpu", Value: map1.Integer(1)},
+			map1.MapEntry{Key: "mem", Value: map1.Integer(256)},
+		)},
+		map1.MapEntry{Key: "tags", Value: map1.List{map1.String("a")}},
+	)
+	overlay := map1.NewMap(
+		map1.MapEntry{Key: "limits", Value: map1.NewMap(map1.MapEntry{Key: "mem", Value: map1.Integer(512)})},
+		map1.MapEntry{Key: "tags", Value: map1.List{map1.String("b")}},
+		map1.MapEntry{Key: "debug", Value: map1.Bool(true)},
+	)
+	merged := map1.Merge(base, overlay)
+	want := map1.NewMap(
+		map1.MapEntry{Key: "debug", Value: map1.Bool(true)},
+		map1.MapEntry{Key: "limits", Value: map1.NewMap(
+			map1.MapEntry{Key: "cpu", Value: map1.Integer(1)},
+			map1.MapEntry{Key: "mem", Value: map1.Integer(512)},
+		)},
+		map1.MapEntry{Key: "name", Value: map1.String("svc")},
+		map1.MapEntry{Key: "tags", Value: map1.List{map1.String("b")}},
+	)
+	if !map1.Equal(merged, want) {
+		t.Errorf("Merge:\n%s", map1.Dump(merged))
+	}
+
+	cases := []struct{ base, overlay map1.Value }{
+		{base, overlay},
+		{overlay, base},
+		{base, map1.EmptyMap()},
+		{map1.EmptyMap(), overlay},
+		{base, map1.Integer(1)},
+		{base, map1.NewMap(map1.MapEntry{Key: "limits", Value: map1.String("\xff")})},
+		{base, &map1.Map{Keys: []string{"x", "x"}, Values: []map1.Value{map1.Bool(true), map1.Bool(false)}}},
+		{&map1.Map{Keys: []string{"name", "name"}, Values: []map1.Value{map1.Bool(true), map1.Bool(false)}}, overlay},
+	}
+	for i, tc := range cases {
+		got, gotErr := map1.MIDFromMerged(tc.base, tc.overlay)
+		exp, expErr := map1.MIDFull(map1.Merge(tc.base, tc.overlay))
+		if got != exp || fmt.Sprint(gotErr) != fmt.Sprint(expErr) {
+			t.Errorf("case %d: lazy %s %v, materialized %s %v", i, got, gotErr, exp, expErr)
+		}
+	}
+}
+
+// benchOverlay is a small overlay on benchMap, the usual layered-config
+// shape: a few overrides on a large base.
+func benchOverlay() *map1.Map {
+	m := &map1.Map{}
+	for i := 0; i < 1000; i += 100 {
+		m.Keys = append(m.Keys, fmt.Sprintf("k%05d", i))
+		m.Values = append(m.Values, map1.String("override"))
+	}
+	return m
+}
+
+func BenchmarkMIDFromMerged(b *testing.B) {
+	base, overlay := benchMap(false), benchOverlay()
+	for i := 0; i < b.N; i++ {
+		map1.MIDFromMerged(base, overlay)
+	}
+}
+
+func BenchmarkMIDFullMerge(b *testing.B) {
+	base, overlay := benchMap(false), benchOverlay()
+	for i := 0; i < b.N; i++ {
+		map1.MIDFull(map1.Merge(base, overlay))
+	}
+}
+
+// TestSizeBreakdown checks EncodedSize against the encoder and that the
+// breakdown sums to it and names the oversized entry.
+func TestSizeBreakdown(t *testing.T) {
+	v := map1.NewMap(
+		map1.MapEntry{Key: "a/b", Value: map1.List{map1.Integer(1), map1.String("xy")}},
+		map1.MapEntry{Key: "c", Value: map1.NewMap(map1.MapEntry{Key: "d", Value: map1.Bool(true)})},
+	)
+	size, err := map1.EncodedSize(v)
+	if err != nil || size != len(map1.MustCanonBytesFull(v)) {
+		t.Fatalf("EncodedSize: %d %v", size, err)
+	}
+	parts, err := map1.SizeBreakdown(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := 0
+	for _, n := range parts {
+		sum += n
+	}
+	if sum != size || len(parts) != 3 || parts["/a~1b"] == 0 {
+		t.Errorf("breakdown %v sums to %d, want %d", parts, sum, size)
+	}
+
+	big := map1.NewMap(
+		map1.MapEntry{Key: "meta", Value: map1.String("x")},
+		map1.MapEntry{Key: "payload", Value: make(map1.Bytes, map1.MaxCanonBytes)},
+	)
+	if _, err := map1.EncodedSize(big); err == nil || err.(*map1.MapError).Code != map1.ErrLimitSize {
+		t.Errorf("EncodedSize(big): got %v, want ERR_LIMIT_SIZE", err)
+	}
+	parts, err = map1.SizeBreakdown(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts["/payload"] <= map1.MaxCanonBytes || parts["/meta"] >= 100 {
+		t.Errorf("breakdown %v", parts)
+	}
+
+	// A container over its entry limit is sized in full, and so is
+	// every entry after it.
+	long := make(map1.List, map1.MaxListEntries+1)
+	for i := range long {
+		long[i] = map1.Integer(i)
+	}
+	wide := map1.NewMap(
+		map1.MapEntry{Key: "a", Value: long},
+		map1.MapEntry{Key: "b", Value: map1.String(strings.Repeat("x", 1000))},
+	)
+	parts, err = map1.SizeBreakdown(wide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts["/a"] != 6+5+9*len(long) || parts["/b"] != 6+5+1000 || parts[""] != 10 {
+		t.Errorf("breakdown %v", parts)
+	}
+	wide.Keys = append(wide.Keys, "c")
+	wide.Values = append(wide.Values, map1.String("\xff"))
+	if _, err := map1.SizeBreakdown(wide); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("violation after oversized entry: got %v, want ERR_UTF8", err)
+	}
+
+	if _, err := map1.SizeBreakdown(map1.NewMap(map1.MapEntry{Key: "k", Value: map1.String("\xff")})); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("got %v, want ERR_UTF8", err)
+	}
+}
+
+// TestEncodeCanonBytes checks streaming output matches CanonBytesFull
+// and that the size limit trips before oversized output is forwarded.
+func TestEncodeCanonBytes(t *testing.T) {
+	var items map1.List
+	for i := 0; i < 100; i++ {
+		items = append(items, map1.NewMap(map1.MapEntry{Key: "blob", Value: make(map1.Bytes, 1000+i)}))
+	}
+	v := map1.NewMap(map1.MapEntry{Key: "items", Value: items})
+	var out bytes.Buffer
+	if err := map1.EncodeCanonBytes(&out, v); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), map1.MustCanonBytesFull(v)) {
+		t.Error("streamed bytes differ from CanonBytesFull")
+	}
+
+	var huge map1.List
+	for i := 0; i < 200; i++ {
+		huge = append(huge, make(map1.Bytes, 8<<10))
+	}
+	out.Reset()
+	cw := map1.NewCanonWriter(&out)
+	err := map1.EncodeCanonBytes(cw, huge)
+	if err == nil || err.(*map1.MapError).Code != map1.ErrLimitSize {
+		t.Fatalf("got %v, want ERR_LIMIT_SIZE", err)
+	}
+	if out.Len() > map1.MaxCanonBytes || cw.Written() != out.Len() || cw.Err() != err {
+		t.Errorf("forwarded %d bytes (Written %d, Err %v)", out.Len(), cw.Written(), cw.Err())
+	}
+	if _, err := cw.Write([]byte{0}); err == nil {
+		t.Error("CanonWriter error should be sticky")
+	}
+
+	bad := map1.List{map1.String("ok"), map1.String("\xff")}
+	if err := map1.EncodeCanonBytes(io.Discard, bad); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("got %v, want ERR_UTF8", err)
+	}
+}
+
+// TestCanonBytesReaderEqual compares streams across chunk boundaries.
+func TestCanonBytesReaderEqual(t *testing.T) {
+	big := map1.MustCanonBytesFull(map1.List{make(map1.Bytes, 100<<10)})
+	other := append([]byte(nil), big...)
+	other[len(other)-1] = 1
+	cases := []struct {
+		a, b []byte
+		want bool
+	}{
+		{big, big, true},
+		{big, other, false},
+		{big, big[:len(big)-1], false},
+		{[]byte("MAP1\x00"), []byte("MAP1\x00"), true},
+	}
+	for i, tc := range cases {
+		got, err := map1.CanonBytesReaderEqual(bytes.NewReader(tc.a), bytes.NewReader(tc.b))
+		if err != nil || got != tc.want {
+			t.Errorf("case %d: got %v %v, want %v", i, got, err, tc.want)
+		}
+	}
+	if _, err := map1.CanonBytesReaderEqual(bytes.NewReader(big), strings.NewReader("MAP2\x00")); err == nil || err.(*map1.MapError).Code != map1.ErrCanonHdr {
+		t.Errorf("got %v, want ERR_CANON_HDR", err)
+	}
+	huge := append([]byte("MAP1\x00"), make([]byte, map1.MaxCanonBytes)...)
+	if _, err := map1.CanonBytesReaderEqual(bytes.NewReader(huge), bytes.NewReader(huge)); err == nil || err.(*map1.MapError).Code != map1.ErrLimitSize {
+		t.Errorf("got %v, want ERR_LIMIT_SIZE", err)
+	}
+}
+
+// TestCanonicalizeJSON checks the canonical JSON form, that it keeps
+// the MID, and IsCanonicalJSON on both sides.
+func TestCanonicalizeJSON(t *testing.T) {
+	cases := map[string]string{
+		`{ "b": [1, true, "x"], "a": {"d": -2, "c": ""} }`: `{"a":{"c":"","d":-2},"b":[1,true,"x"]}`,
+		`"tab\there \u0001 \"q\" \\ \/ é"`:                 `"tab\there \u0001 \"q\" \\ / é"`,
+		`{"é":1,"z":2,"é́":3}`:                             `{"z":2,"é":1,"é́":3}`,
+		` 42 `:                                             `42`,
+	}
+	for in, want := range cases {
+		got, err := map1.CanonicalizeJSON([]byte(in))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %s %v, want %s", in, got, err, want)
+			continue
+		}
+		m1, _ := map1.MIDFullJSON([]byte(in))
+		m2, _ := map1.MIDFullJSON(got)
+		if m1 != m2 {
+			t.Errorf("%s: canonical form changed the MID", in)
+		}
+		if ok, err := map1.IsCanonicalJSON(got); !ok || err != nil {
+			t.Errorf("%s: canonical output not recognized: %v %v", got, ok, err)
+		}
+		if ok, _ := map1.IsCanonicalJSON([]byte(in)); ok {
+			t.Errorf("%s: non-canonical input recognized as canonical", in)
+		}
+	}
+	if ok, _ := map1.IsCanonicalJSON([]byte(`{"a":1}x`)); ok {
+		t.Error("trailing content accepted")
+	}
+	if _, err := map1.IsCanonicalJSON([]byte(`{"a":null}`)); err == nil || err.(*map1.MapError).Code != map1.ErrType {
+		t.Errorf("got %v, want ERR_TYPE", err)
+	}
+}
+
+// TestOversizedContainerFailsFast checks a MAP or LIST over its entry
+// limit is rejected before its entries are looked at: every key and
+// element here is invalid UTF-8, which would outrank ERR_LIMIT_SIZE if
+// it were ever checked.
+func TestOversizedContainerFailsFast(t *testing.T) {
+	m := &map1.Map{
+		Keys:   make([]string, map1.MaxMapEntries+1),
+		Values: make([]map1.Value, map1.MaxMapEntries+1),
+	}
+	l := make(map1.List, map1.MaxListEntries+1)
+	for i := range m.Keys {
+		m.Keys[i] = "\xff"
+		m.Values[i] = map1.Bool(true)
+		l[i] = map1.String("\xff")
+	}
+	for name, v := range map[string]map1.Value{"map": m, "list": l} {
+		if _, err := map1.CanonBytesFull(v); err == nil || err.(*map1.MapError).Code != map1.ErrLimitSize {
+			t.Errorf("%s: got %v, want ERR_LIMIT_SIZE", name, err)
+		}
+		if me := map1.ValidateAll(v); me == nil || len(me.Errors()) != 1 || me.Code() != map1.ErrLimitSize {
+			t.Errorf("%s: ValidateAll got %v", name, me)
+		}
+	}
+}
+
+// TestValueMatchesCanon covers a match, a mismatch and both error
+// sources.
+func TestValueMatchesCanon(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)})
+	canon := map1.MustCanonBytesFull(v)
+	if ok, err := map1.ValueMatchesCanon(v, canon); !ok || err != nil {
+		t.Errorf("match: %v %v", ok, err)
+	}
+	other := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(2)})
+	if ok, err := map1.ValueMatchesCanon(other, canon); ok || err != nil {
+		t.Errorf("mismatch: %v %v", ok, err)
+	}
+	if _, err := map1.ValueMatchesCanon(v, canon[:len(canon)-1]); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("truncated canon: got %v, want ERR_CANON_MCF", err)
+	}
+	if _, err := map1.ValueMatchesCanon(map1.String("\xff"), canon); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("bad value: got %v, want ERR_UTF8", err)
+	}
+}
+
+// TestMIDFromValuePrefix checks a custom label keeps the digest.
+func TestMIDFromValuePrefix(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)})
+	std := map1.MustMIDFull(v)
+	custom, err := map1.MIDFromValuePrefix(v, "acme:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom != "acme:"+strings.TrimPrefix(std, map1.DefaultMIDPrefix) {
+		t.Errorf("got %s, want acme: + digest of %s", custom, std)
+	}
+	if got, _ := map1.MIDFromValuePrefix(v, map1.DefaultMIDPrefix); got != std {
+		t.Errorf("default prefix: got %s, want %s", got, std)
+	}
+	if map1.MID(custom).Valid() {
+		t.Error("custom-prefixed MID should not be a valid MAP v1 MID")
+	}
+}
+
+// TestDecodeErrorOffset checks every failure is located at the tag of
+// the innermost value concerned, whatever the tag.
+func TestDecodeErrorOffset(t *testing.T) {
+	u32 := func(n byte) []byte { return []byte{0, 0, 0, n} }
+	cat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	str := func(s string) []byte { return cat([]byte{0x01}, u32(byte(len(s))), []byte(s)) }
+	deep := bytes.Repeat(cat([]byte{0x03}, u32(1)), map1.MaxDepth+1)
+
+	cases := []struct {
+		name string
+		buf  []byte
+		code string
+		off  int
+	}{
+		{"empty", nil, map1.ErrCanonMCF, 0},
+		{"unknown tag", []byte{0x07}, map1.ErrCanonMCF, 0},
+		{"truncated string len", []byte{0x01, 0, 0}, map1.ErrCanonMCF, 0},
+		{"truncated string", cat([]byte{0x01}, u32(3), []byte("a")), map1.ErrCanonMCF, 0},
+		{"truncated bytes", cat([]byte{0x02}, u32(3)), map1.ErrCanonMCF, 0},
+		{"truncated bool", []byte{0x05}, map1.ErrCanonMCF, 0},
+		{"invalid bool", []byte{0x05, 0x02}, map1.ErrCanonMCF, 0},
+		{"truncated int", []byte{0x06, 0, 0, 0}, map1.ErrCanonMCF, 0},
+		{"bool in list", cat([]byte{0x03}, u32(2), []byte{0x05, 0x01, 0x05, 0x02}), map1.ErrCanonMCF, 7},
+		{"bool truncated in list", cat([]byte{0x03}, u32(2), []byte{0x05, 0x01, 0x05}), map1.ErrCanonMCF, 7},
+		{"missing list item", cat([]byte{0x03}, u32(1)), map1.ErrCanonMCF, 5},
+		{"list count", cat([]byte{0x03}, []byte{0, 1, 0, 0}), map1.ErrLimitSize, 0},
+		{"missing map key", cat([]byte{0x04}, u32(1)), map1.ErrCanonMCF, 5},
+		{"nested bool in map", cat([]byte{0x04}, u32(1), str("a"), []byte{0x03}, u32(1), []byte{0x05, 0x09}), map1.ErrCanonMCF, 16},
+		{"bad utf8 key", cat([]byte{0x04}, u32(1), str("\xff"), []byte{0x05, 0x01}), map1.ErrUTF8, 5},
+		{"key order", cat([]byte{0x04}, u32(2), str("b"), []byte{0x05, 0x01}, str("a"), []byte{0x05, 0x01}), map1.ErrKeyOrder, 13},
+		{"dup key", cat([]byte{0x04}, u32(2), str("a"), []byte{0x05, 0x01}, str("a"), []byte{0x05, 0x01}), map1.ErrDupKey, 13},
+		{"depth", deep, map1.ErrLimitDepth, 5 * map1.MaxDepth},
+	}
+	for _, c := range cases {
+		_, off, err := map1.DecodeMCF(c.buf)
+		if err == nil {
+			t.Errorf("%s: no error", c.name)
+			continue
+		}
+		if code := err.(*map1.MapError).Code; code != c.code || off != c.off {
+			t.Errorf("%s: got %s at %d, want %s at %d", c.name, code, off, c.code, c.off)
+		}
+	}
+}
+
+// TestEnvelope checks the round trip, that the MID is untouched and
+// that envelope framing is never mistaken for CANON_BYTES.
+func TestEnvelope(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)})
+	env, err := map1.Envelope(v, "application/vnd.acme.order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ct, err := map1.OpenEnvelope(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct != "application/vnd.acme.order" || map1.MustMIDFull(got) != map1.MustMIDFull(v) {
+		t.Errorf("round trip: got %q %s", ct, map1.Dump(got))
+	}
+	canon := map1.MustCanonBytesFull(v)
+	if !bytes.HasSuffix(env, canon) {
+		t.Error("envelope does not end with the inner CANON_BYTES")
+	}
+	if _, err := map1.MIDFromCanonBytes(env); err == nil || err.(*map1.MapError).Code != map1.ErrCanonHdr {
+		t.Errorf("envelope as canon: got %v, want ERR_CANON_HDR", err)
+	}
+
+	cases := []struct {
+		name string
+		env  []byte
+		code string
+	}{
+		{"bare canon", canon, map1.ErrCanonHdr},
+		{"bad version", append([]byte("MAPE\x02\x00"), canon...), map1.ErrCanonHdr},
+		{"truncated header", []byte("MAPE\x01"), map1.ErrCanonMCF},
+		{"truncated content type", []byte("MAPE\x01\x05ab"), map1.ErrCanonMCF},
+		{"bad content type", append([]byte("MAPE\x01\x01\xff"), canon...), map1.ErrUTF8},
+		{"bad inner canon", append([]byte("MAPE\x01\x00"), canon[:len(canon)-1]...), map1.ErrCanonMCF},
+	}
+	for _, c := range cases {
+		if _, _, err := map1.OpenEnvelope(c.env); err == nil || err.(*map1.MapError).Code != c.code {
+			t.Errorf("%s: got %v, want %s", c.name, err, c.code)
+		}
+	}
+	if _, err := map1.Envelope(v, strings.Repeat("x", map1.MaxEnvelopeContentType+1)); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("long content type: got %v, want ERR_SCHEMA", err)
+	}
+}
+
+// TestHugeLengthPrefix feeds length prefixes near 0xFFFFFFFF.  On a
+// 32-bit int these wrap negative, so a plain off+int(n) > len(buf)
+// check passes and slicing panics; run with GOARCH=386 to cover that.
+func TestHugeLengthPrefix(t *testing.T) {
+	for _, n := range []uint32{0xFFFFFFFF, 0xFFFFFFFB, 0x80000000} {
+		for _, tag := range []byte{0x01, 0x02, 0x40} {
+			body := []byte{tag, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n), 'x'}
+			for _, wrap := range [][]byte{
+				body,
+				append([]byte{0x03, 0, 0, 0, 1}, body...),
+			} {
+				_, _, err := map1.DecodeMCFWithOptions(wrap, map1.DecodeOptions{SkipUnknownTags: true})
+				if err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+					t.Errorf("decode tag %#x len %#x: got %v, want ERR_CANON_MCF", tag, n, err)
+				}
+				canon := append([]byte("MAP1\x00"), wrap...)
+				if _, err := map1.MIDFromCanonBytes(canon); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+					t.Errorf("canon tag %#x len %#x: got %v, want ERR_CANON_MCF", tag, n, err)
+				}
+				if _, err := map1.MIDFromCanonBytesFast(canon); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+					t.Errorf("fast tag %#x len %#x: got %v, want ERR_CANON_MCF", tag, n, err)
+				}
+			}
+		}
+	}
+}
+
+// TestStrictIntegers pins the fixed-width INTEGER form: exactly 8
+// payload bytes are consumed, fewer are rejected.
+func TestStrictIntegers(t *testing.T) {
+	payload := []byte{0x06, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE}
+	for _, opts := range []map1.DecodeOptions{{}, {StrictIntegers: true}} {
+		// Two INTEGERs back to back, the second cut short: the first
+		// decodes from exactly 9 bytes and the rest is rejected.
+		buf := append(append([]byte{}, payload...), payload[:5]...)
+		v, n, err := map1.DecodeMCFWithOptions(buf, opts)
+		if err != nil || v != map1.Integer(-2) || n != 9 {
+			t.Errorf("%+v: got %v %d %v, want -2 after 9 bytes", opts, v, n, err)
+		}
+		truncated := [][]byte{buf[n:]}
+		for short := 1; short < 9; short++ {
+			truncated = append(truncated, payload[:short])
+		}
+		for _, in := range truncated {
+			_, _, err := map1.DecodeMCFWithOptions(in, opts)
+			if err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+				t.Errorf("%+v, % x: got %v, want ERR_CANON_MCF", opts, in, err)
+				continue
+			}
+			if strict := strings.Contains(err.Error(), "exactly 8 bytes"); strict != opts.StrictIntegers {
+				t.Errorf("%+v, % x: message %q", opts, in, err)
+			}
+		}
+	}
+}
+
+// TestJSONWhitespaceInsensitive feeds one value through MIDFullJSON in
+// many layouts; insignificant whitespace must never change the MID.
+func TestJSONWhitespaceInsensitive(t *testing.T) {
+	compact := `{"a":[1,true,"x y"],"b":{"c":-2,"d":{}},"e":[]}`
+	var doc any
+	if err := json.Unmarshal([]byte(compact), &doc); err != nil {
+		t.Fatal(err)
+	}
+	indent := func(prefix, ind string) string {
+		out, err := json.MarshalIndent(doc, prefix, ind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+	want, err := map1.MIDFullJSON([]byte(compact))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variants := map[string]string{
+		"compact":       compact,
+		"2 spaces":      indent("", "  "),
+		"4 spaces":      indent("", "    "),
+		"tabs":          indent("", "\t"),
+		"crlf":          strings.ReplaceAll(indent("", "  "), "\n", "\r\n"),
+		"lone cr":       strings.ReplaceAll(indent("", " "), "\n", "\r"),
+		"leading":       " \t\r\n" + compact,
+		"trailing":      compact + "\r\n\t ",
+		"around tokens": ` { "a" : [ 1 , true , "x y" ] , "b" : { "c" : -2 , "d" : { } } , "e" : [ ] } `,
+	}
+	for name, v := range variants {
+		got, err := map1.MIDFullJSON([]byte(v))
+		if err != nil || got != want {
+			t.Errorf("%s: got %s %v, want %s", name, got, err, want)
+		}
+	}
+
+	// Whitespace inside a string is content, not layout.
+	if got, _ := map1.MIDFullJSON([]byte(strings.Replace(compact, "x y", "x  y", 1))); got == want {
+		t.Error("whitespace inside a string did not change the MID")
+	}
+	// Only space, tab, LF and CR are JSON whitespace.
+	for _, ws := range []string{"\v", "\f", "\u00a0", "\u2028"} {
+		if _, err := map1.MIDFullJSON([]byte(ws + compact)); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+			t.Errorf("%q: got %v, want ERR_CANON_MCF", ws, err)
+		}
+	}
+	// A BOM is not whitespace (§8).
+	if _, err := map1.MIDFullJSON([]byte("\xef\xbb\xbf" + compact)); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("BOM: got %v, want ERR_SCHEMA", err)
+	}
+}
+
+// TestDecodeInto checks scratch decoding agrees with DecodeCanonBytes
+// across reuse, and stops allocating once the scratch is warm.
+func TestDecodeInto(t *testing.T) {
+	blobs := [][]byte{
+		map1.MustCanonBytesFull(benchMap(false)),
+		map1.MustCanonBytesFull(map1.NewMap(
+			map1.MapEntry{Key: "b", Value: map1.Bytes{1, 2, 3}},
+			map1.MapEntry{Key: "l", Value: map1.List{map1.String("x"), map1.List{}, map1.EmptyMap(), map1.Bool(true)}},
+			map1.MapEntry{Key: "s", Value: map1.String(strings.Repeat("long ", 40))},
+		)),
+		map1.MustCanonBytesFull(map1.Integer(7)),
+	}
+	var scratch map1.DecodeScratch
+	for round := 0; round < 3; round++ {
+		for i, canon := range blobs {
+			got, err := map1.DecodeInto(canon, &scratch)
+			if err != nil {
+				t.Fatalf("round %d blob %d: %v", round, i, err)
+			}
+			if !bytes.Equal(map1.MustCanonBytesFull(got), canon) {
+				t.Errorf("round %d blob %d: round trip differs", round, i)
+			}
+		}
+	}
+
+	for _, bad := range [][]byte{nil, blobs[1][:len(blobs[1])-1], append(blobs[2], 0)} {
+		_, want := map1.DecodeCanonBytes(bad)
+		if _, err := map1.DecodeInto(bad, &scratch); fmt.Sprint(err) != fmt.Sprint(want) {
+			t.Errorf("%x: got %v, want %v", bad, err, want)
+		}
+	}
+	if v, err := map1.DecodeInto(blobs[2], nil); err != nil || v != map1.Integer(7) {
+		t.Errorf("nil scratch: got %v %v", v, err)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		map1.DecodeInto(blobs[0], &scratch)
+	})
+	if allocs > 1 {
+		t.Errorf("warm scratch: %v allocs per decode", allocs)
+	}
+}
+
+func BenchmarkDecodeCanonBytes(b *testing.B) {
+	canon := map1.MustCanonBytesFull(benchMap(false))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		map1.DecodeCanonBytes(canon)
+	}
+}
+
+func BenchmarkDecodeInto(b *testing.B) {
+	canon := map1.MustCanonBytesFull(benchMap(false))
+	var scratch map1.DecodeScratch
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		map1.DecodeInto(canon, &scratch)
+	}
+}
+
+// TestCompareKeys checks CompareKeys is the order the encoder emits.
+func TestCompareKeys(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "\x00", -1},
+		{"a", "ab", -1},
+		{"b", "ab", 1},
+		{"Z", "a", -1},
+		{"\u007f", "\u0080", -1},
+		{"\uffff", "\U00010000", -1}, // UTF-16 order would say otherwise
+		{"\u00e9", "e\u0301", 1},     // no normalization
+	}
+	for _, c := range cases {
+		if got := map1.CompareKeys(c.a, c.b); got != c.want {
+			t.Errorf("CompareKeys(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+		if got := map1.CompareKeys(c.b, c.a); got != -c.want {
+			t.Errorf("CompareKeys(%q, %q) = %d, want %d", c.b, c.a, got, -c.want)
+		}
+		if c.want == 0 {
+			continue
+		}
+		// The encoder agrees: decoding sorts a before b iff a < b.
+		m, _ := map1.DecodeCanonBytes(map1.MustCanonBytesFull(map1.NewMap(
+			map1.MapEntry{Key: c.a, Value: map1.Bool(true)},
+			map1.MapEntry{Key: c.b, Value: map1.Bool(true)},
+		)))
+		if first := m.(*map1.Map).Keys[0]; (first == c.a) != (c.want < 0) {
+			t.Errorf("encoder order for %q, %q starts with %q", c.a, c.b, first)
+		}
+	}
+}
+
+// TestFindDuplicateKeys checks duplicates are reported once each, in
+// first-appearance order, and agree with the encoder.
+func TestFindDuplicateKeys(t *testing.T) {
+	m := &map1.Map{
+		Keys:   []string{"b", "a", "b", "c", "a", "b"},
+		Values: []map1.Value{map1.Bool(true), map1.Bool(true), map1.Bool(true), map1.Bool(true), map1.Bool(true), map1.Bool(true)},
+	}
+	if got := m.FindDuplicateKeys(); fmt.Sprint(got) != "[b a]" {
+		t.Errorf("got %q, want [b a]", got)
+	}
+	if _, err := map1.MIDFull(m); err == nil || err.(*map1.MapError).Code != map1.ErrDupKey {
+		t.Errorf("encoder: got %v, want ERR_DUP_KEY", err)
+	}
+	if got := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Bool(true)}).FindDuplicateKeys(); got != nil {
+		t.Errorf("unique keys: got %q", got)
+	}
+	if got := map1.EmptyMap().FindDuplicateKeys(); got != nil {
+		t.Errorf("empty map: got %q", got)
+	}
+}
+
+// TestByteStringTag checks the opt-in {"$bytes": "<base64>"} convention
+// and that strict parsing is unaffected.
+func TestByteStringTag(t *testing.T) {
+	opts := map1.LenientOptions{ByteStringTag: "$bytes"}
+	raw := []byte(`{"blob":{"$bytes":"AAEC/w=="},"list":[{"$bytes":""}]}`)
+	want := map1.MustMIDFull(map1.NewMap(
+		map1.MapEntry{Key: "blob", Value: map1.Bytes{0, 1, 2, 0xff}},
+		map1.MapEntry{Key: "list", Value: map1.List{map1.Bytes{}}},
+	))
+	if got, err := map1.MIDFullJSONWithOptions(raw, opts); err != nil || got != want {
+		t.Errorf("tagged: got %s %v, want %s", got, err, want)
+	}
+	strict, err := map1.MIDFullJSON(raw)
+	if err != nil || strict == want {
+		t.Errorf("strict: got %s %v, want a MAP-valued MID", strict, err)
+	}
+	if got, _ := map1.MIDFullJSONWithOptions(raw, map1.LenientOptions{}); got != strict {
+		t.Errorf("zero options: got %s, want strict %s", got, strict)
+	}
+	bind := map1.MustMIDFull(map1.NewMap(map1.MapEntry{Key: "blob", Value: map1.Bytes{0, 1, 2, 0xff}}))
+	if got, err := map1.MIDBindJSONWithOptions(raw, []string{"/blob"}, opts); err != nil || got != bind {
+		t.Errorf("bind: got %s %v, want %s", got, err, bind)
+	}
+
+	for _, bad := range []string{
+		`{"b":{"$bytes":"AAE"}}`,
+		`{"b":{"$bytes":"!!!!"}}`,
+		`{"b":{"$bytes":"AAF="}}`,
+		`{"b":{"$bytes":1}}`,
+		`{"b":{"$bytes":"AA==","x":1}}`,
+		`{"$bytes":{"$bytes":"AA=="}}`,
+	} {
+		if _, err := map1.MIDFullJSONWithOptions([]byte(bad), opts); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+			t.Errorf("%s: got %v, want ERR_SCHEMA", bad, err)
+		}
+	}
+	// Tag errors take part in §6.2 precedence like any other.
+	if _, err := map1.MIDFullJSONWithOptions([]byte(`{"b":{"$bytes":"!"},"c":"\ud800"}`), opts); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("schema vs utf8: got %v, want ERR_SCHEMA", err)
+	}
+	if _, err := map1.MIDFullJSONWithOptions([]byte(`{"b":{"$bytes":"!"},"c":{]}`), opts); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("schema vs mcf: got %v, want ERR_CANON_MCF", err)
+	}
+}
+
+// TestCanonDiff checks the first divergence is located by pointer for
+// each kind of difference.
+func TestCanonDiff(t *testing.T) {
+	base := func(mem map1.Value, tags map1.List) []byte {
+		return map1.MustCanonBytesFull(map1.NewMap(
+			map1.MapEntry{Key: "limits", Value: map1.NewMap(
+				map1.MapEntry{Key: "cpu", Value: map1.Integer(1)},
+				map1.MapEntry{Key: "m/em", Value: mem},
+			)},
+			map1.MapEntry{Key: "tags", Value: tags},
+		))
+	}
+	a := base(map1.Integer(256), map1.List{map1.String("x")})
+
+	cases := []struct {
+		name string
+		b    []byte
+		want []string
+	}{
+		{"scalar", base(map1.Integer(512), map1.List{map1.String("x")}),
+			[]string{"/limits/m~1em: INTEGER 256 in a, INTEGER 512 in b", "a: 060000000000000100", "b: 060000000000000200"}},
+		{"type", base(map1.String("256"), map1.List{map1.String("x")}),
+			[]string{`/limits/m~1em: INTEGER 256 in a, STRING "256" in b`}},
+		{"list length", base(map1.Integer(256), map1.List{map1.String("x"), map1.Bool(true)}),
+			[]string{"/tags: LIST of 1 items in a, 2 in b", "a: (absent)", "b: 0501"}},
+		{"extra key", map1.MustCanonBytesFull(map1.NewMap(map1.MapEntry{Key: "limits", Value: map1.EmptyMap()})),
+			[]string{"/limits/cpu: key only in a", "b: (absent)"}},
+		{"root", map1.MustCanonBytesFull(map1.Integer(1)),
+			[]string{"(root): MAP {… 2 entries} in a, INTEGER 1 in b", "first differing byte at offset 5"}},
+	}
+	for _, c := range cases {
+		got, err := map1.CanonDiff(a, c.b)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, w := range c.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s: missing %q in:\n%s", c.name, w, got)
+			}
+		}
+	}
+
+	if got, err := map1.CanonDiff(a, append([]byte{}, a...)); got != "" || err != nil {
+		t.Errorf("identical: got %q %v", got, err)
+	}
+	if _, err := map1.CanonDiff(a, a[:len(a)-1]); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("invalid b: got %v, want ERR_CANON_MCF", err)
+	}
+}
+
+// TestMCFKeyUTF8 pins ERR_UTF8 for invalid map keys on every path that
+// validates CANON_BYTES, including keys that also break the key order.
+func TestMCFKeyUTF8(t *testing.T) {
+	entry := func(key string) []byte {
+		return append(append([]byte{0x01, 0, 0, 0, byte(len(key))}, key...), 0x05, 0x01)
+	}
+	canonMap := func(keys ...string) []byte {
+		b := []byte{'M', 'A', 'P', '1', 0, 0x04, 0, 0, 0, byte(len(keys))}
+		for _, k := range keys {
+			b = append(b, entry(k)...)
+		}
+		return b
+	}
+	cases := map[string][]byte{
+		"surrogate":        canonMap("\xed\xa0\x80"),
+		"low surrogate":    canonMap("a", "\xed\xbf\xbf"),
+		"invalid byte":     canonMap("\xff"),
+		"truncated rune":   canonMap("a\xc3"),
+		"overlong":         canonMap("\xc0\xaf"),
+		"beyond U+10FFFF":  canonMap("\xf4\x90\x80\x80"),
+		"bad and unsorted": canonMap("b", "\xed\xa0\x80", "a"),
+		"bad duplicate":    canonMap("\xff", "\xff"),
+	}
+	var scratch map1.DecodeScratch
+	for name, canon := range cases {
+		paths := map[string]error{}
+		_, paths["MIDFromCanonBytes"] = map1.MIDFromCanonBytes(canon)
+		_, paths["MIDFromCanonBytesFast"] = map1.MIDFromCanonBytesFast(canon)
+		_, paths["DecodeCanonBytes"] = map1.DecodeCanonBytes(canon)
+		_, paths["DecodeInto"] = map1.DecodeInto(canon, &scratch)
+		_, _, paths["DecodeMCF"] = map1.DecodeMCF(canon[5:])
+		for path, err := range paths {
+			if err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+				t.Errorf("%s via %s: got %v, want ERR_UTF8", name, path, err)
+			}
+		}
+	}
+}
+
+// TestDepthBoundary checks every path agrees on the nesting limit: a
+// value with MaxDepth nested containers is accepted and one more is
+// ERR_LIMIT_DEPTH, whether the containers are MAPs, LISTs or both, and
+// regardless of the scalar leaf (which adds no depth).
+func TestDepthBoundary(t *testing.T) {
+	// build nests n containers around a leaf, alternating MAP and LIST
+	// when mixed, and returns the value, its JSON and its MCF.
+	build := func(n int, kinds string) (map1.Value, string, []byte) {
+		var v map1.Value = map1.Integer(1)
+		js := "1"
+		mcf := []byte{0x06, 0, 0, 0, 0, 0, 0, 0, 1}
+		for i := 0; i < n; i++ {
+			if kinds[i%len(kinds)] == 'm' {
+				v = map1.NewMap(map1.MapEntry{Key: "k", Value: v})
+				js = `{"k":` + js + `}`
+				mcf = append([]byte{0x04, 0, 0, 0, 1, 0x01, 0, 0, 0, 1, 'k'}, mcf...)
+			} else {
+				v = map1.List{v}
+				js = "[" + js + "]"
+				mcf = append([]byte{0x03, 0, 0, 0, 1}, mcf...)
+			}
+		}
+		return v, js, mcf
+	}
+
+	for _, kinds := range []string{"m", "l", "ml", "lm"} {
+		for _, n := range []int{map1.MaxDepth - 1, map1.MaxDepth, map1.MaxDepth + 1} {
+			v, js, mcf := build(n, kinds)
+			canon := append([]byte("MAP1\x00"), mcf...)
+			paths := map[string]error{}
+			_, paths["encode"] = map1.CanonBytesFull(v)
+			paths["validate"] = nil
+			if me := map1.ValidateAll(v); me != nil {
+				paths["validate"] = &map1.MapError{Code: me.Code()}
+			}
+			_, paths["size"] = map1.EncodedSize(v)
+			_, paths["json"] = map1.MIDFullJSON([]byte(js))
+			_, _, paths["mcf"] = map1.DecodeMCF(mcf)
+			_, paths["canon"] = map1.MIDFromCanonBytes(canon)
+			_, paths["canon fast"] = map1.MIDFromCanonBytesFast(canon)
+			for path, err := range paths {
+				if n <= map1.MaxDepth && err != nil {
+					t.Errorf("%s depth %d via %s: %v", kinds, n, path, err)
+				}
+				if n > map1.MaxDepth && (err == nil || err.(*map1.MapError).Code != map1.ErrLimitDepth) {
+					t.Errorf("%s depth %d via %s: got %v, want ERR_LIMIT_DEPTH", kinds, n, path, err)
+				}
+			}
+		}
+	}
+}
+
+// TestMIDFullJSONReader checks the streaming adapter agrees with
+// MIDFullJSON however the input is chunked, including strict checks
+// that straddle read boundaries deep inside a large document.
+func TestMIDFullJSONReader(t *testing.T) {
+	var big strings.Builder
+	big.WriteString(`{"items":[`)
+	for i := 0; i < 5000; i++ {
+		if i > 0 {
+			big.WriteByte(',')
+		}
+		fmt.Fprintf(&big, `{"id":%d,"name":"item \"%d\" \\u"}`, i, i)
+	}
+	big.WriteString(`]}`)
+	large := big.String()
+	deep := strings.Replace(large, `"name":"item \"4321\"`, `"name":"item \ud83d\ude00\"4321\"`, 1)
+
+	inputs := map[string]string{
+		"large":             large,
+		"large surrogate":   deep,
+		"late lone low":     strings.Replace(large, `\\u"}]}`, `\\u\uDC00"}]}`, 1),
+		"bom":               " \r\n\xef\xbb\xbf" + large,
+		"bom then bad":      "\xef\xbb\xbf" + deep,
+		"partial bom":       "\xef\xbb{}",
+		"bom in string":     "{\"a\":\"\xef\xbb\xbf\"}",
+		"escaped backslash": `{"a":"\\ud800"}`,
+		"bad escape":        `{"a":"\u12"}`,
+		"null":              `{"a":null}`,
+		"empty":             ``,
+		"trailing":          `{} {}`,
+	}
+	readers := map[string]func(string) io.Reader{
+		"whole":    func(s string) io.Reader { return strings.NewReader(s) },
+		"one byte": func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+		"half":     func(s string) io.Reader { return iotest.HalfReader(strings.NewReader(s)) },
+	}
+	for name, in := range inputs {
+		want, wantErr := map1.MIDFullJSON([]byte(in))
+		for rname, mk := range readers {
+			got, err := map1.MIDFullJSONReader(mk(in))
+			if got != want || fmt.Sprint(err) != fmt.Sprint(wantErr) {
+				t.Errorf("%s, %s: got %s %v, want %s %v", name, rname, got, err, want, wantErr)
+			}
+		}
+	}
+	if _, err := map1.MIDFullJSONReader(strings.NewReader(deep)); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("deep surrogate: got %v, want ERR_UTF8", err)
+	}
+
+	// Past the size limit the code is ERR_LIMIT_SIZE (or the BOM's
+	// ERR_SCHEMA, which outranks it) whatever else is wrong and however
+	// the input is chunked.
+	pad := strings.Repeat("a", map1.MaxCanonBytes)
+	oversize := map[string]struct{ in, code string }{
+		"surrogate past limit":   {`["` + pad + `\uD800"]`, map1.ErrLimitSize},
+		"surrogate before limit": {`["\uD800` + pad + `"]`, map1.ErrLimitSize},
+		"null before limit":      {`[null,"` + pad + `"]`, map1.ErrLimitSize},
+		"syntax before limit":    {`[1,,"` + pad + `"]`, map1.ErrLimitSize},
+		"depth before limit":     {strings.Repeat("[", map1.MaxDepth+1) + `"` + pad + `"`, map1.ErrLimitSize},
+		"bom":                    {"\xef\xbb\xbf[\"" + pad + `"]`, map1.ErrSchema},
+		"whitespace at limit":    {`""` + strings.Repeat(" ", map1.MaxCanonBytes-1), map1.ErrLimitSize},
+	}
+	for name, tc := range oversize {
+		if _, err := map1.MIDFullJSON([]byte(tc.in)); err == nil || err.(*map1.MapError).Code != tc.code {
+			t.Errorf("%s: MIDFullJSON got %v, want %s", name, err, tc.code)
+		}
+		for _, rname := range []string{"whole", "one byte"} {
+			if _, err := map1.MIDFullJSONReader(readers[rname](tc.in)); err == nil || err.(*map1.MapError).Code != tc.code {
+				t.Errorf("%s, %s: got %v, want %s", name, rname, err, tc.code)
+			}
+		}
+	}
+	if _, err := map1.MIDFullJSON([]byte(`""` + strings.Repeat(" ", map1.MaxCanonBytes-2))); err != nil {
+		t.Errorf("exactly at limit: %v", err)
+	}
+
+	// An endless body stops at the limit instead of being read whole.
+	endless := io.MultiReader(strings.NewReader("["), iotest.OneByteReader(&repeatReader{b: ' '}))
+	if _, err := map1.MIDFullJSONReader(endless); err == nil || err.(*map1.MapError).Code != map1.ErrLimitSize {
+		t.Errorf("endless: got %v, want ERR_LIMIT_SIZE", err)
+	}
+	// Read errors are passed through.
+	boom := errors.New("boom")
+	if _, err := map1.MIDFullJSONReader(io.MultiReader(strings.NewReader(`{"a":`), iotest.ErrReader(boom))); err != boom {
+		t.Errorf("read error: got %v, want %v", err, boom)
+	}
+}
+
+// repeatReader yields b forever.
+type repeatReader struct{ b byte }
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.b
+	}
+	return len(p), nil
+}
+
+// repeatedStrings is a LIST of many STRINGs drawn from a few values,
+// the enum-heavy shape InternStrings targets.
+func repeatedStrings() []byte {
+	l := make(map1.List, 5000)
+	for i := range l {
+		l[i] = map1.String([]string{"pending", "active", "suspended", "closed"}[i%4])
+	}
+	return map1.MustCanonBytesFull(l)[5:]
+}
+
+// TestInternStrings checks interning changes nothing but sharing.
+func TestInternStrings(t *testing.T) {
+	mcf := repeatedStrings()
+	plain, _, err := map1.DecodeMCF(mcf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interned, n, err := map1.DecodeMCFWithOptions(mcf, map1.DecodeOptions{InternStrings: true})
+	if err != nil || n != len(mcf) || !map1.Equal(plain, interned) {
+		t.Fatalf("got %d %v, want a value equal to the plain decode", n, err)
+	}
+	l := interned.(map1.List)
+	if unsafe.StringData(string(l[0].(map1.String))) != unsafe.StringData(string(l[4].(map1.String))) {
+		t.Error("equal strings do not share storage")
+	}
+	bad := append([]byte{0x03, 0, 0, 0, 2}, 0x01, 0, 0, 0, 1, 0xff, 0x01, 0, 0, 0, 1, 0xff)
+	if _, _, err := map1.DecodeMCFWithOptions(bad, map1.DecodeOptions{InternStrings: true}); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("invalid repeated string: got %v, want ERR_UTF8", err)
+	}
+}
+
+func BenchmarkDecodeRepeatedStrings(b *testing.B) {
+	mcf := repeatedStrings()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		map1.DecodeMCF(mcf)
+	}
+}
+
+func BenchmarkDecodeRepeatedStringsInterned(b *testing.B) {
+	mcf := repeatedStrings()
+	opts := map1.DecodeOptions{InternStrings: true}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		map1.DecodeMCFWithOptions(mcf, opts)
+	}
+}
+
+// TestCanonBytesAt checks subtree addressing, including through LISTs,
+// and that only the subtree is validated.
+func TestCanonBytesAt(t *testing.T) {
+	item := map1.NewMap(map1.MapEntry{Key: "sku", Value: map1.String("x-1")})
+	doc := map1.NewMap(
+		map1.MapEntry{Key: "items", Value: map1.List{map1.Integer(0), item}},
+		map1.MapEntry{Key: "a/b", Value: map1.Bool(true)},
+		map1.MapEntry{Key: "bad", Value: map1.String("\xff")},
+		map1.MapEntry{Key: "dup", Value: map1.Bool(true)},
+		map1.MapEntry{Key: "dup", Value: map1.Bool(false)},
+	)
+	ok := map[string]map1.Value{
+		"/items/1":     item,
+		"/items/1/sku": map1.String("x-1"),
+		"/items/0":     map1.Integer(0),
+		"/a~1b":        map1.Bool(true),
+	}
+	for ptr, want := range ok {
+		canon, err := map1.CanonBytesAt(doc, ptr)
+		if err != nil || !bytes.Equal(canon, map1.MustCanonBytesFull(want)) {
+			t.Errorf("%s: got %x %v", ptr, canon, err)
+		}
+		if mid, err := map1.MIDAt(doc, ptr); err != nil || mid != map1.MustMIDFull(want) {
+			t.Errorf("MIDAt %s: got %s %v", ptr, mid, err)
+		}
+	}
+	bad := map[string]string{
+		"":           map1.ErrUTF8,
+		"/bad":       map1.ErrUTF8,
+		"/dup":       map1.ErrDupKey,
+		"/missing":   map1.ErrSchema,
+		"/items/2":   map1.ErrSchema,
+		"/items/01":  map1.ErrSchema,
+		"/items/-":   map1.ErrSchema,
+		"/a~1b/x":    map1.ErrSchema,
+		"items":      map1.ErrSchema,
+		"/items/1/~": map1.ErrSchema,
+	}
+	for ptr, code := range bad {
+		if _, err := map1.CanonBytesAt(doc, ptr); err == nil || err.(*map1.MapError).Code != code {
+			t.Errorf("%q: got %v, want %s", ptr, err, code)
+		}
+	}
+}
+
+func TestFloatFieldsInJSON(t *testing.T) {
+	got, err := map1.FloatFieldsInJSON([]byte(`{"price":9.99,"qty":2,"dims":[1,2.5e0,{"w":1E3}],"a/b":{"~":-0.0},"ok":"1.5"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/price", "/dims/1", "/dims/2/w", "/a~1b/~0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FloatFieldsInJSON = %q, want %q", got, want)
+	}
+
+	if got, err := map1.FloatFieldsInJSON([]byte(`1.5`)); err != nil || !reflect.DeepEqual(got, []string{""}) {
+		t.Errorf("root float: %q, %v", got, err)
+	}
+	if got, err := map1.FloatFieldsInJSON([]byte(`{"a":1,"b":null}`)); err != nil || len(got) != 0 {
+		t.Errorf("no floats: %q, %v", got, err)
+	}
+	for _, in := range []string{`{"a":1.5`, `{"a":1.5}}`, `{"a":.5}`, ``} {
+		_, err := map1.FloatFieldsInJSON([]byte(in))
+		if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrCanonMCF {
+			t.Errorf("%q: err = %v, want %s", in, err, map1.ErrCanonMCF)
+		}
+	}
+
+	// The ERR_TYPE message names the token.
+	_, err = map1.MIDFullJSON([]byte(`{"price":9.99}`))
+	if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrType || !strings.Contains(me.Error(), "9.99") {
+		t.Errorf("MIDFullJSON float: err = %v", err)
+	}
+}
+
+func TestSignedCanonBytes(t *testing.T) {
+	key := []byte("k")
+	sign := func(b []byte) ([]byte, error) {
+		m := hmac.New(sha256.New, key)
+		m.Write(b)
+		return m.Sum(nil), nil
+	}
+	verify := func(b, sig []byte) bool {
+		want, _ := sign(b)
+		return hmac.Equal(want, sig)
+	}
+
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)})
+	canon, sig, err := map1.SignedCanonBytes(v, sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := map1.CanonBytesFull(v); !bytes.Equal(canon, want) {
+		t.Errorf("canon = %x, want %x", canon, want)
+	}
+	if ok, err := map1.VerifyCanonSignature(canon, sig, verify); !ok || err != nil {
+		t.Errorf("verify = %v, %v", ok, err)
+	}
+
+	tampered := append([]byte(nil), canon...)
+	tampered[len(tampered)-1] ^= 1
+	if ok, err := map1.VerifyCanonSignature(tampered, sig, verify); ok || err != nil {
+		t.Errorf("tampered verify = %v, %v", ok, err)
+	}
+
+	// Malformed canon is rejected before the verifier runs.
+	called := false
+	_, err = map1.VerifyCanonSignature(canon[:len(canon)-1], sig, func(_, _ []byte) bool { called = true; return true })
+	if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrCanonMCF || called {
+		t.Errorf("truncated: err = %v, verifier called = %v", err, called)
+	}
+
+	// Encoding and signer errors pass through.
+	if _, _, err := map1.SignedCanonBytes(map1.String("\xff"), sign); err == nil {
+		t.Error("invalid UTF-8 signed")
+	}
+	boom := errors.New("boom")
+	if _, _, err := map1.SignedCanonBytes(v, func([]byte) ([]byte, error) { return nil, boom }); err != boom {
+		t.Errorf("signer error = %v, want %v", err, boom)
+	}
+}
+
+func TestNullExtension(t *testing.T) {
+	allow := map1.EncodeOptions{AllowNull: true}
+	withNull := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Null{}})
+
+	// Off by default, everywhere.
+	for name, err := range map[string]error{
+		"encode": func() error { _, err := map1.MIDFromValue(withNull); return err }(),
+		"json":   func() error { _, err := map1.MIDFullJSON([]byte(`{"a":null}`)); return err }(),
+	} {
+		if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrType {
+			t.Errorf("%s: err = %v, want %s", name, err, map1.ErrType)
+		}
+	}
+	if me := map1.ValidateAll(withNull); me == nil || me.Code() != map1.ErrType {
+		t.Errorf("ValidateAll = %v, want %s", me, map1.ErrType)
+	}
+
+	canon, err := map1.CanonBytesFromValueWithOptions(withNull, allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("MAP1\x00\x04\x00\x00\x00\x01\x01\x00\x00\x00\x01a\x40\x00\x00\x00\x00"); !bytes.Equal(canon, want) {
+		t.Errorf("canon = %x, want %x", canon, want)
+	}
+
+	// Distinct from every empty value and from absence.
+	mid, _ := map1.MIDFromValueWithOptions(withNull, allow)
+	for _, other := range []map1.Value{
+		map1.NewMap(map1.MapEntry{Key: "a", Value: map1.String("")}),
+		map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Bytes{}}),
+		map1.NewMap(map1.MapEntry{Key: "a", Value: map1.List{}}),
+		map1.NewMap(),
+	} {
+		if m, _ := map1.MIDFromValueWithOptions(other, allow); m == mid {
+			t.Errorf("NULL collides with %s", map1.Dump(other))
+		}
+	}
+
+	// Only an opted-in decoder reads it back.
+	if _, err := map1.MIDFromCanonBytes(canon); err == nil {
+		t.Error("conformant decoder accepted NULL")
+	}
+	v, err := map1.DecodeCanonBytesWithOptions(canon, map1.DecodeOptions{AllowNull: true})
+	if err != nil || !map1.Equal(v, withNull) {
+		t.Errorf("decode = %v, %v", v, err)
+	}
+	v, err = map1.DecodeCanonBytesWithOptions(canon, map1.DecodeOptions{SkipUnknownTags: true})
+	if err != nil || !map1.Equal(v, map1.NewMap()) {
+		t.Errorf("skip = %v, %v", v, err)
+	}
+	root, _ := map1.CanonBytesFromValueWithOptions(map1.Null{}, allow)
+	if v, err := map1.DecodeCanonBytesWithOptions(root, map1.DecodeOptions{AllowNull: true}); err != nil || v != (map1.Null{}) {
+		t.Errorf("root NULL = %v, %v", v, err)
+	}
+	_, err = map1.DecodeCanonBytesWithOptions([]byte("MAP1\x00\x40\x00\x00\x00\x01x"), map1.DecodeOptions{AllowNull: true})
+	if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrCanonMCF {
+		t.Errorf("NULL with payload: err = %v, want %s", err, map1.ErrCanonMCF)
+	}
+
+	// The lenient JSON adapter maps null to it, FULL and BIND.
+	lenient := map1.LenientOptions{NullAsNull: true}
+	if got, err := map1.MIDFullJSONWithOptions([]byte(`{"a":null}`), lenient); err != nil || got != mid {
+		t.Errorf("MIDFullJSONWithOptions = %s, %v, want %s", got, err, mid)
+	}
+	if got, err := map1.MIDBindJSONWithOptions([]byte(`{"a":null,"b":1}`), []string{"/a"}, lenient); err != nil || got != mid {
+		t.Errorf("MIDBindJSONWithOptions = %s, %v, want %s", got, err, mid)
+	}
+}
+
+func TestTrailingBytesOffset(t *testing.T) {
+	canon := map1.MustCanonBytesFull(map1.Integer(1)) // root ends at 14
+	for _, tc := range []struct {
+		extra []byte
+		want  string
+	}{
+		{[]byte{0}, "at offset 14 (1 extra)"},
+		{canon, "at offset 14 (14 extra)"},
+	} {
+		in := append(append([]byte(nil), canon...), tc.extra...)
+		for name, fn := range map[string]func([]byte) (string, error){
+			"MIDFromCanonBytes":     map1.MIDFromCanonBytes,
+			"MIDFromCanonBytesFast": map1.MIDFromCanonBytesFast,
+		} {
+			_, err := fn(in)
+			if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrCanonMCF || !strings.Contains(me.Msg, tc.want) {
+				t.Errorf("%s(+%d): err = %v, want %q", name, len(tc.extra), err, tc.want)
+			}
+		}
+	}
+}
+
+func TestValueFromDecodedJSON(t *testing.T) {
+	decode := func(s string) any {
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	raw := `{"a":[1,-9223372036854775808,true,"x"],"b":{"c":"d","e":9223372036854775807},"":[]}`
+	v, err := map1.ValueFromDecodedJSON(decode(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := map1.MIDFromValue(v)
+	if want, _ := map1.MIDFullJSON([]byte(raw)); got != want {
+		t.Errorf("MID = %s, want %s (MIDFullJSON)", got, want)
+	}
+
+	for _, tc := range []struct {
+		name string
+		in   any
+		code string
+		path string
+	}{
+		{"fraction", decode(`{"a":{"b":1.5}}`), map1.ErrType, "/a/b"},
+		{"exponent", decode(`[1e3]`), map1.ErrType, "/0"},
+		{"overflow", decode(`{"n":9223372036854775808}`), map1.ErrType, "/n"},
+		{"null", decode(`{"a/b":null}`), map1.ErrType, "/a~1b"},
+		{"float64", map[string]any{"f": float64(2)}, map1.ErrType, "/f"},
+		{"go type", []any{int(1)}, map1.ErrSchema, "/0"},
+		{"schema beats type", []any{nil, struct{}{}}, map1.ErrSchema, "/1"},
+	} {
+		_, err := map1.ValueFromDecodedJSON(tc.in)
+		me, ok := err.(*map1.MapError)
+		if !ok || me.Code != tc.code || me.Path != tc.path {
+			t.Errorf("%s: err = %v, want %s at %s", tc.name, err, tc.code, tc.path)
+		}
+	}
+	_, err = map1.ValueFromDecodedJSON(decode(`{"a":1.25}`))
+	if err == nil || !strings.Contains(err.Error(), "1.25") {
+		t.Errorf("fraction error %v does not name the token", err)
+	}
+
+	deep := any("leaf")
+	for i := 0; i < map1.MaxDepth+1; i++ {
+		deep = []any{deep}
+	}
+	if _, err := map1.ValueFromDecodedJSON(deep); err == nil || err.(*map1.MapError).Code != map1.ErrLimitDepth {
+		t.Errorf("deep: err = %v, want %s", err, map1.ErrLimitDepth)
+	}
+}
+
+func TestMIDFromValues(t *testing.T) {
+	fromJSON, err := map1.ValueFromRawMessage(json.RawMessage(`{"k":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	built := map1.String("x")
+	got, err := map1.MIDFromValues(fromJSON, built)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := map1.MIDFromValue(map1.List{fromJSON, built}); got != want {
+		t.Errorf("MIDFromValues = %s, want %s", got, want)
+	}
+	if swapped, _ := map1.MIDFromValues(built, fromJSON); swapped == got {
+		t.Error("order ignored")
+	}
+	if empty, _ := map1.MIDFromValues(); empty != map1.MustMIDFromCanonBytes([]byte("MAP1\x00\x03\x00\x00\x00\x00")) {
+		t.Errorf("no values = %s, want the empty LIST", empty)
+	}
+	if _, err := map1.MIDFromValues(built, map1.String("\xff")); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("bad element: err = %v, want %s", err, map1.ErrUTF8)
+	}
+}
+
+func TestRejectEmptyKeys(t *testing.T) {
+	m := map1.NewMap(
+		map1.MapEntry{Key: "a", Value: map1.Integer(2)},
+		map1.MapEntry{Key: "\x00", Value: map1.Integer(3)},
+		map1.MapEntry{Key: "", Value: map1.Integer(1)},
+	)
+
+	// Accepted by default, and "" sorts before every other key.
+	canon, err := map1.CanonBytesFromValue(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := map1.DecodeCanonBytes(canon)
+	if keys := v.(*map1.Map).Keys; !reflect.DeepEqual(keys, []string{"", "\x00", "a"}) {
+		t.Errorf("keys = %q, want empty key first", keys)
+	}
+	if _, err := map1.MIDFullJSON([]byte(`{"":1}`)); err != nil {
+		t.Errorf("MIDFullJSON: %v", err)
+	}
+
+	wantSchema := func(name string, err error) {
+		t.Helper()
+		if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrSchema {
+			t.Errorf("%s: err = %v, want %s", name, err, map1.ErrSchema)
+		}
+	}
+	_, err = map1.CanonBytesFromValueWithOptions(m, map1.EncodeOptions{RejectEmptyKeys: true})
+	wantSchema("encode", err)
+	_, err = map1.DecodeCanonBytesWithOptions(canon, map1.DecodeOptions{RejectEmptyKeys: true})
+	wantSchema("decode", err)
+	_, err = map1.MIDFullJSONWithOptions([]byte(`{"a":{"":1}}`), map1.LenientOptions{RejectEmptyKeys: true})
+	wantSchema("json", err)
+	_, err = map1.MIDBindJSONWithOptions([]byte(`{"a":1,"":2}`), []string{"/a"}, map1.LenientOptions{RejectEmptyKeys: true})
+	wantSchema("json bind", err)
+
+	// It is a SCHEMA error, so it outranks a type error elsewhere.
+	_, err = map1.MIDFullJSONWithOptions([]byte(`{"a":1.5,"":1}`), map1.LenientOptions{RejectEmptyKeys: true})
+	wantSchema("json precedence", err)
+
+	// Non-empty keys are unaffected.
+	if _, err := map1.CanonBytesFromValueWithOptions(map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)}), map1.EncodeOptions{RejectEmptyKeys: true}); err != nil {
+		t.Errorf("non-empty key rejected: %v", err)
+	}
+}
+
+func TestJSONMIDEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{`{"a":1,"b":[true,"x"]}`, " {\"b\" : [true, \"\\u0078\"],\n\"a\":1} ", true},
+		{`{"a":1}`, `{"a":2}`, false},
+		{`{"a":1}`, `{"a":"1"}`, false},
+		{`[]`, `{}`, false},
+		{`-0`, `0`, true},
+	} {
+		got, err := map1.JSONMIDEqual([]byte(tc.a), []byte(tc.b))
+		if err != nil || got != tc.want {
+			t.Errorf("JSONMIDEqual(%s, %s) = %v, %v, want %v", tc.a, tc.b, got, err, tc.want)
+		}
+		midA, _ := map1.MIDFullJSON([]byte(tc.a))
+		midB, _ := map1.MIDFullJSON([]byte(tc.b))
+		if (midA == midB) != tc.want {
+			t.Errorf("%s vs %s disagrees with MIDFullJSON", tc.a, tc.b)
+		}
+	}
+
+	for _, tc := range []struct {
+		a, b, code string
+	}{
+		{`{"a":1.5}`, `{}`, map1.ErrType},
+		{`{}`, `{"a":1,"a":2}`, map1.ErrDupKey},
+		{`{"a":null}`, `{`, map1.ErrType}, // rawA's error first
+	} {
+		_, err := map1.JSONMIDEqual([]byte(tc.a), []byte(tc.b))
+		if me, ok := err.(*map1.MapError); !ok || me.Code != tc.code {
+			t.Errorf("JSONMIDEqual(%s, %s): err = %v, want %s", tc.a, tc.b, err, tc.code)
+		}
+	}
+}
+
+func TestRecanonicalizeCanonBytes(t *testing.T) {
+	str := func(s string) []byte { return append([]byte{0x01, 0, 0, 0, byte(len(s))}, s...) }
+	mp := func(n byte, kvs ...[]byte) []byte {
+		return append([]byte{0x04, 0, 0, 0, n}, bytes.Join(kvs, nil)...)
+	}
+	canon := func(body []byte) []byte { return append([]byte("MAP1\x00"), body...) }
+	one := []byte{0x06, 0, 0, 0, 0, 0, 0, 0, 1}
+
+	// {"b": {"z":1, "y":1}, "a": 1}, unsorted at both levels.
+	unsorted := canon(mp(2, str("b"), mp(2, str("z"), one, str("y"), one), str("a"), one))
+	want := map1.MustCanonBytesFull(map1.NewMap(
+		map1.MapEntry{Key: "a", Value: map1.Integer(1)},
+		map1.MapEntry{Key: "b", Value: map1.NewMap(
+			map1.MapEntry{Key: "y", Value: map1.Integer(1)},
+			map1.MapEntry{Key: "z", Value: map1.Integer(1)},
+		)},
+	))
+
+	if _, err := map1.DecodeCanonBytes(unsorted); err == nil || err.(*map1.MapError).Code != map1.ErrKeyOrder {
+		t.Errorf("strict decode: err = %v, want %s", err, map1.ErrKeyOrder)
+	}
+	got, err := map1.RecanonicalizeCanonBytes(unsorted)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("RecanonicalizeCanonBytes = %x, %v, want %x", got, err, want)
+	}
+	if got, err := map1.RecanonicalizeCanonBytes(want); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("canonical input changed: %x, %v", got, err)
+	}
+
+	// Duplicates are still rejected, even when not adjacent.
+	for name, in := range map[string][]byte{
+		"adjacent":     canon(mp(2, str("a"), one, str("a"), one)),
+		"non-adjacent": canon(mp(3, str("b"), one, str("a"), one, str("b"), one)),
+	} {
+		if _, err := map1.RecanonicalizeCanonBytes(in); err == nil || err.(*map1.MapError).Code != map1.ErrDupKey {
+			t.Errorf("%s: err = %v, want %s", name, err, map1.ErrDupKey)
+		}
+	}
+	if _, err := map1.RecanonicalizeCanonBytes(canon(mp(1, str("a"), str("\xff")))); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("bad UTF-8: err = %v, want %s", err, map1.ErrUTF8)
+	}
+}
+
+func TestBadKeyTagOffset(t *testing.T) {
+	// The INTEGER key tag sits after the header and the MAP's tag and count.
+	in := []byte("MAP1\x00\x04\x00\x00\x00\x01\x06\x00\x00\x00\x00\x00\x00\x00\x01\x05\x01")
+	for name, fn := range map[string]func([]byte) (string, error){
+		"MIDFromCanonBytes":     map1.MIDFromCanonBytes,
+		"MIDFromCanonBytesFast": map1.MIDFromCanonBytesFast,
+	} {
+		_, err := fn(in)
+		if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrSchema || !strings.Contains(me.Msg, "offset 10 has tag 0x06") {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+// TestEmptyValueMIDs pins the table in docs/gotchas.md: each empty
+// value has a MID of its own.
+func TestEmptyValueMIDs(t *testing.T) {
+	want := []struct {
+		v   map1.Value
+		mid string
+	}{
+		{map1.EmptyMap(), "map1:c67223b733f8def290e67077621379eef3565ac3940462b8491c7f0834894816"},
+		{map1.EmptyList(), "map1:228190053caeedbea5bcf8deebc7c47a91f0be74a83b68a8cbba480e7a615cd5"},
+		{map1.String(""), "map1:d264a09926744749bd140935da518d78612494fcd72fc20966ad1f8825d2df1f"},
+		{map1.Bytes(nil), "map1:56ad90a00f6efe386f74ec91a1dbec56561ff8daac3b77e354be9e737e3369d4"},
+	}
+	seen := map[string]bool{}
+	for _, w := range want {
+		got, err := map1.MIDFromValue(w.v)
+		if err != nil || got != w.mid {
+			t.Errorf("%s: MID = %s, %v, want %s", map1.Dump(w.v), got, err, w.mid)
+		}
+		if seen[got] {
+			t.Errorf("%s: MID collides with another empty value", map1.Dump(w.v))
+		}
+		seen[got] = true
+	}
+	if got, _ := map1.MIDFromValue(map1.Bytes{}); got != want[3].mid {
+		t.Errorf("Bytes{} MID = %s, want that of Bytes(nil)", got)
+	}
+	if got, _ := map1.MIDFromValue(map1.List(nil)); got != want[1].mid {
+		t.Errorf("List(nil) MID = %s, want that of EmptyList()", got)
+	}
+}
+
+func TestMIDFullWithSize(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.List{map1.Integer(1), map1.String("xy")}})
+	mid, size, err := map1.MIDFullWithSize(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canon, want, _ := map1.CanonBytesAndMIDFull(v)
+	if mid != want || size != len(canon) {
+		t.Errorf("MIDFullWithSize = %s, %d, want %s, %d", mid, size, want, len(canon))
+	}
+	if n, _ := map1.EncodedSize(v); n != size {
+		t.Errorf("EncodedSize = %d, want %d", n, size)
+	}
+	if _, size, err := map1.MIDFullWithSize(map1.String("\xff")); err == nil || size != 0 {
+		t.Errorf("invalid value: size %d, err %v", size, err)
+	}
+}
+
+func TestMaxTotalNodes(t *testing.T) {
+	// 1 root + 3 objects + 6 scalars = 10 values.
+	raw := []byte(`[{"a":1,"b":2},{"a":3,"b":4},{"a":5,"b":6}]`)
+	v, err := map1.ValueFromRawMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canon := map1.MustCanonBytesFull(v)
+	want := map1.MustMIDFromCanonBytes(canon)
+
+	wantLimit := func(name string, err error) {
+		t.Helper()
+		if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrLimitSize {
+			t.Errorf("%s: err = %v, want %s", name, err, map1.ErrLimitSize)
+		}
+	}
+	for _, max := range []int{0, 10, 11} {
+		if got, err := map1.MIDFullJSONWithOptions(raw, map1.LenientOptions{MaxTotalNodes: max}); err != nil || got != want {
+			t.Errorf("json, cap %d: %s, %v", max, got, err)
+		}
+		if _, err := map1.DecodeCanonBytesWithOptions(canon, map1.DecodeOptions{MaxTotalNodes: max}); err != nil {
+			t.Errorf("mcf, cap %d: %v", max, err)
+		}
+	}
+	_, err = map1.MIDFullJSONWithOptions(raw, map1.LenientOptions{MaxTotalNodes: 9})
+	wantLimit("json", err)
+	_, err = map1.MIDBindJSONWithOptions(raw[1:14], []string{"/a"}, map1.LenientOptions{MaxTotalNodes: 2})
+	wantLimit("json bind", err)
+	_, err = map1.DecodeCanonBytesWithOptions(canon, map1.DecodeOptions{MaxTotalNodes: 9})
+	wantLimit("mcf", err)
+
+	// A higher-precedence violation seen before the cap still wins.
+	_, err = map1.MIDFullJSONWithOptions([]byte(`[1.5,1,1,1]`), map1.LenientOptions{MaxTotalNodes: 3})
+	if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrType {
+		t.Errorf("precedence: err = %v, want %s", err, map1.ErrType)
+	}
+}
+
+func TestAllKeys(t *testing.T) {
+	v := map1.NewMap(
+		map1.MapEntry{Key: "name", Value: map1.String("x")},
+		map1.MapEntry{Key: "items", Value: map1.List{
+			map1.NewMap(map1.MapEntry{Key: "id", Value: map1.Integer(1)}, map1.MapEntry{Key: "name", Value: map1.String("a")}),
+			map1.NewMap(map1.MapEntry{Key: "\u00e9", Value: map1.NewMap(map1.MapEntry{Key: "", Value: map1.Bool(true)})}),
+		}},
+	)
+	want := []string{"", "id", "items", "name", "\u00e9"}
+	if got := map1.AllKeys(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("AllKeys = %q, want %q", got, want)
+	}
+	for _, v := range []map1.Value{map1.String("x"), map1.List{map1.Integer(1)}, map1.EmptyMap(), nil} {
+		if got := map1.AllKeys(v); got != nil {
+			t.Errorf("AllKeys(%v) = %q, want nil", v, got)
+		}
+	}
+}
+
+func TestParseMID(t *testing.T) {
+	mid := map1.MustMIDFromCanonBytes(map1.MustCanonBytesFull(map1.String("x")))
+	scheme, digest, err := map1.ParseMID(mid)
+	if err != nil || scheme != "map1" {
+		t.Fatalf("ParseMID = %q, %v", scheme, err)
+	}
+	if want := sha256.Sum256(map1.MustCanonBytesFull(map1.String("x"))); digest != want {
+		t.Errorf("digest = %x, want %x", digest, want)
+	}
+	if !bytes.Equal(map1.MID(mid).Digest(), digest[:]) {
+		t.Error("MID.Digest disagrees with ParseMID")
+	}
+
+	hex64 := mid[len("map1:"):]
+	for _, tc := range []struct{ name, in, msg string }{
+		{"empty", "", "no scheme"},
+		{"no scheme", hex64, "no scheme"},
+		{"other scheme", "map2:" + hex64, `unknown scheme "map2"`},
+		{"uppercase scheme", "MAP1:" + hex64, `unknown scheme "MAP1"`},
+		{"short", mid[:len(mid)-1], "63 hex digits"},
+		{"long", mid + "0", "65 hex digits"},
+		{"uppercase hex", "map1:" + strings.ToUpper(hex64), "not a lowercase hex digit"},
+		{"bad hex", mid[:len(mid)-1] + "g", "'g' at digest offset 63"},
+		{"second colon", "map1:" + hex64[:63] + ":", "':' at digest offset 63"},
+	} {
+		_, _, err := map1.ParseMID(tc.in)
+		if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrSchema || !strings.Contains(me.Msg, tc.msg) {
+			t.Errorf("%s: err = %v, want %s mentioning %q", tc.name, err, map1.ErrSchema, tc.msg)
+		}
+		if map1.MID(tc.in).Valid() {
+			t.Errorf("%s: MID.Valid accepted %q", tc.name, tc.in)
+		}
+	}
+}
+
+func TestCanonBytesChecksummed(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.List{map1.Integer(1), map1.Bytes{0xff}}})
+	b, err := map1.CanonBytesChecksummed(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canon := map1.MustCanonBytesFull(v)
+	if !bytes.Equal(b[:len(canon)], canon) || len(b) != len(canon)+4 {
+		t.Fatalf("CanonBytesChecksummed = %x, want %x + 4 bytes", b, canon)
+	}
+	if got := binary.BigEndian.Uint32(b[len(canon):]); got != crc32.Checksum(canon, crc32.MakeTable(crc32.Castagnoli)) {
+		t.Errorf("checksum = %08x", got)
+	}
+	mid, err := map1.MIDFromChecksummedCanonBytes(b)
+	if want := map1.MustMIDFromCanonBytes(canon); err != nil || mid != want {
+		t.Errorf("MID = %s, %v, want %s", mid, err, want)
+	}
+
+	flip := func(i int) []byte {
+		c := append([]byte(nil), b...)
+		c[i] ^= 0x10
+		return c
+	}
+	for _, tc := range []struct {
+		name string
+		in   []byte
+		code string
+	}{
+		{"body bit flip", flip(len(canon) - 1), map1.ErrCanonMCF},
+		{"checksum bit flip", flip(len(b) - 1), map1.ErrCanonMCF},
+		{"no checksum", canon[:5], map1.ErrCanonMCF},
+		{"header", flip(0), map1.ErrCanonHdr},
+		{"unchecksummed", canon, map1.ErrCanonMCF},
+		{"too long", append([]byte("MAP1\x00"), make([]byte, map1.MaxCanonBytes)...), map1.ErrLimitSize},
+	} {
+		_, err := map1.MIDFromChecksummedCanonBytes(tc.in)
+		if me, ok := err.(*map1.MapError); !ok || me.Code != tc.code {
+			t.Errorf("%s: err = %v, want %s", tc.name, err, tc.code)
+		}
+	}
+
+	// A correct checksum over invalid CANON_BYTES still fails validation.
+	bad := []byte("MAP1\x00\x01\x00\x00\x00\x01\xff")
+	bad = binary.BigEndian.AppendUint32(bad, crc32.Checksum(bad, crc32.MakeTable(crc32.Castagnoli)))
+	if _, err := map1.MIDFromChecksummedCanonBytes(bad); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("valid checksum, bad UTF-8: err = %v", err)
+	}
+}
+
+// TestMaxTotalNodesAmplification: ~200k empty LISTs fit in
+// MAX_CANON_BYTES and each decodes to a Value; MaxTotalNodes stops the
+// decode long before they are all materialized.
+func TestMaxTotalNodesAmplification(t *testing.T) {
+	u32 := func(n int) []byte { return []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)} }
+	empty := []byte{0x03, 0, 0, 0, 0}
+	n := (map1.MaxCanonBytes - 100) / len(empty)
+
+	// LISTs are capped at MaxListEntries, so spread them over a few.
+	chunks := (n + map1.MaxListEntries - 1) / map1.MaxListEntries
+	canon := append([]byte("MAP1\x00\x03"), u32(chunks)...)
+	for left := n; left > 0; left -= map1.MaxListEntries {
+		k := min(left, map1.MaxListEntries)
+		canon = append(append(canon, 0x03), u32(k)...)
+		canon = append(canon, bytes.Repeat(empty, k)...)
+	}
+
+	if _, err := map1.DecodeCanonBytesWithOptions(canon, map1.DecodeOptions{}); err != nil {
+		t.Fatalf("uncapped: %v", err)
+	}
+	allocs := testing.AllocsPerRun(1, func() {
+		_, err := map1.DecodeCanonBytesWithOptions(canon, map1.DecodeOptions{MaxTotalNodes: 1000})
+		if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrLimitSize {
+			t.Errorf("capped: err = %v, want %s", err, map1.ErrLimitSize)
+		}
+	})
+	if allocs > 2000 {
+		t.Errorf("capped decode made %.0f allocations; the cap should stop it early", allocs)
+	}
+}
+
+func TestCanonBytesAndMIDFromJSON(t *testing.T) {
+	raw := []byte(`{"b":[1,true],"a":"x"}`)
+	canon, mid, err := map1.CanonBytesAndMIDFromJSON(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := map1.MIDFullJSON(raw); mid != want {
+		t.Errorf("MID = %s, want %s", mid, want)
+	}
+	if got := map1.MustMIDFromCanonBytes(canon); got != mid {
+		t.Errorf("MID of returned canon = %s, want %s", got, mid)
+	}
+	for _, bad := range []string{`{"a":1,"a":2}`, `[1.0]`, `{`} {
+		_, _, err := map1.CanonBytesAndMIDFromJSON([]byte(bad))
+		_, want := map1.MIDFullJSON([]byte(bad))
+		if err == nil || err.(*map1.MapError).Code != want.(*map1.MapError).Code {
+			t.Errorf("%s: err = %v, want %v", bad, err, want)
+		}
+	}
+}
+
+func TestMIDFromListStreaming(t *testing.T) {
+	seq := func(items ...map1.Value) func(func(map1.Value) bool) {
+		return func(yield func(map1.Value) bool) {
+			for _, v := range items {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+	check := func(name string, items []map1.Value) {
+		t.Helper()
+		got, gotErr := map1.MIDFromListStreaming(seq(items...))
+		want, wantErr := map1.MIDFull(map1.List(items))
+		if got != want || (gotErr == nil) != (wantErr == nil) ||
+			(gotErr != nil && gotErr.(*map1.MapError).Code != wantErr.(*map1.MapError).Code) {
+			t.Errorf("%s: streaming = %s, %v; MIDFull = %s, %v", name, got, gotErr, want, wantErr)
+		}
+	}
+
+	check("empty", nil)
+	check("mixed", []map1.Value{map1.Integer(1), map1.String("x"), map1.NewMap(map1.MapEntry{Key: "k", Value: map1.Bool(true)}), map1.List{}})
+	many := make([]map1.Value, map1.MaxListEntries)
+	for i := range many {
+		many[i] = map1.Integer(int64(i))
+	}
+	check("max entries", many)
+	check("type then utf8", []map1.Value{map1.Null{}, map1.String("\xff")})
+	check("utf8 then schema", []map1.Value{map1.String("\xff"), nil})
+	deep := map1.Value(map1.List{})
+	for i := 0; i < map1.MaxDepth; i++ {
+		deep = map1.List{deep}
+	}
+	check("depth", []map1.Value{deep})
+	check("size", []map1.Value{map1.Bytes(make([]byte, map1.MaxCanonBytes/2)), map1.Bytes(make([]byte, map1.MaxCanonBytes/2))})
+	check("size then type", []map1.Value{map1.Bytes(make([]byte, map1.MaxCanonBytes)), map1.Null{}})
+	check("too many with errors", append([]map1.Value{map1.Null{}}, append(many, map1.Integer(0))...))
+
+	// Past the limit, nothing more is drawn.
+	drawn := 0
+	_, err := map1.MIDFromListStreaming(func(yield func(map1.Value) bool) {
+		for yield(map1.Integer(0)) {
+			drawn++
+		}
+	})
+	if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrLimitSize || drawn != map1.MaxListEntries {
+		t.Errorf("endless: err = %v after %d values", err, drawn)
+	}
+}
+
+func TestJSONErrorPath(t *testing.T) {
+	for _, tc := range []struct {
+		in, code, path string
+	}{
+		{`{"config":{"ratios":[1,2,0.5]}}`, map1.ErrType, "/config/ratios/2"},
+		{`{"a":[{"b":null}]}`, map1.ErrType, "/a/0/b"},
+		{`{"a/b":{"c~d":1e3}}`, map1.ErrType, "/a~1b/c~0d"},
+		{`{"x":[1,"\ud800"]}`, map1.ErrUTF8, "/x/1"},
+		{`{"x":{"\udc00":1}}`, map1.ErrUTF8, "/x/\ufffd"},
+		{`{"a":{"k":1,"k":2}}`, map1.ErrDupKey, "/a/k"},
+		{`{"a":[99999999999999999999]}`, map1.ErrType, "/a/0"},
+		{`1.5`, map1.ErrType, ""},
+	} {
+		_, err := map1.MIDFullJSON([]byte(tc.in))
+		me, ok := err.(*map1.MapError)
+		if !ok || me.Code != tc.code || me.Path != tc.path {
+			t.Errorf("%s: err = %v, want %s at %q", tc.in, err, tc.code, tc.path)
+		}
+	}
+
+	// A padded input makes the decoder consume the surrogate's string
+	// well after the reader saw it.
+	pad := strings.Repeat(" ", 40000)
+	in := `{"a":"ok",` + pad + `"b":["x","\ud83d\ude00"]}`
+	_, err := map1.MIDFullJSON([]byte(in))
+	if me, ok := err.(*map1.MapError); !ok || me.Path != "/b/1" {
+		t.Errorf("padded surrogate: err = %v, want path /b/1", err)
+	}
+
+	_, err = map1.MIDFullJSON([]byte(`{"config":{"ratios":[1,2,0.5]}}`))
+	if want := "ERR_TYPE at /config/ratios/2: JSON float not allowed"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Error() = %q, want prefix %q", err.Error(), want)
+	}
+}
+
+func TestVerifyMIDCanonBytes(t *testing.T) {
+	canon := benchCanon()
+	mid, err := map1.MIDFromCanonBytes(canon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := map1.VerifyMIDCanonBytes(canon, mid); !ok || err != nil {
+		t.Errorf("matching MID: %v, %v", ok, err)
+	}
+	other := map1.MustCanonBytesFull(map1.String("other"))
+	if ok, err := map1.VerifyMIDCanonBytes(other, mid); ok || err != nil {
+		t.Errorf("other content: %v, %v", ok, err)
+	}
+	if _, err := map1.VerifyMIDCanonBytes(canon, "map1:XYZ"); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("malformed MID: %v", err)
+	}
+
+	// Invalid canon gets exactly MIDFromCanonBytes's error.
+	for _, bad := range [][]byte{
+		canon[:len(canon)-1],
+		[]byte("MAP0\x00\x05\x01"),
+		append(append([]byte{}, canon...), 0),
+		[]byte("MAP1\x00\x04\x00\x00\x00\x02\x01\x00\x00\x00\x01b\x05\x01\x01\x00\x00\x00\x01a\x05\x01"),
+		[]byte("MAP1\x00\x01\x00\x00\x00\x01\xff"),
+	} {
+		_, want := map1.MIDFromCanonBytes(bad)
+		_, got := map1.VerifyMIDCanonBytes(bad, mid)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%x: got %v, want %v", bad, got, want)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		map1.VerifyMIDCanonBytes(canon, mid)
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocs per verify", allocs)
+	}
+}
+
+func BenchmarkVerifyMIDCanonBytes(b *testing.B) {
+	canon := benchCanon()
+	mid := map1.MustMIDFromCanonBytes(canon)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		map1.VerifyMIDCanonBytes(canon, mid)
+	}
+}
+
+func TestJSONParser(t *testing.T) {
+	jp := map1.NewJSONParser()
+	inputs := []string{
+		`{"b":1,"a":[true,"x",{"c":[]}]}`,
+		`[]`,
+		`"\u00e9"`,
+		`{"a":{"b":{"c":{"d":[1,2,3]}}},"e":{"f":"g"}}`,
+		`{"a":1,"a":2}`,
+		`{"a":[1.5]}`,
+		`{"x":"\ud800"}`,
+		`{"a":{"a":{"a":1,"a":{"b":2}}}}`,
+		`[[[[]]],{}]`,
+		`{"z":0,"y":[{"k":null}]}`,
+		`{`,
+		`{"b":1,"a":[true,"x",{"c":[]}]}`,
+	}
+	// Twice over, so the second pass runs on grown buffers.
+	for pass := 0; pass < 2; pass++ {
+		for _, in := range inputs {
+			want, wantErr := map1.MIDFullJSON([]byte(in))
+			got, gotErr := jp.MID([]byte(in))
+			if got != want || fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+				t.Errorf("%s: got %s, %v; want %s, %v", in, got, gotErr, want, wantErr)
+			}
+		}
+	}
+	deep := strings.Repeat("[", map1.MaxDepth+1) + strings.Repeat("]", map1.MaxDepth+1)
+	if _, err := jp.MID([]byte(deep)); err == nil || err.(*map1.MapError).Code != map1.ErrLimitDepth {
+		t.Errorf("too deep: %v", err)
+	}
+}
+
+var jsonParserBench = []byte(`{"id":12345,"name":"widget","tags":["a","b","c"],"attrs":{"color":"red","size":3,"ok":true}}`)
+
+func BenchmarkMIDFullJSONSmall(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		map1.MIDFullJSON(jsonParserBench)
+	}
+}
+
+func BenchmarkJSONParserMID(b *testing.B) {
+	jp := map1.NewJSONParser()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		jp.MID(jsonParserBench)
+	}
+}
+
+func TestMIDFullExcluding(t *testing.T) {
+	req := func(id string, ts int64) *map1.Map {
+		return map1.NewMap(
+			map1.MapEntry{Key: "request_id", Value: map1.String(id)},
+			map1.MapEntry{Key: "op", Value: map1.String("transfer")},
+			map1.MapEntry{Key: "meta", Value: map1.NewMap(
+				map1.MapEntry{Key: "timestamp", Value: map1.Integer(ts)},
+				map1.MapEntry{Key: "client", Value: map1.String("cli")},
+			)},
+		)
+	}
+	excl := []string{"/request_id", "/meta/timestamp"}
+	a, err := map1.MIDFullExcluding(req("r1", 100), excl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := map1.MIDFullExcluding(req("r2", 200), excl)
+	if a != b {
+		t.Errorf("volatile fields changed the MID: %s vs %s", a, b)
+	}
+	want := map1.MustMIDFull(map1.NewMap(
+		map1.MapEntry{Key: "op", Value: map1.String("transfer")},
+		map1.MapEntry{Key: "meta", Value: map1.NewMap(map1.MapEntry{Key: "client", Value: map1.String("cli")})},
+	))
+	if a != want {
+		t.Errorf("got %s, want %s", a, want)
+	}
+
+	// The input is left alone, and missing pointers are no-ops.
+	r := req("r1", 100)
+	if got, _ := map1.MIDFullExcluding(r, []string{"/nope", "/op/deeper", "/meta/nope"}); got != map1.MustMIDFull(r) {
+		t.Errorf("no-op exclusion changed the MID")
+	}
+	if _, err := map1.MIDFullExcluding(map1.String("x"), []string{"/a"}); err != nil {
+		t.Errorf("scalar root: %v", err)
+	}
+	if len(r.Keys) != 3 {
+		t.Errorf("descriptor modified: %v", r.Keys)
+	}
+
+	for _, ptrs := range [][]string{
+		{""},
+		{"/a", "/a"},
+		{"a"},
+	} {
+		if _, err := map1.MIDFullExcluding(r, ptrs); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+			t.Errorf("%q: err = %v, want ERR_SCHEMA", ptrs, err)
+		}
+	}
+	withList := map1.NewMap(map1.MapEntry{Key: "l", Value: map1.List{map1.Integer(1)}})
+	if _, err := map1.MIDFullExcluding(withList, []string{"/l/0"}); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("LIST traversal: err = %v, want ERR_SCHEMA", err)
+	}
+}
+
+func TestChain(t *testing.T) {
+	entries := []map1.Value{
+		map1.NewMap(map1.MapEntry{Key: "op", Value: map1.String("open")}),
+		map1.Integer(42),
+		map1.List{map1.Bool(true)},
+	}
+	var c map1.Chain
+	if c.Head() != "" || c.Len() != 0 {
+		t.Fatalf("zero Chain: head %q, len %d", c.Head(), c.Len())
+	}
+	prev := ""
+	for i, v := range entries {
+		entry, chain, err := c.Append(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != map1.MustMIDFull(v) {
+			t.Errorf("entry %d: MID %s", i, entry)
+		}
+		if want := map1.MustMIDFull(map1.List{map1.String(prev), v}); chain != want {
+			t.Errorf("entry %d: chain %s, want %s", i, chain, want)
+		}
+		if c.Head() != chain || c.Len() != i+1 {
+			t.Errorf("entry %d: head %s, len %d", i, c.Head(), c.Len())
+		}
+		prev = chain
+	}
+
+	// The genesis link, spelled out in bytes for other implementations:
+	// LIST(2) [ STRING "" , INTEGER 42 ].
+	var g map1.Chain
+	_, chain, _ := g.Append(map1.Integer(42))
+	canon := []byte("MAP1\x00\x03\x00\x00\x00\x02\x01\x00\x00\x00\x00\x06\x00\x00\x00\x00\x00\x00\x00\x2a")
+	if want := map1.MustMIDFromCanonBytes(canon); chain != want {
+		t.Errorf("genesis chain MID %s, want %s", chain, want)
+	}
+
+	// Reordering changes the head.
+	var r map1.Chain
+	for i := len(entries) - 1; i >= 0; i-- {
+		r.Append(entries[i])
+	}
+	if r.Head() == c.Head() {
+		t.Error("reordered chain has the same head")
+	}
+
+	// A bad entry leaves the chain as it was.
+	head := c.Head()
+	if _, _, err := c.Append(map1.String("\xff")); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("bad entry: %v", err)
+	}
+	if c.Head() != head || c.Len() != len(entries) {
+		t.Error("bad entry changed the chain")
+	}
+}
+
+func TestMIDFromCanonBytesAt(t *testing.T) {
+	records := [][]byte{
+		map1.MustCanonBytesFull(map1.String("first")),
+		map1.MustCanonBytesFull(map1.NewMap(map1.MapEntry{Key: "k", Value: map1.Integer(2)})),
+		[]byte("MAP1\x00\x01\x00\x00\x00\x01\xff"),
+		map1.MustCanonBytesFull(map1.List{}),
+	}
+	var file []byte
+	var offsets []int64
+	for _, rec := range records {
+		offsets = append(offsets, int64(len(file)))
+		file = append(file, rec...)
+	}
+	r := bytes.NewReader(file)
+	for i, rec := range records {
+		want, wantErr := map1.MIDFromCanonBytes(rec)
+		got, gotErr := map1.MIDFromCanonBytesAt(r, offsets[i], len(rec))
+		if got != want || fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+			t.Errorf("record %d: got %s, %v; want %s, %v", i, got, gotErr, want, wantErr)
+		}
+	}
+
+	// A wrong length is caught by validation, not by reading more.
+	if _, err := map1.MIDFromCanonBytesAt(r, offsets[0], len(records[0])+1); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("overlong length: %v", err)
+	}
+	last := offsets[len(offsets)-1]
+	if _, err := map1.MIDFromCanonBytesAt(r, last, len(records[3])+1); err != io.ErrUnexpectedEOF {
+		t.Errorf("past end: %v", err)
+	}
+	if _, err := map1.MIDFromCanonBytesAt(r, 0, -1); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("negative length: %v", err)
+	}
+
+	// Over the limit only the header is read.
+	big := &countingReaderAt{r: bytes.NewReader(file)}
+	if _, err := map1.MIDFromCanonBytesAt(big, 0, map1.MaxCanonBytes+1); err == nil || err.(*map1.MapError).Code != map1.ErrLimitSize || big.n != 5 {
+		t.Errorf("over limit: %v after reading %d bytes", err, big.n)
+	}
+	if _, err := map1.MIDFromCanonBytesAt(r, 1, map1.MaxCanonBytes+1); err == nil || err.(*map1.MapError).Code != map1.ErrCanonHdr {
+		t.Errorf("over limit, bad header: %v", err)
+	}
+}
+
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += n
+	return n, err
+}
+
+// TestMergeSorted checks the linear merge of Sorted maps against the
+// general path.
+func TestMergeSorted(t *testing.T) {
+	// A copy with every Sorted mark cleared forces the general path.
+	var unsorted func(v map1.Value) map1.Value
+	unsorted = func(v map1.Value) map1.Value {
+		switch val := v.(type) {
+		case *map1.Map:
+			out := &map1.Map{Keys: append([]string(nil), val.Keys...)}
+			for _, item := range val.Values {
+				out.Values = append(out.Values, unsorted(item))
+			}
+			return out
+		}
+		return v
+	}
+	base := map1.NewMap(
+		map1.MapEntry{Key: "b", Value: map1.Integer(1)},
+		map1.MapEntry{Key: "d", Value: map1.NewMap(
+			map1.MapEntry{Key: "x", Value: map1.Integer(1)},
+			map1.MapEntry{Key: "z", Value: map1.Integer(2)},
+		)},
+		map1.MapEntry{Key: "f", Value: map1.List{map1.Integer(1)}},
+	)
+	overlay := map1.NewMap(
+		map1.MapEntry{Key: "a", Value: map1.Integer(9)},
+		map1.MapEntry{Key: "d", Value: map1.NewMap(map1.MapEntry{Key: "y", Value: map1.Integer(3)})},
+		map1.MapEntry{Key: "f", Value: map1.String("replaced")},
+		map1.MapEntry{Key: "g", Value: map1.Bool(true)},
+	)
+	cb, _ := map1.Canonicalize(base)
+	co, _ := map1.Canonicalize(overlay)
+
+	merged := map1.Merge(cb, co).(*map1.Map)
+	if !merged.Sorted || !map1.Equal(merged, map1.Merge(unsorted(cb), unsorted(co))) {
+		t.Errorf("sorted merge:\n%s", map1.Dump(merged))
+	}
+	if inner := merged.Values[2].(*map1.Map); !inner.Sorted || strings.Join(inner.Keys, ",") != "x,y,z" {
+		t.Errorf("nested merge: %v sorted=%v", inner.Keys, inner.Sorted)
+	}
+	if got, _ := map1.MIDFromMerged(cb, co); got != map1.MustMIDFull(merged) {
+		t.Error("MIDFromMerged disagrees with Merge")
+	}
+
+	// Layering onto an empty map reproduces the input.
+	if !map1.Equal(map1.Merge(map1.Merge(&map1.Map{Sorted: true}, cb), co), merged) {
+		t.Error("layered merge differs")
+	}
+
+	// A wrong mark or a duplicate key must not be hidden by the merge.
+	bad := []*map1.Map{
+		{Keys: []string{"b", "a"}, Values: []map1.Value{map1.Integer(1), map1.Integer(2)}, Sorted: true},
+		{Keys: []string{"a", "a"}, Values: []map1.Value{map1.Integer(1), map1.Integer(2)}, Sorted: true},
+	}
+	for _, m := range bad {
+		for _, pair := range [][2]map1.Value{{m, co}, {co, m}} {
+			_, gotErr := map1.MIDFull(map1.Merge(pair[0], pair[1]))
+			_, wantErr := map1.MIDFull(map1.Merge(unsorted(pair[0]), unsorted(pair[1])))
+			if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+				t.Errorf("%v: got %v, want %v", m.Keys, gotErr, wantErr)
+			}
+		}
+	}
+}
+
+func BenchmarkMergeSortedLayers(b *testing.B) {
+	base, _ := map1.Canonicalize(benchMap(false))
+	overlay, _ := map1.Canonicalize(benchOverlay())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v := base
+		for l := 0; l < 4; l++ {
+			v = map1.Merge(v, overlay)
+		}
+	}
+}
+
+func TestMIDFromListSlice(t *testing.T) {
+	list := make(map1.List, 30)
+	for i := range list {
+		list[i] = map1.Integer(int64(i))
+	}
+	got, err := map1.MIDFromListSlice(list, 10, 20)
+	if err != nil || got != map1.MustMIDFull(list[10:20]) {
+		t.Errorf("[10:20]: %s, %v", got, err)
+	}
+	if got == map1.MustMIDFull(list) {
+		t.Error("slice MID equals the parent's")
+	}
+	if got, _ := map1.MIDFromListSlice(list, 5, 5); got != map1.MustMIDFull(map1.List{}) {
+		t.Errorf("empty slice: %s", got)
+	}
+	if got, _ := map1.MIDFromListSlice(list, 0, len(list)); got != map1.MustMIDFull(list) {
+		t.Errorf("whole list: %s", got)
+	}
+
+	// Elements outside the range are not looked at.
+	bad := append(map1.List{map1.String("\xff")}, list...)
+	if _, err := map1.MIDFromListSlice(bad, 1, 3); err != nil {
+		t.Errorf("bad element outside range: %v", err)
+	}
+	if _, err := map1.MIDFromListSlice(bad, 0, 3); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("bad element inside range: %v", err)
+	}
+
+	for _, r := range [][2]int{{-1, 2}, {3, 2}, {0, 31}, {31, 31}} {
+		if _, err := map1.MIDFromListSlice(list, r[0], r[1]); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+			t.Errorf("%v: err = %v, want ERR_SCHEMA", r, err)
+		}
+	}
+	if _, err := map1.MIDFromListSlice(map1.EmptyMap(), 0, 0); err == nil || err.(*map1.MapError).Code != map1.ErrSchema {
+		t.Errorf("MAP root: %v", err)
+	}
+}
+
+func TestFramedCanonBytes(t *testing.T) {
+	values := []map1.Value{
+		map1.NewMap(map1.MapEntry{Key: "k", Value: map1.Integer(1)}),
+		map1.String(""),
+		map1.List{map1.Bool(true), map1.Bytes{0, 1}},
+	}
+	var stream bytes.Buffer
+	for _, v := range values {
+		if err := map1.WriteFramedCanonBytes(&stream, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The frame is the length, then the plain CANON_BYTES.
+	first := map1.MustCanonBytesFull(values[0])
+	if n := binary.BigEndian.Uint32(stream.Bytes()); int(n) != len(first) || !bytes.Equal(stream.Bytes()[4:4+n], first) {
+		t.Errorf("frame: %x", stream.Bytes()[:4+len(first)])
+	}
+
+	r := bytes.NewReader(stream.Bytes())
+	for i, want := range values {
+		got, err := map1.ReadFramedCanonBytes(r)
+		if err != nil || !map1.Equal(got, want) {
+			t.Errorf("record %d: %v, %v", i, got, err)
+		}
+		if map1.MustMIDFull(got) != map1.MustMIDFull(want) {
+			t.Errorf("record %d: MID changed", i)
+		}
+	}
+	if _, err := map1.ReadFramedCanonBytes(r); err != io.EOF {
+		t.Errorf("end of stream: %v, want io.EOF", err)
+	}
+
+	// Nothing is written for a value that does not encode.
+	var out bytes.Buffer
+	if err := map1.WriteFramedCanonBytes(&out, map1.String("\xff")); err == nil || out.Len() != 0 {
+		t.Errorf("bad value: %v, wrote %d bytes", err, out.Len())
+	}
+
+	whole := stream.Bytes()
+	for _, tc := range []struct {
+		in   []byte
+		want string
+	}{
+		{whole[:2], io.ErrUnexpectedEOF.Error()},
+		{whole[:4], io.ErrUnexpectedEOF.Error()},
+		{whole[:len(first)], io.ErrUnexpectedEOF.Error()},
+		{[]byte{0x00, 0x10, 0x00, 0x01}, map1.ErrLimitSize},
+		{append([]byte{0, 0, 0, 6}, "MAP1\x00\x05"...), map1.ErrCanonMCF},
+		{append([]byte{0, 0, 0, 6}, "MAP0\x00\x05"...), map1.ErrCanonHdr},
+	} {
+		_, err := map1.ReadFramedCanonBytes(bytes.NewReader(tc.in))
+		if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("%x: err = %v, want %s", tc.in, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+// Package bson maps BSON documents (MongoDB's wire format) onto the
+// MAP v1 canonical model, preserving the type distinctions that a JSON
+// round-trip loses.
+//
+// Type mapping:
+//
+//	string (0x02)        → STRING
+//	binary (0x05)        → BYTES (subtype discarded)
+//	int32/int64 (0x10/12) → INTEGER
+//	bool (0x08)          → BOOLEAN
+//	document (0x03)      → MAP
+//	array (0x04)         → LIST (keys must be "0", "1", …)
+//	double (0x01)        → ERR_TYPE, or INTEGER with IntegralFloatsAsInteger
+//	null (0x0A)          → ERR_TYPE
+//	ObjectId (0x07)      → ERR_TYPE, or BYTES(12) with ObjectIDAsBytes
+//	UTC datetime (0x09)  → ERR_TYPE, or INTEGER(ms) with DateAsInteger
+//	any other known type → ERR_TYPE
+//
+// Doubles follow JSON-STRICT, which rejects 1.0 as well as 1.5: every
+// double is ERR_TYPE by default, integral or not.  IntegralFloatsAsInteger
+// admits those with an exact int64 value (2.0 → INTEGER 2, -0.0 →
+// INTEGER 0); fractions, values outside int64, NaN and ±Inf stay
+// ERR_TYPE.
+//
+// Malformed BSON (bad lengths, missing terminators, unknown type bytes)
+// is ERR_CANON_MCF.  A repeated field name in one document is
+// ERR_DUP_KEY.  An unsupported element (ERR_TYPE), a misnumbered array
+// key (ERR_SCHEMA) and a duplicate are recorded and parsing goes on,
+// skipping the element, so the error reported is the highest-precedence
+// one in the whole input (§6.2) — the same deferral the JSON-STRICT
+// adapter uses.
+//
+// The parser is self-contained; the core map1 package stays free of
+// any BSON dependency.
+package bson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+)
+
+// Options enables the non-default mappings.
+type Options struct {
+	ObjectIDAsBytes bool // ObjectId → BYTES (12 bytes)
+	DateAsInteger   bool // UTC datetime → INTEGER milliseconds since epoch
+
+	// IntegralFloatsAsInteger maps a double with an exact int64 value
+	// to INTEGER.  Other doubles, NaN and ±Inf included, stay ERR_TYPE.
+	IntegralFloatsAsInteger bool
+}
+
+// MIDFromBSON computes the FULL MID of a BSON document with default options.
+func MIDFromBSON(raw []byte) (string, error) {
+	return MIDFromBSONWithOptions(raw, Options{})
+}
+
+// MIDFromBSONWithOptions computes the FULL MID of a BSON document.
+func MIDFromBSONWithOptions(raw []byte, opts Options) (string, error) {
+	v, err := ValueFromBSON(raw, opts)
+	if err != nil {
+		return "", err
+	}
+	return map1.MIDFull(v)
+}
+
+// ValueFromBSON converts a BSON document to a canonical MAP.
+func ValueFromBSON(raw []byte, opts Options) (map1.Value, error) {
+	if len(raw) > map1.MaxCanonBytes {
+		return nil, mapErr(map1.ErrLimitSize, "input exceeds MAX_CANON_BYTES")
+	}
+	p := parser{buf: raw, opts: opts}
+	v, end, err := p.document(0, 1, false)
+	if err == nil && end != len(raw) {
+		err = mapErr(map1.ErrCanonMCF, "trailing bytes after BSON document")
+	}
+	if err != nil {
+		return nil, p.report(err)
+	}
+	if len(p.soft) > 0 {
+		// Encode-time errors (invalid UTF-8, say) compete with the
+		// recorded ones; the tree is missing the skipped elements but
+		// is otherwise complete.
+		_, err := map1.CanonBytesFull(v)
+		return nil, p.report(err)
+	}
+	return v, nil
+}
+
+// BSON element type bytes.
+const (
+	typeDouble   = 0x01
+	typeString   = 0x02
+	typeDocument = 0x03
+	typeArray    = 0x04
+	typeBinary   = 0x05
+	typeObjectID = 0x07
+	typeBool     = 0x08
+	typeDate     = 0x09
+	typeNull     = 0x0A
+	typeInt32    = 0x10
+	typeInt64    = 0x12
+)
+
+// unsupported lists BSON types that are well-formed but have no
+// canonical mapping.
+var unsupported = map[byte]string{
+	typeDouble: "double", 0x06: "undefined", typeObjectID: "ObjectId",
+	typeDate: "datetime", typeNull: "null", 0x0B: "regex",
+	0x0C: "DBPointer", 0x0D: "JavaScript", 0x0E: "symbol",
+	0x0F: "JavaScript with scope", 0x11: "timestamp", 0x13: "decimal128",
+	0x7F: "max key", 0xFF: "min key",
+}
+
+type parser struct {
+	buf  []byte
+	opts Options
+	soft []*map1.MapError // recorded violations; parsing continued past them
+}
+
+func mapErr(code, msg string) error {
+	return &map1.MapError{Code: code, Msg: msg}
+}
+
+func (p *parser) record(code, msg string) {
+	p.soft = append(p.soft, &map1.MapError{Code: code, Msg: msg})
+}
+
+// report returns the highest-precedence (§6.2) of the recorded
+// violations and hard, which may be nil.  Ties go to the earliest
+// found; the recorded ones always precede hard.
+func (p *parser) report(hard error) error {
+	var best *map1.MapError
+	for _, e := range p.soft {
+		if best == nil || e.Precedence() < best.Precedence() {
+			best = e
+		}
+	}
+	if he, ok := hard.(*map1.MapError); ok && (best == nil || he.Precedence() < best.Precedence()) {
+		best = he
+	}
+	if best == nil {
+		return hard
+	}
+	return best
+}
+
+func (p *parser) int32At(off int) (int, error) {
+	if off+4 > len(p.buf) {
+		return 0, mapErr(map1.ErrCanonMCF, "truncated BSON int32")
+	}
+	return int(int32(binary.LittleEndian.Uint32(p.buf[off:]))), nil
+}
+
+// document parses a document (or array) at off and returns the offset
+// just past it.  depth is the container depth it occupies.
+func (p *parser) document(off, depth int, array bool) (map1.Value, int, error) {
+	if depth > map1.MaxDepth {
+		return nil, off, mapErr(map1.ErrLimitDepth, "exceeds MAX_DEPTH")
+	}
+	size, err := p.int32At(off)
+	if err != nil {
+		return nil, off, err
+	}
+	end := off + size
+	if size < 5 || end > len(p.buf) || p.buf[end-1] != 0x00 {
+		return nil, off, mapErr(map1.ErrCanonMCF, "bad BSON document length")
+	}
+	off += 4
+
+	var list map1.List
+	m := &map1.Map{}
+	seen := map[string]bool{}
+	n := 0 // elements so far, skipped ones included
+	for off < end-1 {
+		typ := p.buf[off]
+		off++
+		nul := bytes.IndexByte(p.buf[off:end], 0x00)
+		if nul < 0 {
+			return nil, off, mapErr(map1.ErrCanonMCF, "unterminated BSON field name")
+		}
+		name := string(p.buf[off : off+nul])
+		off += nul + 1
+
+		v, next, err := p.element(typ, off, end-1, depth)
+		if err != nil {
+			return nil, off, err
+		}
+		off = next
+		n++
+
+		if array {
+			if name != strconv.Itoa(n-1) {
+				p.record(map1.ErrSchema, "BSON array keys must be sequential indexes")
+			}
+			if v != nil {
+				list = append(list, v)
+			}
+			continue
+		}
+		if seen[name] {
+			p.record(map1.ErrDupKey, "duplicate field name in BSON document")
+			continue
+		}
+		seen[name] = true
+		if v != nil {
+			m.Keys = append(m.Keys, name)
+			m.Values = append(m.Values, v)
+		}
+	}
+	if off != end-1 {
+		return nil, off, mapErr(map1.ErrCanonMCF, "BSON element overruns document")
+	}
+	if array {
+		if list == nil {
+			list = map1.List{}
+		}
+		return list, end, nil
+	}
+	return m, end, nil
+}
+
+// element parses one value of type typ at off; limit is the offset of
+// the enclosing document's terminator.  An element with no canonical
+// mapping is recorded as ERR_TYPE and skipped: the value is nil and the
+// offset is past it.
+func (p *parser) element(typ byte, off, limit, depth int) (map1.Value, int, error) {
+	need := func(n int) error {
+		if n < 0 || off+n > limit {
+			return mapErr(map1.ErrCanonMCF, "truncated BSON element")
+		}
+		return nil
+	}
+
+	switch typ {
+	case typeString:
+		n, err := p.int32At(off)
+		if err != nil {
+			return nil, off, err
+		}
+		if err := need(4 + n); err != nil || n < 1 || p.buf[off+4+n-1] != 0x00 {
+			return nil, off, mapErr(map1.ErrCanonMCF, "bad BSON string")
+		}
+		return map1.String(p.buf[off+4 : off+4+n-1]), off + 4 + n, nil
+
+	case typeBinary:
+		n, err := p.int32At(off)
+		if err != nil {
+			return nil, off, err
+		}
+		if err := need(5 + n); err != nil || n < 0 {
+			return nil, off, mapErr(map1.ErrCanonMCF, "bad BSON binary")
+		}
+		data := append([]byte{}, p.buf[off+5:off+5+n]...)
+		return map1.Bytes(data), off + 5 + n, nil
+
+	case typeInt32:
+		if err := need(4); err != nil {
+			return nil, off, err
+		}
+		return map1.Integer(int32(binary.LittleEndian.Uint32(p.buf[off:]))), off + 4, nil
+
+	case typeInt64:
+		if err := need(8); err != nil {
+			return nil, off, err
+		}
+		return map1.Integer(int64(binary.LittleEndian.Uint64(p.buf[off:]))), off + 8, nil
+
+	case typeBool:
+		if err := need(1); err != nil {
+			return nil, off, err
+		}
+		switch p.buf[off] {
+		case 0x00:
+			return map1.Bool(false), off + 1, nil
+		case 0x01:
+			return map1.Bool(true), off + 1, nil
+		}
+		return nil, off, mapErr(map1.ErrCanonMCF, "invalid BSON boolean")
+
+	case typeDocument, typeArray:
+		return p.document(off, depth+1, typ == typeArray)
+
+	case typeObjectID:
+		if p.opts.ObjectIDAsBytes {
+			if err := need(12); err != nil {
+				return nil, off, err
+			}
+			return map1.Bytes(append([]byte{}, p.buf[off:off+12]...)), off + 12, nil
+		}
+
+	case typeDouble:
+		if p.opts.IntegralFloatsAsInteger {
+			if err := need(8); err != nil {
+				return nil, off, err
+			}
+			f := math.Float64frombits(binary.LittleEndian.Uint64(p.buf[off:]))
+			// 2^63 is exact in float64; anything from it up overflows.
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= 1<<63 {
+				p.record(map1.ErrType, fmt.Sprintf("BSON double %v has no exact int64 value", f))
+				return nil, off + 8, nil
+			}
+			return map1.Integer(int64(f)), off + 8, nil
+		}
+
+	case typeDate:
+		if p.opts.DateAsInteger {
+			if err := need(8); err != nil {
+				return nil, off, err
+			}
+			return map1.Integer(int64(binary.LittleEndian.Uint64(p.buf[off:]))), off + 8, nil
+		}
+	}
+
+	if name, ok := unsupported[typ]; ok {
+		next, err := p.skip(typ, off, limit)
+		if err != nil {
+			return nil, off, err
+		}
+		p.record(map1.ErrType, "BSON "+name+" not supported")
+		return nil, next, nil
+	}
+	return nil, off, mapErr(map1.ErrCanonMCF, "unknown BSON element type")
+}
+
+// skip returns the offset just past an unsupported element of type typ
+// at off, checking only that it fits before limit.
+func (p *parser) skip(typ byte, off, limit int) (int, error) {
+	n := 0
+	switch typ {
+	case typeDouble, typeDate, 0x11: // timestamp
+		n = 8
+	case typeObjectID:
+		n = 12
+	case 0x13: // decimal128
+		n = 16
+	case 0x0D, 0x0E, 0x0C: // JavaScript, symbol: a string; DBPointer: a string and an ObjectId
+		l, err := p.int32At(off)
+		if err != nil {
+			return off, err
+		}
+		n = 4 + l
+		if typ == 0x0C {
+			n += 12
+		}
+	case 0x0F: // JavaScript with scope: led by its total length
+		l, err := p.int32At(off)
+		if err != nil {
+			return off, err
+		}
+		n = l
+	case 0x0B: // regex: pattern and options, both NUL-terminated
+		for i := 0; i < 2; i++ {
+			if off+n > limit {
+				break
+			}
+			nul := bytes.IndexByte(p.buf[off+n:limit], 0x00)
+			if nul < 0 {
+				return off, mapErr(map1.ErrCanonMCF, "unterminated BSON regex")
+			}
+			n += nul + 1
+		}
+	}
+	if n < 0 || off+n > limit {
+		return off, mapErr(map1.ErrCanonMCF, "truncated BSON element")
+	}
+	return off + n, nil
+}
@@ -0,0 +1,175 @@
+package bson_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+	"github.com/map-protocol/map1/implementations/go/bson"
+)
+
+// doc assembles a BSON document from pre-encoded elements.
+func doc(elems ...[]byte) []byte {
+	var body []byte
+	for _, e := range elems {
+		body = append(body, e...)
+	}
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(body)+5))
+	out = append(out, body...)
+	return append(out, 0x00)
+}
+
+func elem(typ byte, name string, payload []byte) []byte {
+	out := append([]byte{typ}, name...)
+	out = append(out, 0x00)
+	return append(out, payload...)
+}
+
+func str(s string) []byte {
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(s)+1))
+	return append(append(out, s...), 0x00)
+}
+
+func i64(n int64) []byte { return binary.LittleEndian.AppendUint64(nil, uint64(n)) }
+
+func TestMIDFromBSON(t *testing.T) {
+	raw := doc(
+		elem(0x02, "name", str("x")),
+		elem(0x10, "n", binary.LittleEndian.AppendUint32(nil, 7)),
+		elem(0x08, "ok", []byte{1}),
+		elem(0x05, "bin", append(binary.LittleEndian.AppendUint32(nil, 2), 0x00, 0xAB, 0xCD)),
+		elem(0x04, "arr", doc(elem(0x12, "0", i64(-1)), elem(0x03, "1", doc()))),
+	)
+	got, err := bson.MIDFromBSON(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := map1.MIDFull(map1.NewMap(
+		map1.MapEntry{Key: "name", Value: map1.String("x")},
+		map1.MapEntry{Key: "n", Value: map1.Integer(7)},
+		map1.MapEntry{Key: "ok", Value: map1.Bool(true)},
+		map1.MapEntry{Key: "bin", Value: map1.Bytes{0xAB, 0xCD}},
+		map1.MapEntry{Key: "arr", Value: map1.List{map1.Integer(-1), map1.EmptyMap()}},
+	))
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestBSONErrors(t *testing.T) {
+	oid := make([]byte, 12)
+	cases := map[string]struct {
+		raw  []byte
+		code string
+	}{
+		"double":    {doc(elem(0x01, "d", i64(0))), map1.ErrType},
+		"null":      {doc(elem(0x0A, "z", nil)), map1.ErrType},
+		"objectid":  {doc(elem(0x07, "_id", oid)), map1.ErrType},
+		"date":      {doc(elem(0x09, "t", i64(0))), map1.ErrType},
+		"dup":       {doc(elem(0x02, "a", str("1")), elem(0x02, "a", str("2"))), map1.ErrDupKey},
+		"truncated": {doc(elem(0x12, "n", []byte{1, 2}))[:8], map1.ErrCanonMCF},
+		"unknown":   {doc(elem(0x42, "q", nil)), map1.ErrCanonMCF},
+		"arraykeys": {doc(elem(0x04, "a", doc(elem(0x10, "1", []byte{0, 0, 0, 0})))), map1.ErrSchema},
+		"trailing":  {append(doc(), 0x00), map1.ErrCanonMCF},
+	}
+	for name, c := range cases {
+		if _, err := bson.MIDFromBSON(c.raw); err == nil || err.(*map1.MapError).Code != c.code {
+			t.Errorf("%s: expected %s, got %v", name, c.code, err)
+		}
+	}
+
+	opts := bson.Options{ObjectIDAsBytes: true, DateAsInteger: true}
+	got, err := bson.MIDFromBSONWithOptions(doc(elem(0x07, "_id", oid), elem(0x09, "t", i64(42))), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := map1.MIDFull(map1.NewMap(
+		map1.MapEntry{Key: "_id", Value: map1.Bytes(oid)},
+		map1.MapEntry{Key: "t", Value: map1.Integer(42)},
+	))
+	if got != want {
+		t.Errorf("options: got %s, want %s", got, want)
+	}
+}
+
+func f64(f float64) []byte { return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)) }
+
+func TestBSONDoubles(t *testing.T) {
+	integral := bson.Options{IntegralFloatsAsInteger: true}
+	for _, c := range []struct {
+		f    float64
+		want int64
+		ok   bool // accepted with IntegralFloatsAsInteger
+	}{
+		{2.0, 2, true},
+		{-0.0, 0, true},
+		{-4096, -4096, true},
+		{math.MinInt64, math.MinInt64, true},
+		{1 << 62, 1 << 62, true},
+		{2.5, 0, false},
+		{1 << 63, 0, false},
+		{-(1 << 63) * 2, 0, false},
+		{1e300, 0, false},
+		{math.SmallestNonzeroFloat64, 0, false},
+		{math.NaN(), 0, false},
+		{math.Inf(1), 0, false},
+		{math.Inf(-1), 0, false},
+	} {
+		raw := doc(elem(0x01, "d", f64(c.f)))
+
+		// JSON-STRICT alignment: every double is ERR_TYPE by default.
+		if _, err := bson.MIDFromBSON(raw); err == nil || err.(*map1.MapError).Code != map1.ErrType {
+			t.Errorf("%v default: err = %v, want %s", c.f, err, map1.ErrType)
+		}
+
+		got, err := bson.MIDFromBSONWithOptions(raw, integral)
+		if !c.ok {
+			if err == nil || err.(*map1.MapError).Code != map1.ErrType {
+				t.Errorf("%v integral: err = %v, want %s", c.f, err, map1.ErrType)
+			}
+			continue
+		}
+		want, _ := map1.MIDFull(map1.NewMap(map1.MapEntry{Key: "d", Value: map1.Integer(c.want)}))
+		if err != nil || got != want {
+			t.Errorf("%v integral: %s, %v, want INTEGER %d", c.f, got, err, c.want)
+		}
+	}
+
+	truncated := doc(elem(0x01, "d", []byte{0, 0, 0}))[:12]
+	if _, err := bson.MIDFromBSONWithOptions(truncated, integral); err == nil || err.(*map1.MapError).Code != map1.ErrCanonMCF {
+		t.Errorf("truncated double: err = %v, want %s", err, map1.ErrCanonMCF)
+	}
+}
+
+// TestBSONPrecedence checks an unsupported element does not hide a
+// later violation that outranks ERR_TYPE (§6.2).
+func TestBSONPrecedence(t *testing.T) {
+	oid := make([]byte, 12)
+	dbl := elem(0x01, "d", f64(1.5))
+	cases := map[string]struct {
+		raw  []byte
+		code string
+	}{
+		"double then unknown":  {doc(dbl, elem(0x42, "q", nil)), map1.ErrCanonMCF},
+		"null then truncated":  {doc(elem(0x0A, "z", nil), elem(0x12, "n", []byte{1, 2})), map1.ErrCanonMCF},
+		"objectid then trail":  {append(doc(elem(0x07, "_id", oid)), 0x00), map1.ErrCanonMCF},
+		"regex then bad bool":  {doc(elem(0x0B, "r", []byte("a\x00i\x00")), elem(0x08, "b", []byte{2})), map1.ErrCanonMCF},
+		"double then keys":     {doc(dbl, elem(0x04, "a", doc(elem(0x10, "1", []byte{0, 0, 0, 0})))), map1.ErrSchema},
+		"dup then date":        {doc(elem(0x02, "a", str("1")), elem(0x02, "a", str("2")), elem(0x09, "t", i64(0))), map1.ErrType},
+		"bad utf8 then double": {doc(elem(0x02, "s", str("\xff")), dbl), map1.ErrType},
+		"skipped dup":          {doc(dbl, elem(0x01, "d", f64(2))), map1.ErrType},
+		"truncated double":     {doc(elem(0x01, "d", []byte{0, 0, 0})), map1.ErrCanonMCF},
+	}
+	for name, c := range cases {
+		if _, err := bson.MIDFromBSON(c.raw); err == nil || err.(*map1.MapError).Code != c.code {
+			t.Errorf("%s: expected %s, got %v", name, c.code, err)
+		}
+	}
+
+	// Skipped array elements still count toward the index sequence.
+	arr := doc(elem(0x04, "a", doc(elem(0x0A, "0", nil), elem(0x10, "1", []byte{0, 0, 0, 0}))))
+	if _, err := bson.MIDFromBSON(arr); err == nil || err.(*map1.MapError).Code != map1.ErrType {
+		t.Errorf("array: expected %s, got %v", map1.ErrType, err)
+	}
+}
@@ -0,0 +1,145 @@
+package map1
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+)
+
+// MIDCache is a bounded LRU map from an input key to its MID, guarded
+// by a single mutex.  Typical keys are raw JSON bodies (see
+// MIDFullJSON) so repeated identical inputs skip parse, encode and hash.
+// Errors are never cached.
+//
+// Under heavy concurrent load the single lock contends; use
+// ShardedMIDCache there.
+type MIDCache struct {
+	mu      sync.Mutex
+	max     int
+	lru     *list.List // front = most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key string
+	mid string
+}
+
+// NewMIDCache returns a cache holding at most maxEntries MIDs.
+// maxEntries <= 0 means unbounded.
+func NewMIDCache(maxEntries int) *MIDCache {
+	return &MIDCache{
+		max:     maxEntries,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached MID for key.
+func (c *MIDCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry).mid, true
+}
+
+// Put records mid under key, evicting the least recently used entry
+// if the cache is full.
+func (c *MIDCache) Put(key, mid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).mid = mid
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, mid: mid})
+	if c.max > 0 && c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached MIDs.
+func (c *MIDCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// MIDFullJSON is MIDFullJSON keyed by the raw input bytes.
+func (c *MIDCache) MIDFullJSON(raw []byte) (string, error) {
+	return cachedMIDFullJSON(c, raw)
+}
+
+// ShardedMIDCache spreads keys over independent MIDCache shards, each
+// with its own lock, so concurrent callers rarely contend.
+type ShardedMIDCache struct {
+	shards []*MIDCache
+}
+
+// NewShardedMIDCache returns a cache of shards shards, each bounded to
+// maxEntriesPerShard (<= 0 means unbounded).  shards < 1 is treated as 1.
+func NewShardedMIDCache(shards, maxEntriesPerShard int) *ShardedMIDCache {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &ShardedMIDCache{shards: make([]*MIDCache, shards)}
+	for i := range s.shards {
+		s.shards[i] = NewMIDCache(maxEntriesPerShard)
+	}
+	return s
+}
+
+func (s *ShardedMIDCache) shard(key string) *MIDCache {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// Get returns the cached MID for key.
+func (s *ShardedMIDCache) Get(key string) (string, bool) {
+	return s.shard(key).Get(key)
+}
+
+// Put records mid under key in its shard.
+func (s *ShardedMIDCache) Put(key, mid string) {
+	s.shard(key).Put(key, mid)
+}
+
+// Len returns the number of cached MIDs across all shards.
+func (s *ShardedMIDCache) Len() int {
+	n := 0
+	for _, c := range s.shards {
+		n += c.Len()
+	}
+	return n
+}
+
+// MIDFullJSON is MIDFullJSON keyed by the raw input bytes.
+func (s *ShardedMIDCache) MIDFullJSON(raw []byte) (string, error) {
+	return cachedMIDFullJSON(s, raw)
+}
+
+type midStore interface {
+	Get(key string) (string, bool)
+	Put(key, mid string)
+}
+
+func cachedMIDFullJSON(c midStore, raw []byte) (string, error) {
+	key := string(raw)
+	if mid, ok := c.Get(key); ok {
+		return mid, nil
+	}
+	mid, err := MIDFullJSON(raw)
+	if err != nil {
+		return "", err
+	}
+	c.Put(key, mid)
+	return mid, nil
+}
@@ -0,0 +1,51 @@
+package map1
+
+import (
+	"bytes"
+	"io"
+)
+
+// MIDFromCanonBytesAt reads exactly length bytes at offset in r,
+// validates them as one CANON_BYTES record and returns its MID, with
+// the same results as MIDFromCanonBytes on those bytes.  It suits
+// files of concatenated records with an external offset index: only
+// the record is read, straight into a buffer of its own size.
+//
+// A length over MAX_CANON_BYTES is ERR_LIMIT_SIZE without reading the
+// record — only its header, which outranks the limit (§6.2).  A
+// negative length is ERR_SCHEMA.  A record running past the end of r
+// is io.ErrUnexpectedEOF; other read errors are returned as is.
+func MIDFromCanonBytesAt(r io.ReaderAt, offset int64, length int) (string, error) {
+	if length < 0 {
+		return "", newErr(ErrSchema, "negative record length")
+	}
+	if length > MaxCanonBytes {
+		hdr := make([]byte, len(canonHdr))
+		if err := readFullAt(r, hdr, offset); err != nil {
+			return "", err
+		}
+		if !bytes.Equal(hdr, canonHdr) {
+			return "", newErr(ErrCanonHdr, "bad CANON_HDR")
+		}
+		return "", newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	canon := make([]byte, length)
+	if err := readFullAt(r, canon, offset); err != nil {
+		return "", err
+	}
+	return MIDFromCanonBytesFast(canon)
+}
+
+// readFullAt fills buf from r at offset.  ReadAt may return io.EOF
+// alongside a full read; a short read at end of input is
+// io.ErrUnexpectedEOF.
+func readFullAt(r io.ReaderAt, buf []byte, offset int64) error {
+	n, err := r.ReadAt(buf, offset)
+	if n == len(buf) {
+		return nil
+	}
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package map1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// canonDiffHexMax caps how many MCF bytes CanonDiff shows per side.
+const canonDiffHexMax = 32
+
+// CanonDiff describes where two CANON_BYTES blobs first diverge, for
+// debugging storage or serialization mismatches.  Both must be valid
+// CANON_BYTES; otherwise the error is that of DecodeCanonBytes, for a
+// before b.  Identical inputs give "".
+//
+// The description names the first byte offset that differs and the
+// first differing node by JSON Pointer (RFC 6901), in canonical order:
+// a value of another type or content, a LIST of another length, or a
+// MAP key present on only one side.  The MCF bytes of the differing
+// node on each side follow, truncated.  Like Dump's, the format is for
+// humans and may change.
+func CanonDiff(a, b []byte) (string, error) {
+	va, err := DecodeCanonBytes(a)
+	if err != nil {
+		return "", err
+	}
+	vb, err := DecodeCanonBytes(b)
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(a, b) {
+		return "", nil
+	}
+
+	off := 0
+	for off < len(a) && off < len(b) && a[off] == b[off] {
+		off++
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "first differing byte at offset %d\n", off)
+	diffValues(&sb, "", va, vb)
+	return sb.String(), nil
+}
+
+// diffValues writes the first difference between a and b, which are
+// known to differ, found under pointer path.
+func diffValues(sb *strings.Builder, path string, a, b Value) {
+	switch x := a.(type) {
+	case List:
+		if y, ok := b.(List); ok {
+			for i := 0; i < len(x) && i < len(y); i++ {
+				if !Equal(x[i], y[i]) {
+					diffValues(sb, path+"/"+strconv.Itoa(i), x[i], y[i])
+					return
+				}
+			}
+			fmt.Fprintf(sb, "%s: LIST of %d items in a, %d in b\n", diffPath(path), len(x), len(y))
+			if len(x) > len(y) {
+				diffNode(sb, x[len(y)], nil)
+			} else {
+				diffNode(sb, nil, y[len(x)])
+			}
+			return
+		}
+	case *Map:
+		if y, ok := b.(*Map); ok {
+			// Decoded maps are in canonical key order.
+			i, j := 0, 0
+			for i < len(x.Keys) || j < len(y.Keys) {
+				c := -1
+				switch {
+				case i >= len(x.Keys):
+					c = 1
+				case j < len(y.Keys):
+					c = CompareKeys(x.Keys[i], y.Keys[j])
+				}
+				switch {
+				case c < 0:
+					fmt.Fprintf(sb, "%s: key only in a\n", diffPath(path+"/"+escapePointerToken(x.Keys[i])))
+					diffNode(sb, x.Values[i], nil)
+					return
+				case c > 0:
+					fmt.Fprintf(sb, "%s: key only in b\n", diffPath(path+"/"+escapePointerToken(y.Keys[j])))
+					diffNode(sb, nil, y.Values[j])
+					return
+				}
+				if !Equal(x.Values[i], y.Values[j]) {
+					diffValues(sb, path+"/"+escapePointerToken(x.Keys[i]), x.Values[i], y.Values[j])
+					return
+				}
+				i++
+				j++
+			}
+		}
+	}
+	fmt.Fprintf(sb, "%s: %s in a, %s in b\n", diffPath(path), diffSummary(a), diffSummary(b))
+	diffNode(sb, a, b)
+}
+
+// diffNode writes the MCF bytes of each side's differing node; nil
+// means the side has no such node.
+func diffNode(sb *strings.Builder, a, b Value) {
+	for _, side := range []struct {
+		name string
+		v    Value
+	}{{"a", a}, {"b", b}} {
+		if side.v == nil {
+			fmt.Fprintf(sb, "  %s: (absent)\n", side.name)
+			continue
+		}
+		mcf, _ := MCFBytes(side.v)
+		more := ""
+		if len(mcf) > canonDiffHexMax {
+			more = fmt.Sprintf(" … (%d bytes)", len(mcf))
+			mcf = mcf[:canonDiffHexMax]
+		}
+		fmt.Fprintf(sb, "  %s: %s%s\n", side.name, hex.EncodeToString(mcf), more)
+	}
+}
+
+// diffSummary is a one-line Dump of v with containers collapsed.
+func diffSummary(v Value) string {
+	return strings.TrimSuffix(DumpN(v, 0, 0), "\n")
+}
+
+func diffPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
@@ -0,0 +1,156 @@
+package map1
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+// Canonicalize returns a deep copy of v in canonical form: every MAP
+// has its keys in canonical order (§3.5) and is marked Sorted.  v is
+// validated first, so the result always encodes cleanly and
+// Canonicalize(v) is Equal to DecodeCanonBytes(CanonBytesFull(v)).
+func Canonicalize(v Value) (Value, error) {
+	if _, err := CanonBytesFromValue(v); err != nil {
+		return nil, err
+	}
+	return canonicalCopy(v), nil
+}
+
+func canonicalCopy(v Value) Value {
+	switch val := v.(type) {
+	case Bytes:
+		return append(Bytes{}, val...)
+	case List:
+		out := make(List, len(val))
+		for i, item := range val {
+			out[i] = canonicalCopy(item)
+		}
+		return out
+	case *Map:
+		order := make([]int, len(val.Keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return val.Keys[order[i]] < val.Keys[order[j]] })
+		out := &Map{
+			Keys:   make([]string, len(order)),
+			Values: make([]Value, len(order)),
+			Sorted: true,
+		}
+		for i, j := range order {
+			out.Keys[i] = val.Keys[j]
+			out.Values[i] = canonicalCopy(val.Values[j])
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// Equal reports whether a and b are the same canonical-model value:
+// same types throughout, and MAPs with the same key/value pairs in any
+// order.  A nil Bytes equals an empty Bytes.  Equal values have equal
+// MIDs (when valid).
+func Equal(a, b Value) bool {
+	switch x := a.(type) {
+	case String:
+		y, ok := b.(String)
+		return ok && x == y
+	case Bytes:
+		y, ok := b.(Bytes)
+		return ok && bytes.Equal(x, y)
+	case Bool:
+		y, ok := b.(Bool)
+		return ok && x == y
+	case Integer:
+		y, ok := b.(Integer)
+		return ok && x == y
+	case Null:
+		_, ok := b.(Null)
+		return ok
+	case List:
+		y, ok := b.(List)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case *Map:
+		y, ok := b.(*Map)
+		if !ok || len(x.Keys) != len(y.Keys) {
+			return false
+		}
+		for i, k := range x.Keys {
+			yv := mapGet(y, k)
+			if yv == nil || !Equal(x.Values[i], yv) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// readerChunk is the comparison granularity of CanonBytesReaderEqual.
+const readerChunk = 32 << 10
+
+// CanonBytesReaderEqual compares two CANON_BYTES streams chunk by chunk
+// and reports whether they are byte-identical, returning false at the
+// first difference without reading further.  Each stream's header is
+// checked (ERR_CANON_HDR) but the MCF body is not decoded.  Reading
+// past MAX_CANON_BYTES on both sides is ERR_LIMIT_SIZE.  Read errors
+// other than EOF are returned as is.
+func CanonBytesReaderEqual(a, b io.Reader) (bool, error) {
+	for _, r := range []io.Reader{a, b} {
+		var hdr [5]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return false, err
+		} else if !bytes.Equal(hdr[:], canonHdr) {
+			return false, newErr(ErrCanonHdr, "bad CANON_HDR")
+		}
+	}
+	bufA := make([]byte, readerChunk)
+	bufB := make([]byte, readerChunk)
+	total := len(canonHdr)
+	for {
+		na, errA := io.ReadFull(a, bufA)
+		nb, errB := io.ReadFull(b, bufB)
+		for _, err := range []error{errA, errB} {
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return false, err
+			}
+		}
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		total += na
+		if total > MaxCanonBytes {
+			return false, newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+		}
+		if errA != nil {
+			// Short read on both sides with equal content: both ended.
+			return true, nil
+		}
+	}
+}
+
+// ValueMatchesCanon reports whether v encodes to exactly canon.  canon
+// is validated first, without decoding it, and its error returned if
+// it is malformed; then v is encoded, and its error returned if it
+// fails.
+func ValueMatchesCanon(v Value, canon []byte) (bool, error) {
+	if err := scanCanonBytes(canon); err != nil {
+		return false, err
+	}
+	got, err := CanonBytesFromValue(v)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(got, canon), nil
+}
@@ -0,0 +1,51 @@
+package map1
+
+// Chain is a hash chain over an ordered sequence of values, for
+// append-only logs: each entry's chain MID commits to the entry and to
+// every entry before it, so reordering, removing or altering any of
+// them changes every later chain MID.
+//
+// The rule, for entries v1, v2, ... in order:
+//
+//	chain_0 = ""                                   (empty chain)
+//	chain_n = MID(LIST[ STRING(chain_{n-1}), v_n ])
+//
+// where MID is the FULL MID and chain_{n-1} is the previous chain MID
+// as a STRING ("map1:" + 64 hex digits).  Any MAP v1.1 implementation
+// can recompute it.  Because v_n sits one level inside a LIST, an entry
+// may nest at most MAX_DEPTH-1 containers deep, and its encoding plus
+// about 80 bytes must fit in MAX_CANON_BYTES.
+//
+// The zero value is an empty chain.  A Chain is not safe for concurrent
+// use.
+type Chain struct {
+	head string
+	n    int
+}
+
+// Append adds v to the chain and returns v's own MID and the new chain
+// MID.  If v cannot be hashed the chain is left unchanged.
+func (c *Chain) Append(v Value) (entryMID, chainMID string, err error) {
+	entryMID, err = MIDFromValue(v)
+	if err != nil {
+		return "", "", err
+	}
+	chainMID, err = MIDFromValue(List{String(c.head), v})
+	if err != nil {
+		return "", "", err
+	}
+	c.head = chainMID
+	c.n++
+	return entryMID, chainMID, nil
+}
+
+// Head returns the chain MID of the last entry, or "" for an empty
+// chain.
+func (c *Chain) Head() string {
+	return c.head
+}
+
+// Len returns the number of entries appended.
+func (c *Chain) Len() int {
+	return c.n
+}
@@ -0,0 +1,50 @@
+package map1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Checksummed CANON_BYTES, for storage integrity:
+//
+//	CANON_BYTES || u32be(CRC-32C(CANON_BYTES))
+//
+// The checksum is a storage convention only, NOT part of MAP v1.1: the
+// MID is always over the checksum-free CANON_BYTES.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+const checksumLen = 4
+
+// CanonBytesChecksummed returns the CANON_BYTES of v followed by their
+// 4-byte CRC-32C (Castagnoli), big-endian.
+func CanonBytesChecksummed(v Value) ([]byte, error) {
+	canon, err := CanonBytesFromValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return binary.BigEndian.AppendUint32(canon, crc32.Checksum(canon, castagnoli)), nil
+}
+
+// MIDFromChecksummedCanonBytes verifies the trailing CRC-32C of b, as
+// written by CanonBytesChecksummed, then validates the CANON_BYTES it
+// covers and returns their MID, the same MID MIDFromCanonBytes gives
+// for them.  A missing or mismatched checksum is ERR_CANON_MCF, found
+// before anything is decoded; the header and size checks still come
+// first, as in MIDFromCanonBytes.
+func MIDFromChecksummedCanonBytes(b []byte) (string, error) {
+	if !bytes.HasPrefix(b, canonHdr) {
+		return "", newErr(ErrCanonHdr, "bad CANON_HDR")
+	}
+	if len(b) > MaxCanonBytes+checksumLen {
+		return "", newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	if len(b) < len(canonHdr)+checksumLen {
+		return "", newErr(ErrCanonMCF, "missing checksum")
+	}
+	canon, sum := b[:len(b)-checksumLen], b[len(b)-checksumLen:]
+	if crc32.Checksum(canon, castagnoli) != binary.BigEndian.Uint32(sum) {
+		return "", newErr(ErrCanonMCF, "checksum mismatch")
+	}
+	return MIDFromCanonBytes(canon)
+}
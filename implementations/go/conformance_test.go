@@ -2,37 +2,24 @@ package map1_test
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
 
 	map1 "github.com/map-protocol/map1/implementations/go"
+	"github.com/map-protocol/map1/implementations/go/bson"
+	"github.com/map-protocol/map1/implementations/go/map1test"
 )
 
-type vectorEntry struct {
-	TestID   string   `json:"test_id"`
-	Mode     string   `json:"mode"`
-	InputB64 string   `json:"input_b64"`
-	Pointers []string `json:"pointers"`
-}
-
-type vectorsFile struct {
-	Meta    json.RawMessage `json:"meta"`
-	Vectors []vectorEntry   `json:"vectors"`
-}
-
-type expectedFile struct {
-	Meta     json.RawMessage        `json:"meta"`
-	Expected map[string]expectedVal `json:"expected"`
-}
-
-type expectedVal struct {
-	MID string `json:"mid,omitempty"`
-	Err string `json:"err,omitempty"`
-}
+type (
+	vectorEntry = map1test.VectorEntry
+	expectedVal = map1test.ExpectedVal
+)
 
 func findVectorsDir() string {
 	// Try relative to this test file.
@@ -46,88 +33,71 @@ func findVectorsDir() string {
 		candidates = append([]string{d}, candidates...)
 	}
 	for _, d := range candidates {
-		if _, err := os.Stat(filepath.Join(d, "conformance_vectors_v11.json")); err == nil {
+		if m, _ := filepath.Glob(filepath.Join(d, map1test.VectorsGlob)); len(m) > 0 {
 			return d
 		}
 	}
 	return ""
 }
 
+// modeRunners maps each vector mode to the API it exercises.  A new
+// adapter's vectors need an entry here; vectors with any other mode
+// fail the run.
+var modeRunners = map[string]func(raw []byte, pointers []string) (string, error){
+	"json_strict_full": func(raw []byte, _ []string) (string, error) { return map1.MIDFullJSON(raw) },
+	"json_strict_bind": map1.MIDBindJSON,
+	"canon_bytes":      func(raw []byte, _ []string) (string, error) { return map1.MIDFromCanonBytes(raw) },
+	"xml_full":         func(raw []byte, _ []string) (string, error) { return map1.MIDFullXML(raw) },
+	"bson_full":        func(raw []byte, _ []string) (string, error) { return bson.MIDFromBSON(raw) },
+}
+
 func runVector(vec vectorEntry) (mid string, errCode string) {
 	raw, err := base64.StdEncoding.DecodeString(vec.InputB64)
 	if err != nil {
 		return "", "BASE64_DECODE_ERROR"
 	}
-
-	switch vec.Mode {
-	case "json_strict_full":
-		result, e := map1.MIDFullJSON(raw)
-		if e != nil {
-			if me, ok := e.(*map1.MapError); ok {
-				return "", me.Code
-			}
-			return "", "UNKNOWN_ERROR"
-		}
-		return result, ""
-
-	case "json_strict_bind":
-		result, e := map1.MIDBindJSON(raw, vec.Pointers)
-		if e != nil {
-			if me, ok := e.(*map1.MapError); ok {
-				return "", me.Code
-			}
-			return "", "UNKNOWN_ERROR"
-		}
-		return result, ""
-
-	case "canon_bytes":
-		result, e := map1.MIDFromCanonBytes(raw)
-		if e != nil {
-			if me, ok := e.(*map1.MapError); ok {
-				return "", me.Code
-			}
-			return "", "UNKNOWN_ERROR"
-		}
-		return result, ""
-
-	default:
+	run, ok := modeRunners[vec.Mode]
+	if !ok {
 		return "", "UNKNOWN_MODE"
 	}
+	result, e := run(raw, vec.Pointers)
+	if e != nil {
+		if me, ok := e.(*map1.MapError); ok {
+			return "", me.Code
+		}
+		return "", "UNKNOWN_ERROR"
+	}
+	return result, ""
 }
 
-func TestConformance(t *testing.T) {
+// loadVectors loads and merges every vectors file in the conformance
+// directory, failing on a mode runVector cannot dispatch.
+func loadVectors(t *testing.T) ([]vectorEntry, map[string]expectedVal) {
+	t.Helper()
 	dir := findVectorsDir()
 	if dir == "" {
 		t.Fatal("Cannot find conformance vectors. Set MAP1_VECTORS_DIR.")
 	}
-
-	vecData, err := os.ReadFile(filepath.Join(dir, "conformance_vectors_v11.json"))
+	vecs, expected, err := map1test.LoadDir(dir)
 	if err != nil {
-		t.Fatalf("reading vectors: %v", err)
+		t.Fatalf("loading vectors: %v", err)
 	}
-	expData, err := os.ReadFile(filepath.Join(dir, "conformance_expected_v11.json"))
-	if err != nil {
-		t.Fatalf("reading expected: %v", err)
+	for _, vec := range vecs {
+		if _, ok := modeRunners[vec.Mode]; !ok {
+			t.Fatalf("%s: %s: unknown mode %q (add a runner to modeRunners)", vec.Source, vec.TestID, vec.Mode)
+		}
 	}
+	return vecs, expected
+}
 
-	var vf vectorsFile
-	if err := json.Unmarshal(vecData, &vf); err != nil {
-		t.Fatalf("parsing vectors: %v", err)
-	}
-	var ef expectedFile
-	if err := json.Unmarshal(expData, &ef); err != nil {
-		t.Fatalf("parsing expected: %v", err)
-	}
+func TestConformance(t *testing.T) {
+	vecs, expected := loadVectors(t)
 
 	passed := 0
-	total := len(vf.Vectors)
+	total := len(vecs)
 
-	for _, vec := range vf.Vectors {
-		exp, ok := ef.Expected[vec.TestID]
-		if !ok {
-			t.Errorf("no expected value for %s", vec.TestID)
-			continue
-		}
+	for _, vec := range vecs {
+		exp := expected[vec.TestID]
 
 		t.Run(vec.TestID, func(t *testing.T) {
 			gotMID, gotErr := runVector(vec)
@@ -219,6 +189,19 @@ func TestBoolIntDistinction(t *testing.T) {
 	})
 }
 
+// TestEmptyBytesVsString verifies empty BYTES and empty STRING are
+// distinct, and that JSON "" is always STRING.
+func TestEmptyBytesVsString(t *testing.T) {
+	mb := midOf(map1.NewMap(map1.MapEntry{Key: "k", Value: map1.Bytes(nil)}))
+	ms := midOf(map1.NewMap(map1.MapEntry{Key: "k", Value: map1.String("")}))
+	if mb == ms {
+		t.Error("BYTES \"\" and STRING \"\" should have different MIDs")
+	}
+	if mj, _ := map1.MIDFullJSON([]byte(`{"k":""}`)); mj != ms {
+		t.Errorf("JSON \"\" should be STRING: got %s, want %s", mj, ms)
+	}
+}
+
 // TestMIDFromCanonBytesRoundtrip encodes and validates.
 func TestMIDFromCanonBytesRoundtrip(t *testing.T) {
 	m := map1.NewMap(
@@ -243,26 +226,119 @@ func TestMIDFromCanonBytesRoundtrip(t *testing.T) {
 	}
 }
 
-func TestConformanceSummary(t *testing.T) {
-	dir := findVectorsDir()
-	if dir == "" {
-		t.Skip("Cannot find conformance vectors")
+// supplementaryVectors pins Go-side behavior for inputs the normative
+// suite does not cover.  They use the same modes as the shared vectors
+// but are NOT part of the 95 — other implementations need not carry them.
+var supplementaryVectors = []struct {
+	id       string
+	mode     string
+	input    string
+	pointers []string
+	exp      expectedVal
+}{
+	// Numeric token shapes outside the RFC 8259 integer grammar.
+	{id: "NUM_BARE_MINUS", mode: "json_strict_full", input: `{"a":-}`, exp: expectedVal{Err: map1.ErrCanonMCF}},
+	{id: "NUM_LEADING_PLUS", mode: "json_strict_full", input: `{"a":+1}`, exp: expectedVal{Err: map1.ErrCanonMCF}},
+	{id: "NUM_UNDERSCORE", mode: "json_strict_full", input: `{"a":1_0}`, exp: expectedVal{Err: map1.ErrCanonMCF}},
+	{id: "NUM_HEX", mode: "json_strict_full", input: `{"a":0x1F}`, exp: expectedVal{Err: map1.ErrCanonMCF}},
+	{id: "NUM_LEADING_ZERO", mode: "json_strict_full", input: `{"a":01}`, exp: expectedVal{Err: map1.ErrCanonMCF}},
+	{id: "NUM_NEG_ZERO", mode: "json_strict_full", input: `{"a":-0}`, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(0)}))}},
+
+	// INTEGER range boundaries (§8.2.1): the int64 extremes are accepted
+	// and one past either end is ERR_TYPE, however many digits.
+	{id: "NUM_INT64_MAX", mode: "json_strict_full", input: `{"a":9223372036854775807}`, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(math.MaxInt64)}))}},
+	{id: "NUM_INT64_MAX_PLUS_1", mode: "json_strict_full", input: `{"a":9223372036854775808}`, exp: expectedVal{Err: map1.ErrType}},
+	{id: "NUM_INT64_MIN", mode: "json_strict_full", input: `{"a":-9223372036854775808}`, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(math.MinInt64)}))}},
+	{id: "NUM_INT64_MIN_MINUS_1", mode: "json_strict_full", input: `{"a":-9223372036854775809}`, exp: expectedVal{Err: map1.ErrType}},
+	{id: "NUM_UINT64_MAX", mode: "json_strict_full", input: `{"a":18446744073709551615}`, exp: expectedVal{Err: map1.ErrType}},
+	{id: "NUM_HUGE", mode: "json_strict_full", input: `{"a":-100000000000000000000000000000}`, exp: expectedVal{Err: map1.ErrType}},
+	{id: "NUM_INT64_MAX_BIND", mode: "json_strict_bind", input: `{"a":9223372036854775807,"b":9223372036854775808}`, pointers: []string{"/a"}, exp: expectedVal{Err: map1.ErrType}},
+
+	// MAP keys must be STRING-tagged (§3.2); framing errors still win.
+	{id: "MCF_MAP_KEY_BYTES", mode: "canon_bytes", input: "MAP1\x00\x04\x00\x00\x00\x01\x02\x00\x00\x00\x01a\x05\x01", exp: expectedVal{Err: map1.ErrSchema}},
+	{id: "MCF_MAP_KEY_INTEGER", mode: "canon_bytes", input: "MAP1\x00\x04\x00\x00\x00\x01\x06\x00\x00\x00\x00\x00\x00\x00\x01\x05\x01", exp: expectedVal{Err: map1.ErrSchema}},
+	{id: "MCF_MAP_KEY_INTEGER_TRUNCATED", mode: "canon_bytes", input: "MAP1\x00\x04\x00\x00\x00\x01\x06\x00\x00\x00\x00\x00\x00\x00\x01", exp: expectedVal{Err: map1.ErrCanonMCF}},
+
+	// U+0000 is an ordinary code point: accepted as a value and as a key,
+	// ordered bytewise, and addressable by BIND.  Unescaped it is a JSON
+	// syntax error like any other control character.
+	{id: "NUL_VALUE_ONLY", mode: "json_strict_full", input: `{"a":"\u0000"}`, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "a", Value: map1.String("\x00")}))}},
+	{id: "NUL_KEY", mode: "json_strict_full", input: `{"\u0000":1}`, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "\x00", Value: map1.Integer(1)}))}},
+	{id: "NUL_KEY_AFTER_EMPTY", mode: "json_strict_full", input: `{"\u0000":1,"":2}`, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "", Value: map1.Integer(2)}, map1.MapEntry{Key: "\x00", Value: map1.Integer(1)}))}},
+	{id: "NUL_KEY_DUP", mode: "json_strict_full", input: `{"\u0000":1,"\u0000":2}`, exp: expectedVal{Err: map1.ErrDupKey}},
+	{id: "NUL_KEY_BIND", mode: "json_strict_bind", input: `{"\u0000":1,"b":2}`, pointers: []string{"/\x00"}, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "\x00", Value: map1.Integer(1)}))}},
+	{id: "NUL_RAW_IN_STRING", mode: "json_strict_full", input: "{\"a\":\"\x00\"}", exp: expectedVal{Err: map1.ErrCanonMCF}},
+
+	// Pointer spellings: only a literal repeat is a duplicate (rule b);
+	// lookalike spellings name different keys.
+	{id: "BIND_PTR_TILDE_DUP", mode: "json_strict_bind", input: `{"m~n":1}`, pointers: []string{"/m~0n", "/m~0n"}, exp: expectedVal{Err: map1.ErrSchema}},
+	{id: "BIND_PTR_TILDE_LOOKALIKE", mode: "json_strict_bind", input: `{"m~n":1,"m~0n":2}`, pointers: []string{"/m~0n", "/m~00n"}, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "m~n", Value: map1.Integer(1)}, map1.MapEntry{Key: "m~0n", Value: map1.Integer(2)}))}},
+	{id: "BIND_PTR_SLASH_VS_NESTED", mode: "json_strict_bind", input: `{"a/b":1,"a":{"b":2}}`, pointers: []string{"/a~1b", "/a/b"}, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "a/b", Value: map1.Integer(1)}, map1.MapEntry{Key: "a", Value: map1.NewMap(map1.MapEntry{Key: "b", Value: map1.Integer(2)})}))}},
+	{id: "BIND_PTR_TILDE_ORDER", mode: "json_strict_bind", input: `{"~1":1,"/0":2}`, pointers: []string{"/~01", "/~10"}, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "~1", Value: map1.Integer(1)}, map1.MapEntry{Key: "/0", Value: map1.Integer(2)}))}},
+	{id: "BIND_PTR_BARE_TILDE", mode: "json_strict_bind", input: `{"m~n":1}`, pointers: []string{"/m~n"}, exp: expectedVal{Err: map1.ErrSchema}},
+
+	// XML depth counts only elements that become MAPs: 32 nested
+	// elements are 31 MAPs under the document MAP plus a STRING leaf.
+	{id: "XML_DEPTH_LEAF_AT_LIMIT", mode: "xml_full", input: xmlNested(map1.MaxDepth, "x"), exp: expectedVal{MID: midOf(nestedMap(map1.MaxDepth, map1.String("x")))}},
+	{id: "XML_DEPTH_OVER_LIMIT", mode: "xml_full", input: xmlNested(map1.MaxDepth+1, "x"), exp: expectedVal{Err: map1.ErrLimitDepth}},
+	{id: "XML_DEPTH_ATTRS_OVER_LIMIT", mode: "xml_full", input: xmlNested(map1.MaxDepth-1, `<a k="v">x</a>`), exp: expectedVal{Err: map1.ErrLimitDepth}},
+
+	// Whitespace-only text beside attributes is indentation, not #text.
+	{id: "XML_ATTR_WHITESPACE_TEXT", mode: "xml_full", input: "<a k=\"v\">\n  </a>", exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "a", Value: map1.NewMap(map1.MapEntry{Key: "@attrs", Value: map1.NewMap(map1.MapEntry{Key: "k", Value: map1.String("v")})})}))}},
+	{id: "XML_ATTR_TEXT_VERBATIM", mode: "xml_full", input: `<a k="v"> x </a>`, exp: expectedVal{MID: midOf(map1.NewMap(map1.MapEntry{Key: "a", Value: map1.NewMap(map1.MapEntry{Key: "@attrs", Value: map1.NewMap(map1.MapEntry{Key: "k", Value: map1.String("v")})}, map1.MapEntry{Key: "#text", Value: map1.String(" x ")})}))}},
+}
+
+// xmlNested wraps inner in n nested <a> elements.
+func xmlNested(n int, inner string) string {
+	return strings.Repeat("<a>", n) + inner + strings.Repeat("</a>", n)
+}
+
+// nestedMap wraps leaf in n nested single-entry MAPs under key "a".
+func nestedMap(n int, leaf map1.Value) map1.Value {
+	for i := 0; i < n; i++ {
+		leaf = map1.NewMap(map1.MapEntry{Key: "a", Value: leaf})
+	}
+	return leaf
+}
+
+func midOf(v map1.Value) string {
+	mid, err := map1.MIDFull(v)
+	if err != nil {
+		panic(err)
 	}
+	return mid
+}
 
-	vecData, _ := os.ReadFile(filepath.Join(dir, "conformance_vectors_v11.json"))
-	expData, _ := os.ReadFile(filepath.Join(dir, "conformance_expected_v11.json"))
+func TestSupplementaryVectors(t *testing.T) {
+	for _, sv := range supplementaryVectors {
+		t.Run(sv.id, func(t *testing.T) {
+			vec := vectorEntry{
+				TestID:   sv.id,
+				Mode:     sv.mode,
+				InputB64: base64.StdEncoding.EncodeToString([]byte(sv.input)),
+				Pointers: sv.pointers,
+			}
+			gotMID, gotErr := runVector(vec)
+			if gotErr != sv.exp.Err || gotMID != sv.exp.MID {
+				t.Errorf("got mid=%q err=%q, expected mid=%q err=%q", gotMID, gotErr, sv.exp.MID, sv.exp.Err)
+			}
+		})
+	}
+}
 
-	var vf vectorsFile
-	json.Unmarshal(vecData, &vf)
-	var ef expectedFile
-	json.Unmarshal(expData, &ef)
+func TestConformanceSummary(t *testing.T) {
+	if findVectorsDir() == "" {
+		t.Skip("Cannot find conformance vectors")
+	}
+	vecs, expected := loadVectors(t)
 
 	passed := 0
 	failed := 0
 	var failures []string
+	byOutcome := map[string]*outcomeTally{}
 
-	for _, vec := range vf.Vectors {
-		exp := ef.Expected[vec.TestID]
+	for _, vec := range vecs {
+		exp := expected[vec.TestID]
 		gotMID, gotErr := runVector(vec)
 
 		ok := false
@@ -272,10 +348,22 @@ func TestConformanceSummary(t *testing.T) {
 			ok = (gotErr == "" && gotMID == exp.MID)
 		}
 
+		outcome := exp.Err
+		if outcome == "" {
+			outcome = "MID"
+		}
+		tally := byOutcome[outcome]
+		if tally == nil {
+			tally = &outcomeTally{}
+			byOutcome[outcome] = tally
+		}
+
 		if ok {
 			passed++
+			tally.passed++
 		} else {
 			failed++
+			tally.failed++
 			failures = append(failures, fmt.Sprintf("  FAIL %s: got mid=%q err=%q expected mid=%q err=%q",
 				vec.TestID, gotMID, gotErr, exp.MID, exp.Err))
 		}
@@ -283,6 +371,7 @@ func TestConformanceSummary(t *testing.T) {
 
 	total := passed + failed
 	fmt.Printf("\nCONFORMANCE (v1.1): %d/%d PASS\n", passed, total)
+	printOutcomeTable(byOutcome)
 	for _, f := range failures {
 		fmt.Println(f)
 	}
@@ -290,3 +379,41 @@ func TestConformanceSummary(t *testing.T) {
 		t.Fatalf("%d/%d tests failed", failed, total)
 	}
 }
+
+// outcomeTally counts results for one expected outcome: an ERR_* code,
+// or "MID" for vectors expected to produce a MID.
+type outcomeTally struct {
+	passed, failed int
+}
+
+// printOutcomeTable prints pass/fail counts per expected outcome, MID
+// first and then the error codes in §6.2 precedence order, so a
+// systematic failure (say, every ERR_UTF8 vector) stands out.
+func printOutcomeTable(byOutcome map[string]*outcomeTally) {
+	outcomes := make([]string, 0, len(byOutcome))
+	for o := range byOutcome {
+		outcomes = append(outcomes, o)
+	}
+	rank := func(o string) int {
+		if o == "MID" {
+			return -1
+		}
+		if idx, ok := map1.Precedence(o); ok {
+			return idx
+		}
+		return math.MaxInt // unknown codes last
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		ri, rj := rank(outcomes[i]), rank(outcomes[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return outcomes[i] < outcomes[j]
+	})
+
+	fmt.Printf("  %-18s %6s %6s %6s\n", "EXPECTED", "PASS", "FAIL", "TOTAL")
+	for _, o := range outcomes {
+		t := byOutcome[o]
+		fmt.Printf("  %-18s %6d %6d %6d\n", o, t.passed, t.failed, t.passed+t.failed)
+	}
+}
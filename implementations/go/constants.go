@@ -21,6 +21,13 @@ const (
 	tagInteger byte = 0x06 // v1.1: payload int64 big-endian, always 8 bytes
 )
 
+// tagNull is the opt-in NULL extension (see Null), NOT part of MAP v1.1.
+// It is the first tag of the private extension range (tagExtMin) and
+// framed like every extension value, tag || u32be(len) || payload, with
+// an empty payload: 0x40 00 00 00 00.  Tags 0x07–0x3F stay free for
+// future spec types, and a decoder with SkipUnknownTags skips it.
+const tagNull byte = 0x40
+
 // Normative safety limits (§4).
 const (
 	MaxCanonBytes  = 1_048_576 // 1 MiB total CANON_BYTES length
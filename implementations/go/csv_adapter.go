@@ -0,0 +1,74 @@
+package map1
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CSV adapter.
+//
+// Each record becomes a MAP of column name → STRING; CSV carries no
+// types, so every cell is a STRING.  A record shorter than the header
+// gets empty STRINGs for its missing trailing columns; a longer record
+// is ERR_SCHEMA.  If header is nil, the first record is the header.
+// Malformed CSV is ERR_CANON_MCF.  Errors number data records from 1,
+// not counting a header read from r.
+
+// ValuesFromCSV reads r and returns one MAP per data record.  Each MAP
+// has its own Keys slice; header is copied, never retained.
+func ValuesFromCSV(r io.Reader, header []string) (List, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // ragged rows are handled below
+	cr.ReuseRecord = true
+
+	if header != nil {
+		header = append([]string{}, header...)
+	}
+	rows := List{}
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, newErr(ErrCanonMCF, "CSV parse error: "+err.Error())
+		}
+		if header == nil {
+			header = append([]string(nil), rec...)
+			continue
+		}
+		if len(rec) > len(header) {
+			return nil, newErr(ErrSchema, fmt.Sprintf("CSV record %d has %d fields, header has %d", len(rows)+1, len(rec), len(header)))
+		}
+		m := &Map{Keys: append([]string(nil), header...), Values: make([]Value, len(header))}
+		for i := range header {
+			if i < len(rec) {
+				m.Values[i] = String(rec[i])
+			} else {
+				m.Values[i] = String("")
+			}
+		}
+		rows = append(rows, m)
+	}
+	return rows, nil
+}
+
+// MIDFromCSV returns the MID of the LIST of all records in r.
+func MIDFromCSV(r io.Reader, header []string) (string, error) {
+	rows, err := ValuesFromCSV(r, header)
+	if err != nil {
+		return "", err
+	}
+	return MIDFromValue(rows)
+}
+
+// MIDsFromCSV returns the MID of each record in r, as a standalone root.
+func MIDsFromCSV(r io.Reader, header []string) ([]string, error) {
+	rows, err := ValuesFromCSV(r, header)
+	if err != nil {
+		return nil, err
+	}
+	return MIDEachListElement(rows)
+}
@@ -1,16 +1,180 @@
 package map1
 
 import (
-	"bytes"
 	"encoding/binary"
+	"fmt"
 )
 
+// DecodeOptions tunes the MCF decoder.  The zero value is the strict,
+// conformant decoder.
+type DecodeOptions struct {
+	// SkipUnknownTags tolerates tags in the extension range (>= 0x40)
+	// by skipping them.  This is a private forward-compatibility
+	// convention, NOT part of MAP v1.1: an extension value is framed
+	// as tag || u32be(len) || payload.  A skipped LIST element is
+	// dropped; a skipped MAP value drops its whole entry.  An
+	// extension value at the root is still ERR_CANON_MCF.  Tags
+	// 0x07–0x3F are always rejected.
+	SkipUnknownTags bool
+
+	// StrictIntegers pins INTEGER to its fixed-width form: the payload
+	// is exactly 8 bytes and anything else is rejected with a message
+	// saying so.  MAP v1.1 has no other INTEGER width, so today this
+	// only changes the error message; it is the hook where a
+	// variable-width extension would reject non-minimal encodings.
+	StrictIntegers bool
+
+	// InternStrings makes equal STRING values and keys within one
+	// decode share a single Go string, so a document repeating a few
+	// enum-like values holds one copy of each instead of one per
+	// occurrence, and stops allocating for repeats.  The price is a
+	// lookup per STRING and a table that lives for the call; it costs
+	// more than it saves when most strings are distinct.
+	InternStrings bool
+
+	// AllowNull decodes the NULL extension (tag 0x40 with an empty
+	// payload; see Null) instead of rejecting it.  A non-empty payload
+	// is ERR_CANON_MCF.  It takes precedence over SkipUnknownTags.
+	AllowNull bool
+
+	// RejectEmptyKeys makes a MAP key "" ERR_SCHEMA, as
+	// EncodeOptions.RejectEmptyKeys does.
+	RejectEmptyKeys bool
+
+	// TolerateUnsortedKeys accepts MAP keys out of canonical order
+	// instead of reporting ERR_KEY_ORDER, for repairing bytes from a
+	// producer that does not sort (see RecanonicalizeCanonBytes).
+	// Duplicate keys, adjacent or not, are still ERR_DUP_KEY.  A map
+	// decoded out of order is not marked Sorted, so re-encoding it
+	// sorts it.
+	TolerateUnsortedKeys bool
+
+	// MaxTotalNodes, if positive, caps the number of values decoded in
+	// total, containers and scalars alike at every level (MAP keys are
+	// not counted).  Decoding stops with ERR_LIMIT_SIZE at the first
+	// value past the cap, bounding the work a wide, shallow input can
+	// cause however it is spread across containers.
+	//
+	// It is also the guard against memory amplification: MAX_CANON_BYTES
+	// bounds the input, but a value costs far more in memory than its
+	// few MCF bytes (an empty LIST is 5 bytes on the wire and a slice
+	// header plus an interface word decoded), so 1 MiB of small values
+	// can materialize a Value graph many times larger.  The cap bounds
+	// the Values materialized, independent of the byte limit; skipped
+	// extension values count toward it too.
+	MaxTotalNodes int
+}
+
+// tagExtMin is the first tag of the private extension range used by
+// DecodeOptions.SkipUnknownTags.
+const tagExtMin byte = 0x40
+
+// mcfDecoder carries decode options through the recursive descent.
+//
+// Violations that leave the framing intact (UTF-8, non-STRING key,
+// duplicate key, key order) are recorded in soft and decoding carries
+// on, so a later, higher-precedence violation is still seen (§6.2).
+// Framing errors and safety limits stop the descent.
+//
+// Error offsets: every violation is located at the tag byte of the
+// innermost value it concerns — the value whose framing failed, the
+// invalid STRING, the offending key.  A missing tag is located where
+// the tag should have been.
+type mcfDecoder struct {
+	opts    DecodeOptions
+	soft    []*MapError
+	softOff []int
+
+	// scratch, if set, supplies the decoded values' storage (DecodeInto).
+	scratch *DecodeScratch
+	// strs interns STRING values under DecodeOptions.InternStrings.
+	strs map[string]Value
+	// nodes counts values decoded, for DecodeOptions.MaxTotalNodes.
+	nodes int
+}
+
+func (d *mcfDecoder) addSoft(err *MapError, off int) {
+	d.soft = append(d.soft, err)
+	d.softOff = append(d.softOff, off)
+}
+
+// report applies the §6.2 reported-code rule to the soft violations
+// plus hard (located at hardOff) and returns the winner's offset.
+func (d *mcfDecoder) report(hard error, hardOff int) (int, error) {
+	err := reportedError(d.soft, hard)
+	if err == nil || err == hard {
+		return hardOff, err
+	}
+	for i, e := range d.soft {
+		if error(e) == err {
+			return d.softOff[i], err
+		}
+	}
+	return hardOff, err
+}
+
 // mcfDecodeOne decodes one MCF value from buf at offset (§3.7 fast-path).
-// Returns the decoded Value and the new offset, or an error.
-// Depth semantics mirror the encoder.
+// Returns the decoded Value and the new offset, or the §6.2 reported
+// error and its offset (see mcfDecoder).  Depth semantics mirror the
+// encoder.
 func mcfDecodeOne(buf []byte, off int, depth int) (Value, int, error) {
+	var d mcfDecoder
+	v, end, err := d.decodeOne(buf, off, depth)
+	if end, err = d.report(err, end); err != nil {
+		return nil, end, err
+	}
+	return v, end, nil
+}
+
+// decodeRoot decodes the single root value of CANON_BYTES starting at
+// off.  Trailing bytes are ERR_CANON_MCF (§3.7.f).
+func decodeRoot(canon []byte, off int) (Value, error) {
+	var d mcfDecoder
+	return d.root(canon, off)
+}
+
+func (d *mcfDecoder) root(canon []byte, off int) (Value, error) {
+	v, end, err := d.decodeOne(canon, off, 0)
+	if err == nil && end != len(canon) {
+		err = trailingBytesErr(canon, end)
+	}
+	if _, err = d.report(err, end); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// trailingBytesErr reports bytes after an MCF root that ends at end,
+// naming the offset (within canon, header included) of the first extra
+// byte and how many follow, so one stray byte reads differently from a
+// second concatenated record.
+func trailingBytesErr(canon []byte, end int) *MapError {
+	return newErr(ErrCanonMCF, fmt.Sprintf("trailing bytes after MCF root at offset %d (%d extra)", end, len(canon)-end))
+}
+
+// DecodeMCFWithOptions is DecodeMCF with decoder options.
+func DecodeMCFWithOptions(buf []byte, opts DecodeOptions) (Value, int, error) {
+	d := mcfDecoder{opts: opts}
+	v, end, err := d.decodeOne(buf, 0, 0)
+	if end, err = d.report(err, end); err != nil {
+		return nil, end, err
+	}
+	if v == nil {
+		return nil, 0, newErr(ErrCanonMCF, "extension tag at root")
+	}
+	return v, end, nil
+}
+
+// decodeOne is mcfDecodeOne under d's options.  A nil Value with a nil
+// error means an extension value was skipped.  On a hard error the
+// returned offset locates it (see mcfDecoder).
+func (d *mcfDecoder) decodeOne(buf []byte, off int, depth int) (Value, int, error) {
+	start := off
 	if off >= len(buf) {
-		return nil, off, newErr(ErrCanonMCF, "truncated tag")
+		return nil, start, newErr(ErrCanonMCF, "truncated tag")
+	}
+	if d.nodes++; d.opts.MaxTotalNodes > 0 && d.nodes > d.opts.MaxTotalNodes {
+		return nil, start, newErr(ErrLimitSize, "node count exceeds MaxTotalNodes")
 	}
 	tag := buf[off]
 	off++
@@ -18,140 +182,230 @@ func mcfDecodeOne(buf []byte, off int, depth int) (Value, int, error) {
 	switch tag {
 
 	case tagString:
-		n, newOff, err := readU32BE(buf, off)
+		raw, end, err := d.stringPayload(buf, start)
 		if err != nil {
-			return nil, off, err
+			return nil, start, err
 		}
-		off = newOff
-		if off+int(n) > len(buf) {
-			return nil, off, newErr(ErrCanonMCF, "truncated string payload")
-		}
-		raw := buf[off : off+int(n)]
-		off += int(n)
-		if err := validateUTF8Scalar(raw); err != nil {
-			return nil, off, err
-		}
-		return String(raw), off, nil
+		return d.str(raw), end, nil
 
 	case tagBytes:
 		n, newOff, err := readU32BE(buf, off)
 		if err != nil {
-			return nil, off, err
+			return nil, start, err
 		}
 		off = newOff
-		if off+int(n) > len(buf) {
-			return nil, off, newErr(ErrCanonMCF, "truncated bytes payload")
+		if !payloadFits(buf, off, n) {
+			return nil, start, newErr(ErrCanonMCF, "truncated bytes payload")
 		}
-		raw := make([]byte, n)
+		raw := d.scratch.bytes(int(n))
 		copy(raw, buf[off:off+int(n)])
 		off += int(n)
 		return Bytes(raw), off, nil
 
 	case tagList:
 		if depth+1 > MaxDepth {
-			return nil, off, newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
+			return nil, start, newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
 		}
 		count, newOff, err := readU32BE(buf, off)
 		if err != nil {
-			return nil, off, err
+			return nil, start, err
 		}
 		off = newOff
 		if count > MaxListEntries {
-			return nil, off, newErr(ErrLimitSize, "list entry count exceeds limit")
+			return nil, start, newErr(ErrLimitSize, "list entry count exceeds limit")
 		}
-		arr := make(List, 0, count)
+		arr := d.scratch.list(int(count))
 		for i := uint32(0); i < count; i++ {
-			item, newOff, err := mcfDecodeOne(buf, off, depth+1)
+			item, newOff, err := d.decodeOne(buf, off, depth+1)
 			if err != nil {
-				return nil, off, err
+				return nil, newOff, err
 			}
 			off = newOff
+			if item == nil {
+				continue
+			}
 			arr = append(arr, item)
 		}
 		return arr, off, nil
 
 	case tagMap:
 		if depth+1 > MaxDepth {
-			return nil, off, newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
+			return nil, start, newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
 		}
 		count, newOff, err := readU32BE(buf, off)
 		if err != nil {
-			return nil, off, err
+			return nil, start, err
 		}
 		off = newOff
 		if count > MaxMapEntries {
-			return nil, off, newErr(ErrLimitSize, "map entry count exceeds limit")
+			return nil, start, newErr(ErrLimitSize, "map entry count exceeds limit")
 		}
 
-		keys := make([]string, 0, count)
-		vals := make([]Value, 0, count)
-		var prevKey []byte
+		keys, vals := d.scratch.entries(int(count))
+		var prevKey String
+		hasPrev := false
+		sorted := true
+		var seen map[String]bool // under TolerateUnsortedKeys
 
 		for i := uint32(0); i < count; i++ {
 			// Keys must be STRING-tagged (§3.2).
+			keyOff := off
 			if off >= len(buf) {
 				return nil, off, newErr(ErrCanonMCF, "truncated map key tag")
 			}
 			if buf[off] != tagString {
-				return nil, off, newErr(ErrSchema, "map key must be STRING")
+				d.addSoft(badKeyTag(buf, off), keyOff)
+				// Walk the key and its value for framing only.
+				for j := 0; j < 2; j++ {
+					if _, off, err = d.decodeOne(buf, off, depth+1); err != nil {
+						return nil, off, err
+					}
+				}
+				continue
 			}
-			kv, newOff, err := mcfDecodeOne(buf, off, depth+1)
+			raw, newOff, err := d.stringPayload(buf, off)
 			if err != nil {
-				return nil, off, err
+				return nil, keyOff, err
 			}
 			off = newOff
-			k, ok := kv.(String)
-			if !ok {
-				return nil, off, newErr(ErrSchema, "map key decoded to non-string")
+			k := d.str(raw).(String)
+			if len(k) == 0 && d.opts.RejectEmptyKeys {
+				d.addSoft(newErr(ErrSchema, "empty MAP key (RejectEmptyKeys)"), keyOff)
 			}
-			kb := []byte(string(k))
-
 			// Enforce ordering and uniqueness on the wire.
-			if prevKey != nil {
-				cmp := bytes.Compare(prevKey, kb)
+			if d.opts.TolerateUnsortedKeys {
+				if seen == nil {
+					seen = make(map[String]bool)
+				}
+				if seen[k] {
+					d.addSoft(newErr(ErrDupKey, "duplicate key in MCF"), keyOff)
+				}
+				seen[k] = true
+				if hasPrev && CompareKeys(string(prevKey), string(k)) > 0 {
+					sorted = false
+				}
+			} else if hasPrev {
+				cmp := CompareKeys(string(prevKey), string(k))
 				if cmp == 0 {
-					return nil, off, newErr(ErrDupKey, "duplicate key in MCF")
+					d.addSoft(newErr(ErrDupKey, "duplicate key in MCF"), keyOff)
 				}
 				if cmp > 0 {
-					return nil, off, newErr(ErrKeyOrder, "key order violation in MCF")
+					d.addSoft(newErr(ErrKeyOrder, "key order violation in MCF"), keyOff)
 				}
 			}
-			prevKey = kb
+			prevKey, hasPrev = k, true
 
-			v, newOff2, err := mcfDecodeOne(buf, off, depth+1)
+			v, newOff2, err := d.decodeOne(buf, off, depth+1)
 			if err != nil {
-				return nil, off, err
+				return nil, newOff2, err
 			}
 			off = newOff2
+			if v == nil {
+				continue
+			}
 
 			keys = append(keys, string(k))
 			vals = append(vals, v)
 		}
 
-		return &Map{Keys: keys, Values: vals}, off, nil
+		m := d.scratch.newMap()
+		m.Keys, m.Values, m.Sorted = keys, vals, sorted
+		return m, off, nil
 
 	case tagBoolean:
 		// BOOLEAN: exactly 1 payload byte, must be 0x00 or 0x01 (§3.2).
 		if off >= len(buf) {
-			return nil, off, newErr(ErrCanonMCF, "truncated boolean payload")
+			return nil, start, newErr(ErrCanonMCF, "truncated boolean payload")
 		}
 		payload := buf[off]
 		if payload != 0x00 && payload != 0x01 {
-			return nil, off + 1, newErr(ErrCanonMCF, "invalid boolean payload")
+			return nil, start, newErr(ErrCanonMCF, "invalid boolean payload")
 		}
 		return Bool(payload == 0x01), off + 1, nil
 
 	case tagInteger:
 		// INTEGER: exactly 8 payload bytes, signed big-endian (§3.2).
 		if off+8 > len(buf) {
-			return nil, off, newErr(ErrCanonMCF, "truncated integer payload")
+			if d.opts.StrictIntegers {
+				return nil, start, newErr(ErrCanonMCF, "INTEGER payload is not exactly 8 bytes")
+			}
+			return nil, start, newErr(ErrCanonMCF, "truncated integer payload")
 		}
 		val := int64(binary.BigEndian.Uint64(buf[off : off+8]))
-		return Integer(val), off + 8, nil
+		return d.scratch.integer(Integer(val)), off + 8, nil
 
 	default:
-		return nil, off, newErr(ErrCanonMCF, "unknown MCF tag")
+		if d.opts.AllowNull && tag == tagNull {
+			n, newOff, err := readU32BE(buf, off)
+			if err != nil {
+				return nil, start, err
+			}
+			if n != 0 {
+				return nil, start, newErr(ErrCanonMCF, "NULL payload must be empty")
+			}
+			return Null{}, newOff, nil
+		}
+		if d.opts.SkipUnknownTags && tag >= tagExtMin {
+			n, newOff, err := readU32BE(buf, off)
+			if err != nil {
+				return nil, start, err
+			}
+			off = newOff
+			if !payloadFits(buf, off, n) {
+				return nil, start, newErr(ErrCanonMCF, "truncated extension payload")
+			}
+			return nil, off + int(n), nil
+		}
+		return nil, start, newErr(ErrCanonMCF, "unknown MCF tag")
+	}
+}
+
+// stringPayload reads the STRING whose tag is at buf[start], returning
+// its payload and the offset past it.  Invalid UTF-8 is recorded.
+func (d *mcfDecoder) stringPayload(buf []byte, start int) ([]byte, int, error) {
+	n, off, err := readU32BE(buf, start+1)
+	if err != nil {
+		return nil, start, err
+	}
+	if !payloadFits(buf, off, n) {
+		return nil, start, newErr(ErrCanonMCF, "truncated string payload")
+	}
+	raw := buf[off : off+int(n)]
+	if err := validateUTF8Scalar(raw); err != nil {
+		d.addSoft(err.(*MapError), start)
+	}
+	return raw, off + int(n), nil
+}
+
+// badKeyTag is the ERR_SCHEMA for a MAP key at buf[off] that is not
+// STRING-tagged (§3.2).
+func badKeyTag(buf []byte, off int) *MapError {
+	return newErr(ErrSchema, fmt.Sprintf("map key at offset %d has tag 0x%02x, must be STRING", off, buf[off]))
+}
+
+// payloadFits reports whether an n-byte payload at off lies within buf.
+// The sum is taken in uint64: on a 32-bit platform off+int(n) can wrap
+// negative for n near 0xFFFFFFFF and slip past a plain int check.
+// Once it holds, int(n) <= len(buf) and is safe to slice with.
+func payloadFits(buf []byte, off int, n uint32) bool {
+	return uint64(off)+uint64(n) <= uint64(len(buf))
+}
+
+// str returns raw as a STRING value, interned if the options or a
+// scratch ask for it.
+func (d *mcfDecoder) str(raw []byte) Value {
+	if d.scratch != nil || !d.opts.InternStrings {
+		return d.scratch.string(raw)
+	}
+	if v, ok := d.strs[string(raw)]; ok {
+		return v
+	}
+	if d.strs == nil {
+		d.strs = make(map[string]Value)
 	}
+	v := Value(String(raw))
+	d.strs[string(raw)] = v
+	return v
 }
 
 func readU32BE(buf []byte, off int) (uint32, int, error) {
@@ -0,0 +1,146 @@
+package map1
+
+import "bytes"
+
+// DecodeScratch is reusable storage for DecodeInto.  Decoding draws the
+// backing arrays of every LIST, MAP and BYTES value from it, so a loop
+// that decodes many similar blobs with one scratch stops allocating
+// once the scratch has grown to fit.  Short STRING values and INTEGER
+// values are interned across calls, boxed, so repeats cost nothing.
+// The zero value is ready to use.
+//
+// A DecodeScratch is not safe for concurrent use.
+type DecodeScratch struct {
+	values []Value
+	keys   []string
+	maps   []Map
+	raw    []byte
+	strs   map[string]Value
+	ints   map[Integer]Value
+}
+
+// Interning bounds: only short strings are interned, and each table
+// stops growing at scratchInternMax entries.
+const (
+	scratchInternLen = 64
+	scratchInternMax = 4096
+)
+
+// DecodeInto validates CANON_BYTES exactly as DecodeCanonBytes does and
+// returns the decoded root, built in scratch.
+//
+// Aliasing: each call reuses scratch's storage, so every LIST, *Map and
+// BYTES reachable from a value returned by an earlier call with the
+// same scratch may be overwritten.  Finish with a value (or copy what
+// you need out of it) before the next DecodeInto.  STRING values are
+// ordinary immutable Go strings and may be kept.  To retain a whole
+// tree, decode it with DecodeCanonBytes instead.  A nil scratch
+// allocates as DecodeCanonBytes does.
+func DecodeInto(canon []byte, scratch *DecodeScratch) (Value, error) {
+	if !bytes.HasPrefix(canon, canonHdr) {
+		return nil, newErr(ErrCanonHdr, "bad CANON_HDR")
+	}
+	if len(canon) > MaxCanonBytes {
+		return nil, newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	scratch.reset()
+	d := mcfDecoder{scratch: scratch}
+	return d.root(canon, len(canonHdr))
+}
+
+func (s *DecodeScratch) reset() {
+	if s == nil {
+		return
+	}
+	s.values = s.values[:0]
+	s.keys = s.keys[:0]
+	s.maps = s.maps[:0]
+	s.raw = s.raw[:0]
+}
+
+// The allocators below are called by the decoder on a possibly nil
+// scratch; nil means allocate normally.  Each carves a zero-length,
+// capacity-n slice off the end of its slab, starting a new, larger slab
+// when the current one is full.  Older slabs stay alive only as long
+// as values built from them.
+
+func (s *DecodeScratch) list(n int) List {
+	if s == nil {
+		return make(List, 0, n)
+	}
+	if cap(s.values)-len(s.values) < n {
+		s.values = make([]Value, 0, max(2*cap(s.values), n, 64))
+	}
+	i := len(s.values)
+	s.values = s.values[:i+n]
+	return List(s.values[i : i : i+n])
+}
+
+func (s *DecodeScratch) entries(n int) ([]string, []Value) {
+	if s == nil {
+		return make([]string, 0, n), make([]Value, 0, n)
+	}
+	if cap(s.keys)-len(s.keys) < n {
+		s.keys = make([]string, 0, max(2*cap(s.keys), n, 64))
+	}
+	i := len(s.keys)
+	s.keys = s.keys[:i+n]
+	return s.keys[i : i : i+n], s.list(n)
+}
+
+func (s *DecodeScratch) newMap() *Map {
+	if s == nil {
+		return &Map{}
+	}
+	if len(s.maps) == cap(s.maps) {
+		s.maps = make([]Map, 0, max(2*cap(s.maps), 16))
+	}
+	s.maps = append(s.maps, Map{})
+	return &s.maps[len(s.maps)-1]
+}
+
+func (s *DecodeScratch) bytes(n int) []byte {
+	if s == nil {
+		return make([]byte, n)
+	}
+	if cap(s.raw)-len(s.raw) < n {
+		s.raw = make([]byte, 0, max(2*cap(s.raw), n, 1024))
+	}
+	i := len(s.raw)
+	s.raw = s.raw[:i+n]
+	return s.raw[i : i+n : i+n]
+}
+
+func (s *DecodeScratch) string(raw []byte) Value {
+	if s == nil || len(raw) > scratchInternLen {
+		return String(raw)
+	}
+	if v, ok := s.strs[string(raw)]; ok {
+		return v
+	}
+	v := Value(String(raw))
+	if len(s.strs) < scratchInternMax {
+		if s.strs == nil {
+			s.strs = make(map[string]Value)
+		}
+		s.strs[string(raw)] = v
+	}
+	return v
+}
+
+func (s *DecodeScratch) integer(n Integer) Value {
+	if s == nil {
+		return n
+	}
+	if v, ok := s.ints[n]; ok {
+		return v
+	}
+	v := Value(n)
+	if len(s.ints) < scratchInternMax {
+		if s.ints == nil {
+			s.ints = make(map[Integer]Value)
+		}
+		s.ints[n] = v
+	}
+	return v
+}
@@ -0,0 +1,356 @@
+package map1_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+)
+
+// Differential test: JSON-STRICT vs an independent RFC 8785 (JCS)
+// canonicalizer.
+//
+// The reference below is a self-contained recursive-descent parser —
+// it shares no code with the adapter — plus JCS serialization.  For
+// inputs inside the common subset, the two must agree on accept/reject,
+// and two inputs must share a MID exactly when they share a JCS form.
+//
+// Inputs outside the subset are skipped, not compared: null, floats,
+// integers beyond ±2^53 (JCS works in IEEE 754 doubles), any surrogate
+// escape (JSON-STRICT rejects even valid pairs, JCS accepts them), and
+// nesting beyond MAX_DEPTH (JCS has no limit).
+//
+// Extra corpus: set MAP1_JCS_CORPUS to a directory of *.json files.
+
+var jcsCorpus = []string{
+	`{}`, `[]`, `{"a":1}`, `{ "a" : 1 }`, "{\n\t\"a\": 1\n}",
+	`{"b":2,"a":1}`, `{"a":1,"b":2}`, `{"a":[1,2,{"c":true}]}`,
+	`{"a":[2,1]}`, `{"a":"xA"}`, `{"a":"\u0078A"}`, `{"é":0}`, `{"\u00e9":0}`,
+	`"str"`, `42`, `-0`, `true`, `false`, `[[[[]]]]`,
+	`{"a":"\n\"\\\/"}`, `{"a":"\u001f"}`, `{"€":1,"$":2,"😀":3}`,
+	`{"a":1,"a":2}`, `{"b":{"c":1,"c":1}}`, `{"a":}`, `{"a":1,}`, `[1,]`,
+	`{"a":01}`, `{"a":+1}`, `{"a":'x'}`, `{"a":1} {"b":2}`, `{"a":"unterminated}`,
+	"\xef\xbb\xbf{}", `{"a":"\x"}`, `{"a":NaN}`, "{\"a\":\"\x01\"}", `[1 2]`, ``, ` `,
+	`{"a":1.5}`, `{"a":null}`, `{"a":"😀"}`, `{"a":9007199254740993}`,
+}
+
+func TestDifferentialJCS(t *testing.T) {
+	inputs := map[string][]byte{}
+	for i, s := range jcsCorpus {
+		inputs["builtin_"+strconv.Itoa(i)] = []byte(s)
+	}
+	if dir := os.Getenv("MAP1_JCS_CORPUS"); dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs[filepath.Base(f)] = data
+		}
+	}
+
+	byJCS := map[string]string{} // JCS form → MID
+	byMID := map[string]string{} // MID → JCS form
+	compared := 0
+	for name, raw := range inputs {
+		jcs, refErr := jcsCanonicalize(raw)
+		if errors.Is(refErr, errOutOfSubset) {
+			continue
+		}
+		compared++
+		mid, mapErr := map1.MIDFullJSON(raw)
+		if (refErr == nil) != (mapErr == nil) {
+			t.Errorf("%s %q: JCS err=%v, MAP err=%v", name, raw, refErr, mapErr)
+			continue
+		}
+		if refErr != nil {
+			continue
+		}
+		if prev, ok := byJCS[jcs]; ok && prev != mid {
+			t.Errorf("%s %q: same JCS form %s but MIDs differ", name, raw, jcs)
+		}
+		if prev, ok := byMID[mid]; ok && prev != jcs {
+			t.Errorf("%s %q: same MID but JCS forms differ: %s vs %s", name, raw, prev, jcs)
+		}
+		byJCS[jcs] = mid
+		byMID[mid] = jcs
+	}
+	t.Logf("differential: compared %d of %d inputs", compared, len(inputs))
+}
+
+var (
+	errOutOfSubset = errors.New("outside the common subset")
+	errJCSReject   = errors.New("rejected by reference parser")
+)
+
+func jcsCanonicalize(raw []byte) (string, error) {
+	p := &refParser{src: raw}
+	p.ws()
+	var out strings.Builder
+	if err := p.value(&out, 1); err != nil {
+		return "", err
+	}
+	p.ws()
+	if p.pos != len(p.src) {
+		return "", errJCSReject
+	}
+	return out.String(), nil
+}
+
+type refParser struct {
+	src []byte
+	pos int
+}
+
+func (p *refParser) ws() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *refParser) lit(s string) bool {
+	if strings.HasPrefix(string(p.src[p.pos:]), s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *refParser) value(out *strings.Builder, depth int) error {
+	if p.pos >= len(p.src) {
+		return errJCSReject
+	}
+	switch c := p.src[p.pos]; {
+	case c == '{':
+		return p.object(out, depth)
+	case c == '[':
+		return p.array(out, depth)
+	case c == '"':
+		s, err := p.str()
+		if err != nil {
+			return err
+		}
+		out.WriteString(jcsString(s))
+		return nil
+	case p.lit("true"):
+		out.WriteString("true")
+		return nil
+	case p.lit("false"):
+		out.WriteString("false")
+		return nil
+	case p.lit("null"):
+		return errOutOfSubset
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number(out)
+	}
+	return errJCSReject
+}
+
+func (p *refParser) object(out *strings.Builder, depth int) error {
+	if depth > map1.MaxDepth {
+		return errOutOfSubset
+	}
+	p.pos++ // '{'
+	members := map[string]string{}
+	var keys []string
+	p.ws()
+	if p.lit("}") {
+		out.WriteString("{}")
+		return nil
+	}
+	for {
+		p.ws()
+		if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+			return errJCSReject
+		}
+		k, err := p.str()
+		if err != nil {
+			return err
+		}
+		p.ws()
+		if !p.lit(":") {
+			return errJCSReject
+		}
+		p.ws()
+		var v strings.Builder
+		if err := p.value(&v, depth+1); err != nil {
+			return err
+		}
+		if _, dup := members[k]; dup {
+			return errJCSReject // I-JSON: member names must be unique
+		}
+		members[k] = v.String()
+		keys = append(keys, k)
+		p.ws()
+		if p.lit("}") {
+			break
+		}
+		if !p.lit(",") {
+			return errJCSReject
+		}
+	}
+	// RFC 8785 §3.2.3: sort by UTF-16 code units.
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := utf16.Encode([]rune(keys[i])), utf16.Encode([]rune(keys[j]))
+		for n := 0; n < len(a) && n < len(b); n++ {
+			if a[n] != b[n] {
+				return a[n] < b[n]
+			}
+		}
+		return len(a) < len(b)
+	})
+	out.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteString(jcsString(k))
+		out.WriteByte(':')
+		out.WriteString(members[k])
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+func (p *refParser) array(out *strings.Builder, depth int) error {
+	if depth > map1.MaxDepth {
+		return errOutOfSubset
+	}
+	p.pos++ // '['
+	out.WriteByte('[')
+	p.ws()
+	if p.lit("]") {
+		out.WriteByte(']')
+		return nil
+	}
+	for first := true; ; first = false {
+		if !first {
+			out.WriteByte(',')
+		}
+		p.ws()
+		if err := p.value(out, depth+1); err != nil {
+			return err
+		}
+		p.ws()
+		if p.lit("]") {
+			out.WriteByte(']')
+			return nil
+		}
+		if !p.lit(",") {
+			return errJCSReject
+		}
+	}
+}
+
+func (p *refParser) str() (string, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+		case c < 0x20:
+			return "", errJCSReject
+		case c == '\\':
+			if p.pos+1 >= len(p.src) {
+				return "", errJCSReject
+			}
+			esc := p.src[p.pos+1]
+			p.pos += 2
+			if i := strings.IndexByte(`"\/bfnrt`, esc); i >= 0 {
+				b.WriteByte("\"\\/\b\f\n\r\t"[i])
+				continue
+			}
+			if esc != 'u' || p.pos+4 > len(p.src) {
+				return "", errJCSReject
+			}
+			cp, err := strconv.ParseUint(string(p.src[p.pos:p.pos+4]), 16, 16)
+			if err != nil {
+				return "", errJCSReject
+			}
+			if cp >= 0xD800 && cp <= 0xDFFF {
+				return "", errOutOfSubset
+			}
+			p.pos += 4
+			b.WriteRune(rune(cp))
+		default:
+			r, size := utf8.DecodeRune(p.src[p.pos:])
+			if r == utf8.RuneError && size <= 1 {
+				return "", errJCSReject
+			}
+			b.WriteRune(r)
+			p.pos += size
+		}
+	}
+	return "", errJCSReject
+}
+
+func (p *refParser) number(out *strings.Builder) error {
+	start := p.pos
+	p.lit("-")
+	switch {
+	case p.lit("0"):
+	case p.pos < len(p.src) && p.src[p.pos] >= '1' && p.src[p.pos] <= '9':
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+	default:
+		return errJCSReject
+	}
+	if p.pos < len(p.src) && strings.IndexByte(".eE", p.src[p.pos]) >= 0 {
+		return errOutOfSubset
+	}
+	n, err := strconv.ParseInt(string(p.src[start:p.pos]), 10, 64)
+	if err != nil || n > 1<<53 || n < -(1<<53) {
+		return errOutOfSubset
+	}
+	out.WriteString(strconv.FormatInt(n, 10))
+	return nil
+}
+
+// jcsString serializes s per RFC 8785 §3.2.2.2 (ECMAScript JSON.stringify).
+func jcsString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				b.WriteString(`\u00`)
+				b.WriteString(strconv.FormatInt(int64(r)>>4, 16))
+				b.WriteString(strconv.FormatInt(int64(r)&0xF, 16))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package map1
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dump renders v as indented, human-readable text for debugging.  Each
+// node shows its MAP type name; maps are listed in canonical key order
+// (§3.5).  The output format is not stable and must not be parsed or
+// hashed.  Dump does not validate: a tree that would fail to encode is
+// still rendered.
+func Dump(v Value) string {
+	return DumpN(v, -1, -1)
+}
+
+// DumpN is Dump with limits for logging large trees.  Containers nested
+// deeper than maxDepth (root = 0) are collapsed to "…" and a count, and
+// at most maxItems entries are shown per container, followed by
+// "… N more".  A negative limit means unlimited.
+func DumpN(v Value, maxDepth, maxItems int) string {
+	d := dumper{maxDepth: maxDepth, maxItems: maxItems}
+	d.value(v, 0)
+	return d.b.String()
+}
+
+type dumper struct {
+	b        strings.Builder
+	maxDepth int
+	maxItems int
+}
+
+func (d *dumper) indent(depth int) {
+	d.b.WriteString(strings.Repeat("  ", depth))
+}
+
+// shown returns how many of n entries fit under maxItems.
+func (d *dumper) shown(n int) int {
+	if d.maxItems >= 0 && n > d.maxItems {
+		return d.maxItems
+	}
+	return n
+}
+
+func (d *dumper) more(n, shown, depth int) {
+	if n > shown {
+		d.indent(depth + 1)
+		fmt.Fprintf(&d.b, "… %d more\n", n-shown)
+	}
+}
+
+// value writes v, which starts on the current line, and a newline.
+func (d *dumper) value(v Value, depth int) {
+	switch val := v.(type) {
+	case String:
+		fmt.Fprintf(&d.b, "STRING %s\n", strconv.Quote(string(val)))
+	case Bytes:
+		fmt.Fprintf(&d.b, "BYTES 0x%s\n", hex.EncodeToString(val))
+	case Bool:
+		fmt.Fprintf(&d.b, "BOOLEAN %t\n", bool(val))
+	case Integer:
+		fmt.Fprintf(&d.b, "INTEGER %d\n", int64(val))
+	case Null:
+		d.b.WriteString("NULL\n")
+
+	case List:
+		if len(val) == 0 {
+			d.b.WriteString("LIST []\n")
+			return
+		}
+		if d.maxDepth >= 0 && depth >= d.maxDepth {
+			fmt.Fprintf(&d.b, "LIST [… %d items]\n", len(val))
+			return
+		}
+		d.b.WriteString("LIST [\n")
+		n := d.shown(len(val))
+		for _, item := range val[:n] {
+			d.indent(depth + 1)
+			d.value(item, depth+1)
+		}
+		d.more(len(val), n, depth)
+		d.indent(depth)
+		d.b.WriteString("]\n")
+
+	case *Map:
+		if len(val.Keys) == 0 {
+			d.b.WriteString("MAP {}\n")
+			return
+		}
+		if d.maxDepth >= 0 && depth >= d.maxDepth {
+			fmt.Fprintf(&d.b, "MAP {… %d entries}\n", len(val.Keys))
+			return
+		}
+		order := make([]int, len(val.Keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return val.Keys[order[i]] < val.Keys[order[j]]
+		})
+		d.b.WriteString("MAP {\n")
+		n := d.shown(len(order))
+		for _, i := range order[:n] {
+			d.indent(depth + 1)
+			fmt.Fprintf(&d.b, "%s: ", strconv.Quote(val.Keys[i]))
+			d.value(val.Values[i], depth+1)
+		}
+		d.more(len(order), n, depth)
+		d.indent(depth)
+		d.b.WriteString("}\n")
+
+	default:
+		fmt.Fprintf(&d.b, "<invalid %T>\n", v)
+	}
+}
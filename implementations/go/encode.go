@@ -7,6 +7,21 @@ import (
 	"unicode/utf8"
 )
 
+// EncodeOptions tunes the encoder.  The zero value is the conformant
+// MAP v1.1 encoder.
+type EncodeOptions struct {
+	// AllowNull encodes Null as the NULL extension (tag 0x40, empty
+	// payload) instead of rejecting it with ERR_TYPE.  The result is
+	// NOT MAP v1.1 CANON_BYTES: only a decoder with
+	// DecodeOptions.AllowNull reads it back.
+	AllowNull bool
+
+	// RejectEmptyKeys makes a MAP key "" ERR_SCHEMA.  MAP v1.1 allows
+	// it (it sorts before every other key); this is a guardrail for
+	// schemas where an empty key is always a bug.
+	RejectEmptyKeys bool
+}
+
 // mcfEncode encodes a canonical model value into MCF bytes (§3.2).
 //
 // Depth tracks container nesting:
@@ -14,16 +29,54 @@ import (
 //   - Entering a MAP or LIST checks depth+1 against MaxDepth.
 //   - Scalars (STRING, BYTES, BOOLEAN, INTEGER) don't increment depth.
 func mcfEncode(v Value, depth int) ([]byte, error) {
+	return mcfEncodeOpts(v, depth, EncodeOptions{})
+}
+
+func mcfEncodeOpts(v Value, depth int, opts EncodeOptions) ([]byte, error) {
 	// TODO: use sync.Pool for encode buffers to reduce GC pressure
 	// on high-throughput MID computation.
-	var buf bytes.Buffer
+	buf := encBuf{opts: opts}
 	if err := mcfEncodeTo(&buf, v, depth); err != nil {
-		return nil, err
+		// The encoder stops at the first violation; re-walk the whole
+		// tree so the reported code is the §6.2 winner, not whichever
+		// violation the walk happened to reach first.
+		w := &validator{opts: opts}
+		w.walk(v, "", depth)
+		return nil, reportedError(w.errs, err)
 	}
 	return buf.Bytes(), nil
 }
 
-func mcfEncodeTo(buf *bytes.Buffer, v Value, depth int) error {
+// encBuf is the encoder's output buffer.  With a sink attached
+// (streaming via EncodeCanonBytes) it is drained into the sink whenever
+// it grows past encFlushSize, so memory stays bounded; without one it
+// simply accumulates the encoding.
+type encBuf struct {
+	bytes.Buffer
+	sink *CanonWriter
+	opts EncodeOptions
+}
+
+// encFlushSize is the buffered size at which a streaming encode drains
+// to its sink.
+const encFlushSize = 32 << 10
+
+// maybeFlush drains buf to its sink once it is large enough, returning
+// the sink's error (ERR_LIMIT_SIZE or an I/O error) if the write fails.
+func (buf *encBuf) maybeFlush() error {
+	if buf.sink == nil || buf.Len() < encFlushSize {
+		return nil
+	}
+	return buf.flush()
+}
+
+func (buf *encBuf) flush() error {
+	_, err := buf.sink.Write(buf.Bytes())
+	buf.Reset()
+	return err
+}
+
+func mcfEncodeTo(buf *encBuf, v Value, depth int) error {
 	switch val := v.(type) {
 
 	case Bool:
@@ -42,18 +95,31 @@ func mcfEncodeTo(buf *bytes.Buffer, v Value, depth int) error {
 		binary.BigEndian.PutUint64(b[:], uint64(val))
 		buf.Write(b[:])
 
+	case Null:
+		if !buf.opts.AllowNull {
+			return newErr(ErrType, "NULL is an extension (EncodeOptions.AllowNull)")
+		}
+		buf.WriteByte(tagNull)
+		writeU32BE(&buf.Buffer, 0)
+
 	case String:
 		raw := []byte(string(val))
 		if err := validateUTF8Scalar(raw); err != nil {
 			return err
 		}
+		if err := checkPayloadLen(len(raw)); err != nil {
+			return err
+		}
 		buf.WriteByte(tagString)
-		writeU32BE(buf, uint32(len(raw)))
+		writeU32BE(&buf.Buffer, uint32(len(raw)))
 		buf.Write(raw)
 
 	case Bytes:
+		if err := checkPayloadLen(len(val)); err != nil {
+			return err
+		}
 		buf.WriteByte(tagBytes)
-		writeU32BE(buf, uint32(len(val)))
+		writeU32BE(&buf.Buffer, uint32(len(val)))
 		buf.Write([]byte(val))
 
 	case List:
@@ -64,20 +130,63 @@ func mcfEncodeTo(buf *bytes.Buffer, v Value, depth int) error {
 			return newErr(ErrLimitSize, "list entry count exceeds limit")
 		}
 		buf.WriteByte(tagList)
-		writeU32BE(buf, uint32(len(val)))
+		writeU32BE(&buf.Buffer, uint32(len(val)))
 		for _, item := range val {
 			if err := mcfEncodeTo(buf, item, depth+1); err != nil {
 				return err
 			}
+			if err := buf.maybeFlush(); err != nil {
+				return err
+			}
 		}
 
 	case *Map:
 		if depth+1 > MaxDepth {
 			return newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
 		}
+		// Both limit checks are O(1) and come before any per-entry
+		// work, so an oversized map fails without touching its keys.
 		if len(val.Keys) > MaxMapEntries {
 			return newErr(ErrLimitSize, "map entry count exceeds limit")
 		}
+		checkKey := func(kb []byte) error {
+			if err := validateUTF8Scalar(kb); err != nil {
+				return err
+			}
+			if err := checkPayloadLen(len(kb)); err != nil {
+				return err
+			}
+			if len(kb) == 0 && buf.opts.RejectEmptyKeys {
+				return newErr(ErrSchema, "empty MAP key (RejectEmptyKeys)")
+			}
+			return nil
+		}
+		// A map marked Sorted is encoded straight from Keys/Values, with
+		// no key copy and no sort.  The mark is verified, not assumed:
+		// one O(n) pass confirms the keys strictly ascend, which also
+		// rules out duplicates.  A stale mark (keys changed after
+		// marking) falls through to the general path below.
+		if val.Sorted && keysAscending(val.Keys) {
+			for _, k := range val.Keys {
+				if err := checkKey([]byte(k)); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(tagMap)
+			writeU32BE(&buf.Buffer, uint32(len(val.Keys)))
+			for i, k := range val.Keys {
+				buf.WriteByte(tagString)
+				writeU32BE(&buf.Buffer, uint32(len(k)))
+				buf.WriteString(k)
+				if err := mcfEncodeTo(buf, val.Values[i], depth+1); err != nil {
+					return err
+				}
+				if err := buf.maybeFlush(); err != nil {
+					return err
+				}
+			}
+			break
+		}
 		// Collect keys as UTF-8 bytes, validate, then sort by memcmp.
 		type kv struct {
 			keyBytes []byte
@@ -86,34 +195,41 @@ func mcfEncodeTo(buf *bytes.Buffer, v Value, depth int) error {
 		items := make([]kv, len(val.Keys))
 		for i, k := range val.Keys {
 			kb := []byte(k)
-			if err := validateUTF8Scalar(kb); err != nil {
+			if err := checkKey(kb); err != nil {
 				return err
 			}
 			items[i] = kv{keyBytes: kb, val: val.Values[i]}
 		}
 		// Sort by raw UTF-8 bytes — unsigned-octet lexicographic (§3.5).
+		// Skip the sort when a linear scan shows the keys are already in
+		// order (common for maps that came out of a prior
+		// canonicalization).
 		// TODO: benchmark bytes.Compare vs manual loop for typical key sizes.
-		sort.Slice(items, func(i, j int) bool {
+		less := func(i, j int) bool {
 			return bytes.Compare(items[i].keyBytes, items[j].keyBytes) < 0
-		})
-		// Validate uniqueness and ordering.
-		sortedKeys := make([][]byte, len(items))
-		for i, kv := range items {
-			sortedKeys[i] = kv.keyBytes
 		}
-		if err := ensureSortedUniqueKeys(sortedKeys); err != nil {
-			return err
+		if !sort.SliceIsSorted(items, less) {
+			sort.Slice(items, less)
+		}
+		// Validate uniqueness.
+		for i := 1; i < len(items); i++ {
+			if bytes.Equal(items[i-1].keyBytes, items[i].keyBytes) {
+				return newErr(ErrDupKey, "duplicate key")
+			}
 		}
 		buf.WriteByte(tagMap)
-		writeU32BE(buf, uint32(len(items)))
+		writeU32BE(&buf.Buffer, uint32(len(items)))
 		for _, kv := range items {
 			// Keys are always STRING-tagged (§3.2).
 			buf.WriteByte(tagString)
-			writeU32BE(buf, uint32(len(kv.keyBytes)))
+			writeU32BE(&buf.Buffer, uint32(len(kv.keyBytes)))
 			buf.Write(kv.keyBytes)
 			if err := mcfEncodeTo(buf, kv.val, depth+1); err != nil {
 				return err
 			}
+			if err := buf.maybeFlush(); err != nil {
+				return err
+			}
 		}
 
 	default:
@@ -122,6 +238,27 @@ func mcfEncodeTo(buf *bytes.Buffer, v Value, depth int) error {
 	return nil
 }
 
+// keysAscending reports whether keys are in strictly ascending
+// canonical order (§3.5): sorted and free of duplicates.
+func keysAscending(keys []string) bool {
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPayloadLen rejects a STRING/BYTES payload that could never fit
+// in CANON_BYTES.  It must run before writeU32BE: a length above
+// math.MaxUint32 would otherwise be silently truncated by the cast.
+func checkPayloadLen(n int) error {
+	if n > MaxCanonBytes {
+		return newErr(ErrLimitSize, "payload exceeds MAX_CANON_BYTES")
+	}
+	return nil
+}
+
 func writeU32BE(buf *bytes.Buffer, n uint32) {
 	var b [4]byte
 	binary.BigEndian.PutUint32(b[:], n)
@@ -149,16 +286,3 @@ func validateUTF8Scalar(b []byte) error {
 	}
 	return nil
 }
-
-func ensureSortedUniqueKeys(keys [][]byte) error {
-	for i := 1; i < len(keys); i++ {
-		c := bytes.Compare(keys[i-1], keys[i])
-		if c == 0 {
-			return newErr(ErrDupKey, "duplicate key")
-		}
-		if c > 0 {
-			return newErr(ErrKeyOrder, "key order violation")
-		}
-	}
-	return nil
-}
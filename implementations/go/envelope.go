@@ -0,0 +1,89 @@
+package map1
+
+import (
+	"bytes"
+)
+
+// Envelope framing, for carrying CANON_BYTES on the wire with a
+// content-type tag.  This is a transport convention, NOT part of
+// MAP v1.1 and never part of CANON_BYTES:
+//
+//	envelope = "MAPE" || version(1) || ctlen(1) || content-type || CANON_BYTES
+//
+// The magic cannot be mistaken for CANON_HDR ("MAP1\x00"), so feeding an
+// envelope to MIDFromCanonBytes fails with ERR_CANON_HDR rather than
+// yielding a MID.  The MID of an enveloped value is the MID of its inner
+// CANON_BYTES, unaffected by the content type.
+var envelopeMagic = []byte("MAPE")
+
+const (
+	envelopeVersion = 0x01
+
+	// MaxEnvelopeContentType is the longest content-type tag, in bytes,
+	// an envelope can carry.
+	MaxEnvelopeContentType = 255
+)
+
+// Envelope wraps the CANON_BYTES of v with a content-type tag, which may
+// be empty.  The tag must be valid UTF-8 (ERR_UTF8) of at most
+// MaxEnvelopeContentType bytes (ERR_SCHEMA).
+func Envelope(v Value, contentType string) ([]byte, error) {
+	if err := checkContentType(contentType); err != nil {
+		return nil, err
+	}
+	canon, err := CanonBytesFromValue(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(envelopeMagic)+2+len(contentType)+len(canon))
+	out = append(out, envelopeMagic...)
+	out = append(out, envelopeVersion, byte(len(contentType)))
+	out = append(out, contentType...)
+	return append(out, canon...), nil
+}
+
+// OpenEnvelope unwraps an envelope built by Envelope, returning the
+// decoded value and its content type.  A bad magic or unknown version
+// is ERR_CANON_HDR and truncated framing is ERR_CANON_MCF; the inner
+// CANON_BYTES are then validated exactly as MIDFromCanonBytes does.
+func OpenEnvelope(b []byte) (Value, string, error) {
+	if !bytes.HasPrefix(b, envelopeMagic) {
+		return nil, "", newErr(ErrCanonHdr, "bad envelope magic")
+	}
+	off := len(envelopeMagic)
+	if off+2 > len(b) {
+		return nil, "", newErr(ErrCanonMCF, "truncated envelope header")
+	}
+	if b[off] != envelopeVersion {
+		return nil, "", newErr(ErrCanonHdr, "unsupported envelope version")
+	}
+	n := int(b[off+1])
+	off += 2
+	if off+n > len(b) {
+		return nil, "", newErr(ErrCanonMCF, "truncated envelope content type")
+	}
+	contentType := string(b[off : off+n])
+	if err := checkContentType(contentType); err != nil {
+		return nil, "", err
+	}
+
+	canon := b[off+n:]
+	if !bytes.HasPrefix(canon, canonHdr) {
+		return nil, "", newErr(ErrCanonHdr, "bad CANON_HDR")
+	}
+	if len(canon) > MaxCanonBytes {
+		return nil, "", newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	v, err := decodeRoot(canon, len(canonHdr))
+	if err != nil {
+		return nil, "", err
+	}
+	return v, contentType, nil
+}
+
+func checkContentType(contentType string) error {
+	if len(contentType) > MaxEnvelopeContentType {
+		return newErr(ErrSchema, "envelope content type too long")
+	}
+	return validateUTF8Scalar([]byte(contentType))
+}
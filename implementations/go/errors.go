@@ -17,16 +17,24 @@ const (
 
 // MapError is the canonical error type for MAP v1 processing.
 // Conformance tests compare the Code field against ERR_* strings.
+//
+// Path, when set, is the JSON Pointer of the offending node.  It is
+// diagnostic only and never affects the reported code.
 type MapError struct {
 	Code string
 	Msg  string
+	Path string
 }
 
 func (e *MapError) Error() string {
+	s := e.Code
+	if e.Path != "" {
+		s += " at " + e.Path
+	}
 	if e.Msg != "" {
-		return fmt.Sprintf("%s: %s", e.Code, e.Msg)
+		s += ": " + e.Msg
 	}
-	return e.Code
+	return s
 }
 
 func newErr(code, msg string) *MapError {
@@ -55,9 +63,29 @@ func init() {
 	}
 }
 
+// Precedence returns the §6.2 rank of an error code (lower wins).
+// ok is false for codes not defined by the spec.
+func Precedence(code string) (int, bool) {
+	idx, ok := precIndex[code]
+	return idx, ok
+}
+
+// Precedence returns the §6.2 rank of the error's code (lower wins).
+// Unknown codes rank after every spec-defined code.
+func (e *MapError) Precedence() int {
+	if idx, ok := precIndex[e.Code]; ok {
+		return idx
+	}
+	return len(precedence)
+}
+
 // ChooseReportedError returns the highest-precedence code from a set
-// of detected violations (§6.2 reported-code rule).
+// of detected violations (§6.2 reported-code rule), or "" for an empty
+// set.
 func ChooseReportedError(codes []string) string {
+	if len(codes) == 0 {
+		return ""
+	}
 	best := codes[0]
 	bestIdx := precIndex[best]
 	for _, c := range codes[1:] {
@@ -68,3 +96,61 @@ func ChooseReportedError(codes []string) string {
 	}
 	return best
 }
+
+// MultiError collects every violation found in a value rather than
+// stopping at the first one.  See ValidateAll, which never returns an
+// empty one; the zero value holds no violations and has Code "".
+type MultiError struct {
+	errs []*MapError
+}
+
+// Errors returns the collected violations in detection order.
+func (m *MultiError) Errors() []*MapError {
+	return m.errs
+}
+
+// Code returns the code the spec would report for this set (§6.2), or
+// "" if it is empty.
+func (m *MultiError) Code() string {
+	codes := make([]string, len(m.errs))
+	for i, e := range m.errs {
+		codes[i] = e.Code
+	}
+	return ChooseReportedError(codes)
+}
+
+func (m *MultiError) Error() string {
+	switch len(m.errs) {
+	case 0:
+		return "no violations"
+	case 1:
+		return m.errs[0].Error()
+	}
+	return fmt.Sprintf("%d violations; reported %s", len(m.errs), m.Code())
+}
+
+// reportedError applies the §6.2 reported-code rule to the soft
+// violations a validator collected plus the error that stopped it
+// (hard, possibly nil).  The highest-precedence one wins; ties go to
+// the earliest detected.  Returns nil if there is nothing to report.
+func reportedError(soft []*MapError, hard error) error {
+	var best *MapError
+	for _, e := range soft {
+		if best == nil || e.Precedence() < best.Precedence() {
+			best = e
+		}
+	}
+	if hard != nil {
+		he, ok := hard.(*MapError)
+		if !ok {
+			return hard
+		}
+		if best == nil || he.Precedence() < best.Precedence() {
+			best = he
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best
+}
@@ -0,0 +1,61 @@
+package map1
+
+// MIDFullExcluding is MIDFull of descriptor with the MAP entries at
+// excludePointers removed: identity modulo volatile fields such as
+// "/request_id" or "/timestamp".  Two descriptors that differ only at
+// those pointers get the same MID.
+//
+// Pointers are parsed and de-duplicated as for BIND (rules a, b) and
+// walk MAP levels only; a pointer through a LIST is ERR_SCHEMA.  A
+// pointer that does not resolve, including one that reaches a scalar
+// early, removes nothing.  The empty pointer would exclude the whole
+// descriptor and is ERR_SCHEMA.  Entries are removed before encoding,
+// so violations inside them are not reported.  descriptor itself is
+// left unmodified.
+func MIDFullExcluding(descriptor Value, excludePointers []string) (string, error) {
+	parsed, err := parsePointerSet(excludePointers)
+	if err != nil {
+		return "", err
+	}
+	for _, pp := range parsed {
+		if pp.raw == "" {
+			return "", newErr(ErrSchema, "cannot exclude the whole descriptor")
+		}
+		if descriptor, err = excludePath(descriptor, pp.tokens); err != nil {
+			return "", err
+		}
+	}
+	return MIDFull(descriptor)
+}
+
+// excludePath returns v without the entry at tokens (non-empty),
+// copying each MAP on the way down rather than modifying it.
+func excludePath(v Value, tokens []string) (Value, error) {
+	switch val := v.(type) {
+	case List:
+		return nil, newErr(ErrSchema, "exclude pointer cannot traverse LIST")
+	case *Map:
+		out := &Map{Sorted: val.Sorted}
+		found := false
+		for i, k := range val.Keys {
+			child := val.Values[i]
+			if k == tokens[0] {
+				found = true
+				if len(tokens) == 1 {
+					continue
+				}
+				var err error
+				if child, err = excludePath(child, tokens[1:]); err != nil {
+					return nil, err
+				}
+			}
+			out.Keys = append(out.Keys, k)
+			out.Values = append(out.Values, child)
+		}
+		if !found {
+			return v, nil
+		}
+		return out, nil
+	}
+	return v, nil
+}
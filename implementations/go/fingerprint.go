@@ -0,0 +1,21 @@
+package map1
+
+import "hash/fnv"
+
+// Fingerprint returns a 64-bit FNV-1a digest of v's CANON_BYTES, so
+// values with the same MID share a fingerprint.  It fails exactly when
+// CanonBytesFull would.
+//
+// Fingerprints are collision-prone and trivially forgeable.  Use them
+// only as in-memory hash-bucket keys or a cheap pre-filter before
+// comparing MIDs — never for identity, deduplication or anything
+// security-relevant.
+func Fingerprint(v Value) (uint64, error) {
+	canon, err := CanonBytesFromValue(v)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	h.Write(canon)
+	return h.Sum64(), nil
+}
@@ -0,0 +1,54 @@
+package map1
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Framed CANON_BYTES, for length-delimited streams:
+//
+//	u32be(len(CANON_BYTES)) || CANON_BYTES
+//
+// repeated once per record, so a reader can split records without
+// parsing them.  The framing is a transport convention only, NOT part
+// of MAP v1.1: the MID is always over the inner CANON_BYTES.
+const frameLenSize = 4
+
+// WriteFramedCanonBytes writes v to w as one framed record.  Nothing is
+// written if v does not encode.
+func WriteFramedCanonBytes(w io.Writer, v Value) error {
+	canon, err := CanonBytesFromValue(v)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, frameLenSize, frameLenSize+len(canon))
+	binary.BigEndian.PutUint32(frame, uint32(len(canon)))
+	_, err = w.Write(append(frame, canon...))
+	return err
+}
+
+// ReadFramedCanonBytes reads one framed record from r and returns its
+// decoded value, validated exactly as DecodeCanonBytes validates.
+//
+// At a clean end of stream, before any byte of a frame, it returns
+// io.EOF; a stream ending inside a frame is io.ErrUnexpectedEOF.  A
+// declared length over MAX_CANON_BYTES is ERR_LIMIT_SIZE, returned
+// before the record is read, and leaves r inside that record.
+func ReadFramedCanonBytes(r io.Reader) (Value, error) {
+	var hdr [frameLenSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n > MaxCanonBytes {
+		return nil, newErr(ErrLimitSize, "frame length exceeds MAX_CANON_BYTES")
+	}
+	canon := make([]byte, n)
+	if _, err := io.ReadFull(r, canon); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return DecodeCanonBytes(canon)
+}
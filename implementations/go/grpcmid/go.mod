@@ -0,0 +1,18 @@
+module github.com/map-protocol/map1/implementations/go/grpcmid
+
+go 1.22
+
+require (
+	github.com/map-protocol/map1/implementations/go v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.66.3
+	google.golang.org/protobuf v1.34.1
+)
+
+require (
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240604185151-ef581f913117 // indirect
+)
+
+replace github.com/map-protocol/map1/implementations/go => ../
@@ -0,0 +1,50 @@
+// Package grpcmid provides a gRPC unary server interceptor that
+// computes the MID of each request and stores it in the handler's
+// context, where midctx.FromContext reads it back.
+//
+// It is a module of its own so that the gRPC and protobuf dependencies
+// stay out of the core module; everything MAP-specific lives in
+// midctx, which has none.
+//
+// Requests are rendered with protojson and hashed as JSON-STRICT, so
+// the MID follows protojson's JSON mapping: fields by their JSON
+// names, int64 and uint64 as decimal strings, bytes as base64 strings.
+// A float or double field holding a non-integral value, or any
+// google.protobuf.Value null, cannot be hashed (ERR_TYPE).
+package grpcmid
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/map-protocol/map1/implementations/go/midctx"
+)
+
+// UnaryMIDInterceptor returns an interceptor that computes the FULL
+// MID of each request message and calls the handler with it in the
+// context.  A request whose MID cannot be computed is rejected with
+// codes.InvalidArgument without reaching the handler.
+func UnaryMIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+		mid, err := midctx.RequestMID(req, marshal)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "request MID: "+err.Error())
+		}
+		return h(midctx.NewContext(ctx, mid), req)
+	}
+}
+
+// marshal renders a protobuf request as JSON.
+func marshal(req any) ([]byte, error) {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a protobuf message", req)
+	}
+	return protojson.Marshal(m)
+}
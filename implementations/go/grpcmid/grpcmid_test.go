@@ -0,0 +1,74 @@
+package grpcmid_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+	"github.com/map-protocol/map1/implementations/go/grpcmid"
+	"github.com/map-protocol/map1/implementations/go/midctx"
+)
+
+// healthServer records the MID each Check call finds in its context.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	mids []string
+}
+
+func (s *healthServer) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	mid, ok := midctx.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "no MID in context")
+	}
+	s.mids = append(s.mids, mid)
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func TestUnaryMIDInterceptor(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(grpcmid.UnaryMIDInterceptor()))
+	hs := &healthServer{}
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	for _, svc := range []string{"billing", "billing", ""} {
+		if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: svc}); err != nil {
+			t.Fatalf("Check(%q): %v", svc, err)
+		}
+	}
+	billing, _ := map1.MIDFullJSON([]byte(`{"service":"billing"}`))
+	empty, _ := map1.MIDFullJSON([]byte(`{}`))
+	if len(hs.mids) != 3 || hs.mids[0] != billing || hs.mids[1] != billing || hs.mids[2] != empty {
+		t.Errorf("MIDs = %v, want [%s %s %s]", hs.mids, billing, billing, empty)
+	}
+}
+
+func TestUnaryMIDInterceptorRejects(t *testing.T) {
+	called := false
+	h := func(context.Context, any) (any, error) {
+		called = true
+		return nil, nil
+	}
+	_, err := grpcmid.UnaryMIDInterceptor()(context.Background(), "not a message", &grpc.UnaryServerInfo{}, h)
+	if status.Code(err) != codes.InvalidArgument || called {
+		t.Errorf("non-proto request: err = %v, handler called = %v", err, called)
+	}
+}
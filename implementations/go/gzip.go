@@ -0,0 +1,47 @@
+package map1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// CanonBytesGzipped returns gzip-compressed CANON_BYTES for v.  The
+// compression is a storage convenience only; the MID is always over
+// the uncompressed bytes.
+func CanonBytesGzipped(v Value) ([]byte, error) {
+	canon, err := CanonBytesFromValue(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(canon); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MIDFromGzippedCanonBytes decompresses gz and validates the result as
+// CANON_BYTES, returning the same MID MIDFromCanonBytes would.
+//
+// Decompression stops one byte past MAX_CANON_BYTES, so a zip bomb
+// costs at most that much memory before failing with ERR_LIMIT_SIZE.
+// A malformed gzip stream is ERR_CANON_MCF.
+func MIDFromGzippedCanonBytes(gz []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return "", newErr(ErrCanonMCF, "gzip: "+err.Error())
+	}
+	defer zr.Close()
+	canon, err := io.ReadAll(io.LimitReader(zr, MaxCanonBytes+1))
+	if err != nil {
+		return "", newErr(ErrCanonMCF, "gzip: "+err.Error())
+	}
+	// An over-long result is rejected by MIDFromCanonBytes, after the
+	// header check it outranks.
+	return MIDFromCanonBytes(canon)
+}
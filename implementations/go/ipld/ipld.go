@@ -0,0 +1,76 @@
+// Package ipld bridges MAP identity into IPLD / content-addressed DAG
+// ecosystems by exposing the MAP codec with CIDv1 addressing.
+//
+// A MAP CID is CIDv1(codec=Codec, multihash=sha2-256(CANON_BYTES)).
+// The digest is the same one inside the MID, so a CID and a MID over
+// the same value always agree.
+//
+// Kept as a separate package so the core stays dependency-free; it
+// uses only the standard library.
+package ipld
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"strings"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+)
+
+// Codec is the multicodec code used for MAP CANON_BYTES.  MAP has no
+// entry in the multicodec table yet, so this sits in the private-use
+// range (0x300000–0x3fffff).  It will change if a code is registered.
+const Codec uint64 = 0x300001
+
+// Multihash / CID constants.
+const (
+	cidV1       uint64 = 0x01
+	mhSHA2_256  uint64 = 0x12
+	sha256Len          = 32
+	base32Lower        = 'b' // multibase prefix
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Encode serializes v to CANON_BYTES (the codec's block format).
+func Encode(v map1.Value) ([]byte, error) {
+	return map1.CanonBytesFull(v)
+}
+
+// Decode validates a CANON_BYTES block and decodes it to a Value.
+func Decode(block []byte) (map1.Value, error) {
+	if _, err := map1.MIDFromCanonBytes(block); err != nil {
+		return nil, err
+	}
+	v, _, err := map1.DecodeMCF(block[5:])
+	return v, err
+}
+
+// CIDFromValue returns the base32 string form of v's CIDv1.
+func CIDFromValue(v map1.Value) (string, error) {
+	canon, err := map1.CanonBytesFull(v)
+	if err != nil {
+		return "", err
+	}
+	return cidString(canon), nil
+}
+
+// CIDFromCanonBytes validates canon and returns its CIDv1 string.
+func CIDFromCanonBytes(canon []byte) (string, error) {
+	if _, err := map1.MIDFromCanonBytes(canon); err != nil {
+		return "", err
+	}
+	return cidString(canon), nil
+}
+
+func cidString(canon []byte) string {
+	digest := sha256.Sum256(canon)
+	var b []byte
+	b = binary.AppendUvarint(b, cidV1)
+	b = binary.AppendUvarint(b, Codec)
+	b = binary.AppendUvarint(b, mhSHA2_256)
+	b = binary.AppendUvarint(b, sha256Len)
+	b = append(b, digest[:]...)
+	return string(base32Lower) + strings.ToLower(b32.EncodeToString(b))
+}
@@ -0,0 +1,42 @@
+package ipld_test
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+	"github.com/map-protocol/map1/implementations/go/ipld"
+)
+
+func TestCIDMatchesMID(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "a", Value: map1.Integer(1)})
+	cid, err := ipld.CIDFromValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cid, "b") {
+		t.Fatalf("expected base32 multibase prefix, got %s", cid)
+	}
+	raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(cid[1:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid, _ := map1.MIDFull(v)
+	if got := hex.EncodeToString(raw[len(raw)-32:]); "map1:"+got != mid {
+		t.Errorf("CID digest %s does not match %s", got, mid)
+	}
+
+	block, _ := ipld.Encode(v)
+	back, err := ipld.Decode(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := map1.MIDFull(back); again != mid {
+		t.Errorf("decode round-trip changed MID")
+	}
+	if _, err := ipld.Decode(block[:len(block)-1]); err == nil {
+		t.Error("expected error for truncated block")
+	}
+}
@@ -2,115 +2,251 @@ package map1
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"strconv"
 	"strings"
 )
 
 // MIDFullJSON computes MID from raw UTF-8 JSON bytes (JSON-STRICT + FULL).
+// JSON strings always become STRING; the adapter never produces BYTES,
+// so `""` hashes as String(""), not Bytes(nil).
+//
+// Any JSON value is a valid FULL root, including a bare scalar: `42`
+// hashes as Integer(42) and `"hello"` as String("hello"), matching the
+// BOOL_STANDALONE/INT_STANDALONE vectors.  A bare null is ERR_TYPE.
 func MIDFullJSON(raw []byte) (string, error) {
-	val, dupFound, err := jsonStrictParse(raw)
-	if err != nil {
-		return "", err
-	}
-	canon, err := CanonBytesFromValue(val)
+	return MIDFullJSONWithOptions(raw, LenientOptions{})
+}
+
+// MIDBindJSON computes MID from raw UTF-8 JSON bytes (JSON-STRICT + BIND).
+// Unlike FULL, BIND requires a MAP root: a top-level array or scalar is
+// ERR_SCHEMA (§2.1).
+func MIDBindJSON(raw []byte, pointers []string) (string, error) {
+	return MIDBindJSONWithOptions(raw, pointers, LenientOptions{})
+}
+
+// CanonBytesAndMIDFromJSON returns the CANON_BYTES and FULL MID of raw
+// from one JSON-STRICT parse and one encode, for callers that store
+// the bytes and index by MID.  The MID equals MIDFullJSON(raw) and the
+// errors are the same.
+func CanonBytesAndMIDFromJSON(raw []byte) (canon []byte, mid string, err error) {
+	canon, err = jsonCanonBytes(raw)
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
-	// Raise dup_key only if no higher-precedence error already fired.
-	if dupFound {
-		return "", newErr(ErrDupKey, "duplicate key in JSON")
+	return canon, midOfCanon(canon), nil
+}
+
+// LenientOptions opts the JSON adapter into conventions beyond
+// JSON-STRICT (§8).  They are private to this implementation, NOT part
+// of MAP v1.1: other implementations will reject or hash such input
+// differently.  The zero value is JSON-STRICT.
+type LenientOptions struct {
+	// ByteStringTag, if set, makes an object whose only member is named
+	// ByteStringTag — {"$bytes": "<base64>"} for tag "$bytes" — decode
+	// to BYTES instead of a MAP.  The member value must be a string of
+	// standard padded base64 (RFC 4648 §4); anything else, or other
+	// members alongside the tag, is ERR_SCHEMA.  While parsing, the
+	// tagged object counts as a nesting level against MAX_DEPTH.
+	ByteStringTag string
+
+	// NullAsNull reads JSON null as the NULL extension (see Null)
+	// instead of rejecting it with ERR_TYPE, and encodes with
+	// EncodeOptions.AllowNull.  The MID is over extended bytes.
+	NullAsNull bool
+
+	// RejectEmptyKeys makes an object member named "" ERR_SCHEMA, as
+	// EncodeOptions.RejectEmptyKeys does.  Unlike the other options it
+	// only narrows JSON-STRICT: a MID it allows is a MAP v1.1 MID.
+	RejectEmptyKeys bool
+
+	// MaxTotalNodes, if positive, caps the number of JSON values parsed
+	// in total, as DecodeOptions.MaxTotalNodes does: parsing stops with
+	// ERR_LIMIT_SIZE at the first value past the cap.  Like
+	// RejectEmptyKeys it only narrows JSON-STRICT.
+	MaxTotalNodes int
+}
+
+func (o LenientOptions) encodeOptions() EncodeOptions {
+	return EncodeOptions{AllowNull: o.NullAsNull, RejectEmptyKeys: o.RejectEmptyKeys}
+}
+
+// MIDFullJSONWithOptions is MIDFullJSON with lenient parsing options.
+func MIDFullJSONWithOptions(raw []byte, opts LenientOptions) (string, error) {
+	val, soft, err := jsonParse(raw, opts)
+	if err != nil || len(soft) > 0 {
+		return "", reportedError(soft, err)
 	}
-	return "map1:" + sha256hex(canon), nil
+	return MIDFromValueWithOptions(val, opts.encodeOptions())
 }
 
-// MIDBindJSON computes MID from raw UTF-8 JSON bytes (JSON-STRICT + BIND).
-func MIDBindJSON(raw []byte, pointers []string) (string, error) {
-	val, dupFound, err := jsonStrictParse(raw)
+// MIDBindJSONWithOptions is MIDBindJSON with lenient parsing options.
+func MIDBindJSONWithOptions(raw []byte, pointers []string, opts LenientOptions) (string, error) {
+	val, soft, err := jsonParse(raw, opts)
 	if err != nil {
-		return "", err
+		return "", reportedError(soft, err)
+	}
+	// BIND errors are ERR_SCHEMA and can outrank what the parse
+	// recorded, so project even a tree with soft violations.  Rejected
+	// scalars were parsed as placeholders, which keeps match status
+	// intact; a rejected root is not projected.
+	if len(soft) > 0 {
+		if _, ok := val.(*Map); ok {
+			_, err = BindProject(val, pointers)
+		}
+		return "", reportedError(soft, err)
 	}
 	proj, err := BindProject(val, pointers)
 	if err != nil {
 		return "", err
 	}
-	canon, err := CanonBytesFromValue(proj)
-	if err != nil {
-		return "", err
+	return MIDFromValueWithOptions(proj, opts.encodeOptions())
+}
+
+// ValueFromRawMessage parses one pre-captured JSON fragment under
+// JSON-STRICT rules (§8) and returns its canonical value, so a
+// descriptor can be assembled from fragments and hashed as a whole.
+// Floats, nulls, surrogates and duplicate keys are rejected exactly as
+// MIDFullJSON would reject them.
+func ValueFromRawMessage(m json.RawMessage) (Value, error) {
+	val, soft, err := jsonStrictParse(m)
+	if err != nil || len(soft) > 0 {
+		return nil, reportedError(soft, err)
 	}
-	if dupFound {
-		return "", newErr(ErrDupKey, "duplicate key in JSON")
+	return val, nil
+}
+
+// RawEntry builds a MapEntry whose value is the strictly parsed JSON
+// fragment raw.  See ValueFromRawMessage.
+func RawEntry(key string, raw json.RawMessage) (MapEntry, error) {
+	v, err := ValueFromRawMessage(raw)
+	if err != nil {
+		return MapEntry{}, err
 	}
-	return "map1:" + sha256hex(canon), nil
+	return MapEntry{Key: key, Value: v}, nil
 }
 
-// jsonStrictParse parses raw JSON under JSON-STRICT rules (§8).
-// Returns (canonical_value, dup_found, error).
+// jsonPlaceholder stands in for a value the parser rejected but parsed
+// past (null, float, out-of-range integer) so the surrounding structure
+// stays intact.  It never reaches a caller.
+var jsonPlaceholder Value = Bool(false)
+
+// jsonParser walks a json.Decoder token stream.  Violations that do not
+// break the parse (BOM, surrogates, null, floats, integer range,
+// duplicate keys) are recorded in soft and parsing continues, so the
+// §6.2 winner can be chosen from every determinable violation.  Syntax
+// errors and MAX_DEPTH stop the parse.
 //
-// Duplicate detection is deferred: we record the flag and keep parsing
-// so higher-precedence errors (ERR_TYPE, ERR_UTF8) can surface first.
-func jsonStrictParse(raw []byte) (Value, bool, error) {
-	if len(raw) > MaxCanonBytes {
-		return nil, false, newErr(ErrLimitSize, "input exceeds MAX_CANON_BYTES")
-	}
-
-	// BOM rejection (§8.1.1): check after skipping JSON whitespace.
-	// Reject BOM even if preceded by whitespace.
-	idx := 0
-	for idx < len(raw) {
-		b := raw[idx]
-		if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
-			idx++
-			continue
-		}
-		break
-	}
-	if idx+3 <= len(raw) && raw[idx] == 0xEF && raw[idx+1] == 0xBB && raw[idx+2] == 0xBF {
-		return nil, false, newErr(ErrSchema, "UTF-8 BOM rejected")
-	}
+// Violations tied to a node carry its JSON Pointer in Path.
+type jsonParser struct {
+	dec     *json.Decoder
+	sr      *jsonStrictReader
+	soft    []*MapError
+	opts    LenientOptions
+	nodes   int
+	scratch *jsonScratch // reused buffers (JSONParser); nil allocates afresh
+}
 
-	// Pre-scan for lone surrogate escape sequences (§8.1).
-	// Go's encoding/json silently replaces \uD800–\uDFFF with U+FFFD,
-	// so ensureNoSurrogates() on the decoded string never catches them.
-	// We must detect them at the raw byte level before parsing.
-	if err := scanForSurrogateEscapes(raw); err != nil {
-		return nil, false, err
-	}
+func (p *jsonParser) record(err error) {
+	p.soft = append(p.soft, err.(*MapError))
+}
 
-	// Parse JSON using token-level decoder for duplicate detection.
-	dec := json.NewDecoder(bytes.NewReader(raw))
-	dec.UseNumber()
+// recordAt records err as found at the node path.
+func (p *jsonParser) recordAt(err error, path string) {
+	err.(*MapError).Path = path
+	p.record(err)
+}
 
-	dupFound := false
-	val, err := decodeJSONValue(dec, &dupFound, 1)
-	if err != nil {
-		return nil, false, err
+// atPath returns err with Path set to path.
+func atPath(err *MapError, path string) *MapError {
+	err.Path = path
+	return err
+}
+
+// placeSurrogate gives a surrogate escape the reader recorded the path
+// of the string it lies in.  The reader runs ahead of the decoder, so
+// the escape is only placed once the decoder has consumed the string
+// token containing it; call this after every string token.
+func (p *jsonParser) placeSurrogate(path string) {
+	sr := p.sr
+	if sr == nil || sr.surrogateErr == nil || sr.surrogateAt > p.dec.InputOffset() {
+		return
 	}
+	sr.surrogateErr.Path = path
+	sr.surrogateErr = nil
+}
+
+// jsonStrictParse parses raw JSON under JSON-STRICT rules (§8).
+// Returns the canonical value, the soft violations found, and the
+// error that stopped the parse, if any.  The value is only meaningful
+// when both are empty.
+func jsonStrictParse(raw []byte) (Value, []*MapError, error) {
+	return jsonParse(raw, LenientOptions{})
+}
+
+// jsonParse is jsonStrictParse with lenient options applied.
+func jsonParse(raw []byte, opts LenientOptions) (Value, []*MapError, error) {
+	return jsonParseReader(bytes.NewReader(raw), opts)
+}
+
+// jsonParseReader is jsonParse over a stream.  The BOM, surrogate
+// escape and size checks run on the bytes as the decoder pulls them
+// (see jsonStrictReader), so r is read once, front to back, and never
+// buffered whole.
+func jsonParseReader(r io.Reader, opts LenientOptions) (Value, []*MapError, error) {
+	p := &jsonParser{opts: opts}
+	return p.parse(newJSONStrictReader(r, p))
+}
 
-	// Check for trailing non-whitespace after the root value.
-	// json.Decoder might leave extra tokens in the stream.
-	tok, err2 := dec.Token()
-	if err2 == nil {
-		// There's another token — that's ERR_CANON_MCF (two roots, etc.).
-		_ = tok
-		return nil, false, newErr(ErrCanonMCF, "trailing JSON content")
+// parse reads one JSON document from sr, which must feed p.
+func (p *jsonParser) parse(sr *jsonStrictReader) (Value, []*MapError, error) {
+	p.sr = sr
+
+	// Parse JSON using token-level decoder for duplicate detection.
+	p.dec = json.NewDecoder(sr)
+	p.dec.UseNumber()
+
+	val, err := p.value(1, "")
+	if err == nil {
+		// Check for trailing non-whitespace after the root value.
+		// json.Decoder might leave extra tokens in the stream.
+		if _, terr := p.dec.Token(); terr != io.EOF {
+			// Another token (two roots, etc.) or a parse error in
+			// trailing content — either way ERR_CANON_MCF.
+			err = newErr(ErrCanonMCF, "trailing JSON content")
+		}
 	}
-	// err2 should be io.EOF for well-formed single-root JSON.
-	if err2 != io.EOF {
-		// Some parse error in trailing content.
-		return nil, false, newErr(ErrCanonMCF, "JSON parse error in trailing content")
+	if err != nil {
+		// The parse may have stopped short of the size limit; read on
+		// to it, so an oversized input is ERR_LIMIT_SIZE whatever else
+		// is wrong with it.
+		if sr.readErr == nil {
+			sr.drain()
+		}
+		if sr.overLimit {
+			return nil, sr.oversizeSoft(), sr.readErr
+		}
+		// A failed read surfaces from the decoder as a parse error;
+		// report what actually stopped the stream.
+		if sr.readErr != nil {
+			err = sr.readErr
+		}
+		return nil, p.soft, err
 	}
-
-	return val, dupFound, nil
+	return val, p.soft, nil
 }
 
-// decodeJSONValue recursively decodes one JSON value from the decoder.
-// depth tracks container nesting for the canonical model (root MAP/LIST = 1).
-func decodeJSONValue(dec *json.Decoder, dupFound *bool, depth int) (Value, error) {
-	tok, err := dec.Token()
+// value recursively decodes one JSON value from the decoder.
+// depth tracks container nesting for the canonical model (root MAP/LIST = 1);
+// path is the value's JSON Pointer ("" at the root).
+func (p *jsonParser) value(depth int, path string) (Value, error) {
+	if p.nodes++; p.opts.MaxTotalNodes > 0 && p.nodes > p.opts.MaxTotalNodes {
+		return nil, atPath(newErr(ErrLimitSize, "node count exceeds MaxTotalNodes"), path)
+	}
+	tok, err := p.dec.Token()
 	if err != nil {
 		// Distinguish JSON syntax errors from EOF.
 		if err == io.EOF {
@@ -124,17 +260,18 @@ func decodeJSONValue(dec *json.Decoder, dupFound *bool, depth int) (Value, error
 	case json.Delim:
 		switch v {
 		case '{':
-			return decodeJSONObject(dec, dupFound, depth)
+			return p.object(depth, path)
 		case '[':
-			return decodeJSONArray(dec, dupFound, depth)
+			return p.array(depth, path)
 		default:
 			return nil, newErr(ErrCanonMCF, "unexpected delimiter")
 		}
 
 	case string:
 		// Check for surrogates in the decoded string.
+		p.placeSurrogate(path)
 		if err := ensureNoSurrogates(v); err != nil {
-			return nil, err
+			p.recordAt(err, path)
 		}
 		return String(v), nil
 
@@ -142,31 +279,51 @@ func decodeJSONValue(dec *json.Decoder, dupFound *bool, depth int) (Value, error
 		return Bool(v), nil
 
 	case json.Number:
-		return convertJSONNumber(v)
+		val, err := convertJSONNumber(v)
+		if err != nil {
+			if err.(*MapError).Code == ErrCanonMCF {
+				return nil, err
+			}
+			p.recordAt(err, path)
+			return jsonPlaceholder, nil
+		}
+		return val, nil
 
 	case nil:
+		if p.opts.NullAsNull {
+			return Null{}, nil
+		}
 		// JSON null → ERR_TYPE.
-		return nil, newErr(ErrType, "JSON null not allowed")
+		p.recordAt(newErr(ErrType, "JSON null not allowed"), path)
+		return jsonPlaceholder, nil
 
 	default:
 		return nil, newErr(ErrSchema, fmt.Sprintf("unexpected JSON type: %T", tok))
 	}
 }
 
-// decodeJSONObject decodes a JSON object with duplicate key detection.
+// object decodes a JSON object with duplicate key detection.
 // The opening '{' has already been consumed.
-func decodeJSONObject(dec *json.Decoder, dupFound *bool, depth int) (Value, error) {
+func (p *jsonParser) object(depth int, path string) (Value, error) {
 	if depth > MaxDepth {
-		return nil, newErr(ErrLimitDepth, "exceeds MAX_DEPTH")
+		return nil, atPath(newErr(ErrLimitDepth, "exceeds MAX_DEPTH"), path)
 	}
 
-	keys := make([]string, 0, 8)
-	vals := make([]Value, 0, 8)
-	seen := make(map[string]bool, 8)
+	var keys []string
+	var vals []Value
+	var seen map[string]bool
+	if p.scratch != nil {
+		keys, vals, seen = p.scratch.enterObject()
+		defer p.scratch.leave()
+	} else {
+		keys = make([]string, 0, 8)
+		vals = make([]Value, 0, 8)
+		seen = make(map[string]bool, 8)
+	}
 
-	for dec.More() {
+	for p.dec.More() {
 		// Read key token.
-		kTok, err := dec.Token()
+		kTok, err := p.dec.Token()
 		if err != nil {
 			return nil, newErr(ErrCanonMCF, "JSON parse error reading key")
 		}
@@ -174,18 +331,22 @@ func decodeJSONObject(dec *json.Decoder, dupFound *bool, depth int) (Value, erro
 		if !ok {
 			return nil, newErr(ErrSchema, "JSON key is not a string")
 		}
+		childPath := path + "/" + escapePointerToken(key)
+		p.placeSurrogate(childPath)
 		if err := ensureNoSurrogates(key); err != nil {
-			return nil, err
+			p.recordAt(err, childPath)
+		}
+		if key == "" && p.opts.RejectEmptyKeys {
+			p.recordAt(newErr(ErrSchema, "empty MAP key (RejectEmptyKeys)"), childPath)
 		}
 
 		// Duplicate detection after escape resolution (§8.3).
 		// json.Decoder has already resolved \uXXXX escapes.
 		if seen[key] {
-			*dupFound = true
+			p.recordAt(newErr(ErrDupKey, "duplicate key in JSON"), childPath)
 			// Keep parsing to find higher-precedence errors, but skip this value.
 			childDepth := depth // don't increment for the skipped value's children
-			_, err := decodeJSONValue(dec, dupFound, childDepth)
-			if err != nil {
+			if _, err := p.value(childDepth, childPath); err != nil {
 				return nil, err
 			}
 			continue
@@ -194,7 +355,7 @@ func decodeJSONObject(dec *json.Decoder, dupFound *bool, depth int) (Value, erro
 
 		// Compute child depth: only containers increment.
 		childDepth := depth + 1
-		val, err := decodeJSONValue(dec, dupFound, childDepth)
+		val, err := p.value(childDepth, childPath)
 		if err != nil {
 			return nil, err
 		}
@@ -203,7 +364,7 @@ func decodeJSONObject(dec *json.Decoder, dupFound *bool, depth int) (Value, erro
 	}
 
 	// Consume closing '}'.
-	tok, err := dec.Token()
+	tok, err := p.dec.Token()
 	if err != nil {
 		return nil, newErr(ErrCanonMCF, "JSON parse error: missing '}'")
 	}
@@ -211,20 +372,53 @@ func decodeJSONObject(dec *json.Decoder, dupFound *bool, depth int) (Value, erro
 		return nil, newErr(ErrCanonMCF, "expected '}'")
 	}
 
+	if p.opts.ByteStringTag != "" && seen[p.opts.ByteStringTag] {
+		return p.taggedBytes(keys, vals, path), nil
+	}
+	if p.scratch != nil {
+		return p.scratch.newMap(keys, vals), nil
+	}
 	return &Map{Keys: keys, Values: vals}, nil
 }
 
-// decodeJSONArray decodes a JSON array.
+// taggedBytes decodes an object carrying LenientOptions.ByteStringTag
+// to BYTES.  A malformed one is recorded as ERR_SCHEMA and replaced by
+// a placeholder.
+func (p *jsonParser) taggedBytes(keys []string, vals []Value, path string) Value {
+	if len(keys) != 1 {
+		p.recordAt(newErr(ErrSchema, p.opts.ByteStringTag+" object must have exactly one member"), path)
+		return jsonPlaceholder
+	}
+	s, ok := vals[0].(String)
+	if !ok {
+		p.recordAt(newErr(ErrSchema, p.opts.ByteStringTag+" value must be a base64 string"), path)
+		return jsonPlaceholder
+	}
+	b, err := base64.StdEncoding.Strict().DecodeString(string(s))
+	if err != nil {
+		p.recordAt(newErr(ErrSchema, p.opts.ByteStringTag+" value is not valid base64"), path)
+		return jsonPlaceholder
+	}
+	return Bytes(b)
+}
+
+// array decodes a JSON array.
 // The opening '[' has already been consumed.
-func decodeJSONArray(dec *json.Decoder, dupFound *bool, depth int) (Value, error) {
+func (p *jsonParser) array(depth int, path string) (Value, error) {
 	if depth > MaxDepth {
-		return nil, newErr(ErrLimitDepth, "exceeds MAX_DEPTH")
+		return nil, atPath(newErr(ErrLimitDepth, "exceeds MAX_DEPTH"), path)
 	}
 
-	arr := make(List, 0, 8)
-	for dec.More() {
+	var arr List
+	if p.scratch != nil {
+		arr = p.scratch.enterArray()
+		defer p.scratch.leave()
+	} else {
+		arr = make(List, 0, 8)
+	}
+	for p.dec.More() {
 		childDepth := depth + 1
-		val, err := decodeJSONValue(dec, dupFound, childDepth)
+		val, err := p.value(childDepth, path+"/"+strconv.Itoa(len(arr)))
 		if err != nil {
 			return nil, err
 		}
@@ -232,7 +426,7 @@ func decodeJSONArray(dec *json.Decoder, dupFound *bool, depth int) (Value, error
 	}
 
 	// Consume closing ']'.
-	tok, err := dec.Token()
+	tok, err := p.dec.Token()
 	if err != nil {
 		return nil, newErr(ErrCanonMCF, "JSON parse error: missing ']'")
 	}
@@ -240,6 +434,9 @@ func decodeJSONArray(dec *json.Decoder, dupFound *bool, depth int) (Value, error
 		return nil, newErr(ErrCanonMCF, "expected ']'")
 	}
 
+	if p.scratch != nil {
+		return p.scratch.newList(arr), nil
+	}
 	return arr, nil
 }
 
@@ -255,23 +452,47 @@ func convertJSONNumber(n json.Number) (Value, error) {
 
 	// Condition (a)/(b): reject if decimal point or exponent present.
 	if strings.ContainsAny(s, ".eE") {
-		return nil, newErr(ErrType, "JSON float not allowed: "+s)
+		return nil, newErr(ErrType, "JSON float not allowed: "+s+
+			" (MAP has no floating-point type; send an integer, e.g. in scaled units, or a string)")
+	}
+
+	// Anything else must match the RFC 8259 integer grammar exactly.
+	// encoding/json already enforces this, but we don't want the
+	// accepted set to hinge on ParseInt, which would take "+1".
+	if !isJSONIntToken(s) {
+		return nil, newErr(ErrCanonMCF, "malformed JSON number: "+s)
 	}
 
-	// Parse as signed 64-bit integer.
+	// Parse as signed 64-bit integer.  The token is known to be
+	// well-formed, so the only possible error is ErrRange: anything
+	// outside [MinInt64, MaxInt64], both ends inclusive.
 	val, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
 		// Range overflow → ERR_TYPE (not ERR_CANON_MCF).
-		return nil, newErr(ErrType, "integer overflow: "+s)
-	}
-	// Additional range check (strconv.ParseInt already handles int64 range,
-	// but be explicit for clarity).
-	if val < math.MinInt64 || val > math.MaxInt64 {
 		return nil, newErr(ErrType, "integer out of int64 range: "+s)
 	}
 	return Integer(val), nil
 }
 
+// isJSONIntToken reports whether s matches -?(0|[1-9][0-9]*).
+func isJSONIntToken(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	if s[0] == '0' {
+		return len(s) == 1
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // ensureNoSurrogates checks for surrogate code points in a decoded string.
 // Go's encoding/json decoder can produce surrogates from \uD800-\uDFFF
 // escape sequences (it decodes them as replacement characters or passes
@@ -284,48 +505,3 @@ func ensureNoSurrogates(s string) error {
 	}
 	return nil
 }
-
-// scanForSurrogateEscapes scans raw JSON bytes for \uD800–\uDFFF escape
-// sequences.  Go's encoding/json silently converts these to U+FFFD, so
-// we must catch them here before the decoder swallows them.
-//
-// A high surrogate (\uD800–\uDBFF) followed by a low surrogate (\uDC00–\uDFFF)
-// is a valid surrogate pair — but JSON-STRICT still rejects them because
-// JSON text is UTF-8, and surrogates are only meaningful in UTF-16.
-func scanForSurrogateEscapes(raw []byte) error {
-	inString := false
-	i := 0
-	for i < len(raw) {
-		b := raw[i]
-		if !inString {
-			if b == '"' {
-				inString = true
-			}
-			i++
-			continue
-		}
-		// Inside a string.
-		if b == '\\' {
-			i++
-			if i >= len(raw) {
-				break
-			}
-			if raw[i] == 'u' && i+4 < len(raw) {
-				hex := string(raw[i+1 : i+5])
-				cp, err := strconv.ParseUint(hex, 16, 16)
-				if err == nil && cp >= 0xD800 && cp <= 0xDFFF {
-					return newErr(ErrUTF8, fmt.Sprintf("surrogate escape \\u%s", hex))
-				}
-				i += 5
-				continue
-			}
-			i++
-			continue
-		}
-		if b == '"' {
-			inString = false
-		}
-		i++
-	}
-	return nil
-}
@@ -0,0 +1,174 @@
+package map1
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+// CanonicalizeJSON re-emits raw, parsed under JSON-STRICT rules (§8),
+// in a canonical JSON form: no insignificant whitespace, object members
+// in MAP canonical key order (§3.5, UTF-8 bytes — not the UTF-16 order
+// of RFC 8785), integers in plain decimal, and strings escaping only
+// '"', '\\' and control characters (\b \f \n \r \t, else \u00XX).
+// Inputs with the same MID canonicalize to the same bytes.  Rejects
+// exactly what MIDFullJSON rejects, with the same codes.
+func CanonicalizeJSON(raw []byte) ([]byte, error) {
+	val, err := canonicalJSONValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	e := jsonEmitter{}
+	e.value(val)
+	return e.buf, nil
+}
+
+// IsCanonicalJSON reports whether raw is already byte-identical to
+// CanonicalizeJSON(raw), so a cache can skip rewriting it.  Errors are
+// those of CanonicalizeJSON.  The comparison stops at the first
+// differing byte and never builds the canonical output.
+func IsCanonicalJSON(raw []byte) (bool, error) {
+	val, err := canonicalJSONValue(raw)
+	if err != nil {
+		return false, err
+	}
+	e := jsonEmitter{want: raw, compare: true}
+	e.value(val)
+	return !e.mismatch && e.pos == len(raw), nil
+}
+
+// JSONMIDEqual reports whether rawA and rawB have the same FULL MID,
+// i.e. MIDFullJSON(rawA) == MIDFullJSON(rawB), by comparing their
+// CANON_BYTES instead of hashing them.  Both inputs are parsed under
+// JSON-STRICT rules; if either is rejected, the error is that of
+// MIDFullJSON, for rawA before rawB.
+func JSONMIDEqual(rawA, rawB []byte) (bool, error) {
+	a, err := jsonCanonBytes(rawA)
+	if err != nil {
+		return false, err
+	}
+	b, err := jsonCanonBytes(rawB)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
+
+func jsonCanonBytes(raw []byte) ([]byte, error) {
+	val, soft, err := jsonStrictParse(raw)
+	if err != nil || len(soft) > 0 {
+		return nil, reportedError(soft, err)
+	}
+	return CanonBytesFromValue(val)
+}
+
+// canonicalJSONValue parses and validates raw as MIDFullJSON does.
+func canonicalJSONValue(raw []byte) (Value, error) {
+	val, soft, err := jsonStrictParse(raw)
+	if err != nil || len(soft) > 0 {
+		return nil, reportedError(soft, err)
+	}
+	if _, err := EncodedSize(val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// jsonEmitter writes canonical JSON, either appending to buf or, in
+// compare mode, matching it against want.
+type jsonEmitter struct {
+	buf      []byte
+	want     []byte
+	compare  bool
+	pos      int
+	mismatch bool
+}
+
+func (e *jsonEmitter) write(s string) {
+	if !e.compare {
+		e.buf = append(e.buf, s...)
+		return
+	}
+	if e.mismatch {
+		return
+	}
+	if len(e.want)-e.pos < len(s) || string(e.want[e.pos:e.pos+len(s)]) != s {
+		e.mismatch = true
+		return
+	}
+	e.pos += len(s)
+}
+
+func (e *jsonEmitter) value(v Value) {
+	if e.mismatch {
+		return
+	}
+	switch val := v.(type) {
+	case String:
+		e.string(string(val))
+	case Integer:
+		e.write(strconv.FormatInt(int64(val), 10))
+	case Bool:
+		e.write(strconv.FormatBool(bool(val)))
+	case List:
+		e.write("[")
+		for i, item := range val {
+			if i > 0 {
+				e.write(",")
+			}
+			e.value(item)
+		}
+		e.write("]")
+	case *Map:
+		order := make([]int, len(val.Keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return val.Keys[order[i]] < val.Keys[order[j]] })
+		e.write("{")
+		for n, i := range order {
+			if n > 0 {
+				e.write(",")
+			}
+			e.string(val.Keys[i])
+			e.write(":")
+			e.value(val.Values[i])
+		}
+		e.write("}")
+	}
+}
+
+func (e *jsonEmitter) string(s string) {
+	const hex = "0123456789abcdef"
+	e.write(`"`)
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		var esc string
+		switch {
+		case c == '"':
+			esc = `\"`
+		case c == '\\':
+			esc = `\\`
+		case c == '\b':
+			esc = `\b`
+		case c == '\f':
+			esc = `\f`
+		case c == '\n':
+			esc = `\n`
+		case c == '\r':
+			esc = `\r`
+		case c == '\t':
+			esc = `\t`
+		case c < 0x20:
+			esc = `\u00` + string(hex[c>>4]) + string(hex[c&0xF])
+		default:
+			continue
+		}
+		e.write(s[start:i])
+		e.write(esc)
+		start = i + 1
+	}
+	e.write(s[start:])
+	e.write(`"`)
+}
@@ -0,0 +1,61 @@
+package map1
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FloatFieldsInJSON returns the JSON Pointer (RFC 6901) of every number
+// in raw that JSON-STRICT rejects as a float (§8.2.1) — one written
+// with a decimal point or exponent — in document order, so a caller
+// can see exactly which fields to fix.  A float at the root is "".
+//
+// Only JSON syntax is checked, and malformed JSON is ERR_CANON_MCF.
+// Other violations are ignored: an empty result does not mean
+// MIDFullJSON will accept raw.
+func FloatFieldsInJSON(raw []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var out []string
+	if err := floatFields(dec, "", &out); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, newErr(ErrCanonMCF, "trailing JSON content")
+	}
+	return out, nil
+}
+
+func floatFields(dec *json.Decoder, path string, out *[]string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return newErr(ErrCanonMCF, "JSON parse error")
+	}
+	switch v := tok.(type) {
+	case json.Number:
+		if strings.ContainsAny(string(v), ".eE") {
+			*out = append(*out, path)
+		}
+	case json.Delim:
+		for i := 0; dec.More(); i++ {
+			child := path + "/" + strconv.Itoa(i)
+			if v == '{' {
+				kTok, err := dec.Token()
+				if err != nil {
+					return newErr(ErrCanonMCF, "JSON parse error reading key")
+				}
+				child = path + "/" + escapePointerToken(kTok.(string))
+			}
+			if err := floatFields(dec, child, out); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return newErr(ErrCanonMCF, "JSON parse error")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package map1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ValueFromDecodedJSON converts a tree already decoded by encoding/json
+// into the canonical model under the JSON-STRICT type rules (§8.2), so
+// a decoded structure can be hashed without re-parsing its bytes.
+//
+// Accepted shapes are those json.Decoder produces into an any:
+// map[string]any → MAP, []any → LIST, string → STRING, bool → BOOLEAN
+// and json.Number → INTEGER.  A json.Number is held to the same rules
+// as a raw token: a decimal point or exponent is ERR_TYPE naming the
+// token, and so is a value outside int64.  Decode with UseNumber:
+// a float64 has lost its token, so it is ERR_TYPE whatever its value.
+// nil is ERR_TYPE; any other Go type is ERR_SCHEMA.  Containers nested
+// deeper than MAX_DEPTH are ERR_LIMIT_DEPTH.
+//
+// Every violation is collected and the §6.2 winner returned, with Path
+// set to the JSON Pointer of a node it was found at.  UTF-8 and key
+// rules are left to encoding, as for any Value.
+func ValueFromDecodedJSON(v any) (Value, error) {
+	c := &nativeConverter{}
+	val := c.value(v, "", 1)
+	if err := reportedError(c.errs, nil); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+type nativeConverter struct {
+	errs []*MapError
+}
+
+func (c *nativeConverter) add(code, path, msg string) Value {
+	c.errs = append(c.errs, &MapError{Code: code, Msg: msg, Path: path})
+	return jsonPlaceholder
+}
+
+func (c *nativeConverter) value(v any, path string, depth int) Value {
+	switch x := v.(type) {
+	case map[string]any:
+		if depth > MaxDepth {
+			return c.add(ErrLimitDepth, path, "exceeds MAX_DEPTH")
+		}
+		m := &Map{Keys: make([]string, 0, len(x)), Values: make([]Value, 0, len(x))}
+		for k, item := range x {
+			m.Keys = append(m.Keys, k)
+			m.Values = append(m.Values, c.value(item, path+"/"+escapePointerToken(k), depth+1))
+		}
+		return m
+	case []any:
+		if depth > MaxDepth {
+			return c.add(ErrLimitDepth, path, "exceeds MAX_DEPTH")
+		}
+		out := make(List, len(x))
+		for i, item := range x {
+			out[i] = c.value(item, path+"/"+strconv.Itoa(i), depth+1)
+		}
+		return out
+	case string:
+		return String(x)
+	case bool:
+		return Bool(x)
+	case json.Number:
+		val, err := convertJSONNumber(x)
+		if err != nil {
+			me := err.(*MapError)
+			return c.add(me.Code, path, me.Msg)
+		}
+		return val
+	case float64:
+		return c.add(ErrType, path, "float64 has no INTEGER token; decode with json.Decoder.UseNumber")
+	case nil:
+		return c.add(ErrType, path, "JSON null not allowed")
+	default:
+		return c.add(ErrSchema, path, fmt.Sprintf("unsupported Go type %T", v))
+	}
+}
@@ -0,0 +1,131 @@
+package map1
+
+import "bytes"
+
+// JSONParser computes JSON-STRICT FULL MIDs, like MIDFullJSON, reusing
+// its buffers from one call to the next: the input reader, the
+// per-level key/value buffers and duplicate-key sets, the arenas the
+// parsed tree is carved from, and the encode buffer.  After a few calls
+// have grown them, a parse allocates little beyond what encoding/json
+// itself needs.  Meant for servers hashing many descriptors in a loop.
+//
+// A JSONParser is NOT safe for concurrent use; give each goroutine its
+// own (or keep them in a sync.Pool).  It holds on to memory sized for
+// the largest input it has parsed.
+type JSONParser struct {
+	src     bytes.Reader
+	sr      jsonStrictReader
+	p       jsonParser
+	scratch jsonScratch
+	enc     encBuf
+}
+
+// NewJSONParser returns a JSONParser with empty buffers.
+func NewJSONParser() *JSONParser {
+	return &JSONParser{}
+}
+
+// MID returns MIDFullJSON(raw): the same MID, or the same error.
+func (jp *JSONParser) MID(raw []byte) (string, error) {
+	jp.scratch.reset()
+	jp.src.Reset(raw)
+	jp.p = jsonParser{scratch: &jp.scratch}
+	jp.sr.reset(&jp.src, &jp.p)
+
+	val, soft, err := jp.p.parse(&jp.sr)
+	if err != nil || len(soft) > 0 {
+		return "", reportedError(soft, err)
+	}
+
+	jp.enc.Reset()
+	jp.enc.Write(canonHdr)
+	if err := mcfEncodeTo(&jp.enc, val, 0); err != nil {
+		// As in mcfEncodeOpts: report the §6.2 winner.
+		w := &validator{}
+		w.walk(val, "", 0)
+		return "", reportedError(w.errs, err)
+	}
+	if jp.enc.Len() > MaxCanonBytes {
+		return "", newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	return midOfCanon(jp.enc.Bytes()), nil
+}
+
+// jsonScratch holds a JSONParser's reusable parse buffers.  Objects and
+// arrays being parsed collect their members in the buffers of their
+// nesting level; once complete, the members are copied into arenas and
+// the tree is built from those, so nothing is allocated per container
+// once the buffers have grown.  The tree is only valid until the next
+// reset.
+type jsonScratch struct {
+	level int
+	keys  [][]string
+	vals  [][]Value
+	seen  []map[string]bool
+
+	keyArena []string
+	valArena []Value
+	maps     []Map
+}
+
+func (s *jsonScratch) reset() {
+	clear(s.keyArena)
+	clear(s.valArena)
+	clear(s.maps)
+	s.keyArena, s.valArena, s.maps = s.keyArena[:0], s.valArena[:0], s.maps[:0]
+	s.level = 0
+}
+
+// enter moves one nesting level down, creating its buffers on first use.
+func (s *jsonScratch) enter() {
+	if s.level == len(s.keys) {
+		s.keys = append(s.keys, nil)
+		s.vals = append(s.vals, nil)
+		s.seen = append(s.seen, map[string]bool{})
+	}
+	s.level++
+}
+
+func (s *jsonScratch) leave() {
+	s.level--
+}
+
+// enterObject enters a level and returns its emptied buffers.
+func (s *jsonScratch) enterObject() ([]string, []Value, map[string]bool) {
+	s.enter()
+	i := s.level - 1
+	clear(s.seen[i])
+	return s.keys[i][:0], s.vals[i][:0], s.seen[i]
+}
+
+// enterArray enters a level and returns its emptied element buffer.
+func (s *jsonScratch) enterArray() List {
+	s.enter()
+	return s.vals[s.level-1][:0]
+}
+
+// newMap keeps the (possibly grown) level buffers and returns a Map of
+// their contents, carved from the arenas.
+func (s *jsonScratch) newMap(keys []string, vals []Value) *Map {
+	i := s.level - 1
+	s.keys[i], s.vals[i] = keys, vals
+	ks := len(s.keyArena)
+	s.keyArena = append(s.keyArena, keys...)
+	s.maps = append(s.maps, Map{
+		Keys:   s.keyArena[ks:len(s.keyArena):len(s.keyArena)],
+		Values: s.carve(vals),
+	})
+	return &s.maps[len(s.maps)-1]
+}
+
+// newList is newMap for an array.
+func (s *jsonScratch) newList(items List) List {
+	s.vals[s.level-1] = items
+	return s.carve(items)
+}
+
+func (s *jsonScratch) carve(vals []Value) []Value {
+	start := len(s.valArena)
+	s.valArena = append(s.valArena, vals...)
+	return s.valArena[start:len(s.valArena):len(s.valArena)]
+}
@@ -0,0 +1,231 @@
+package map1
+
+import (
+	"fmt"
+	"io"
+)
+
+// MIDFullJSONReader is MIDFullJSON over a stream: r is read once, in
+// chunks, and never buffered whole, with exactly the same JSON-STRICT
+// results.  Input past MAX_CANON_BYTES is ERR_LIMIT_SIZE without
+// reading further; if parsing fails earlier, r is still read up to the
+// limit, so an oversized input is ERR_LIMIT_SIZE here as it is there.
+// An error reading r is returned as is.
+func MIDFullJSONReader(r io.Reader) (string, error) {
+	val, soft, err := jsonParseReader(r, LenientOptions{})
+	if err != nil || len(soft) > 0 {
+		return "", reportedError(soft, err)
+	}
+	canon, err := CanonBytesFromValue(val)
+	if err != nil {
+		return "", err
+	}
+	return midOfCanon(canon), nil
+}
+
+// jsonStrictReadSize is the chunk size jsonStrictReader reads.
+const jsonStrictReadSize = 32 << 10
+
+var utf8BOM = [3]byte{0xEF, 0xBB, 0xBF}
+
+// jsonStrictReader feeds a json.Decoder and applies the JSON-STRICT
+// checks encoding/json cannot, byte by byte as they pass through:
+//
+//   - A UTF-8 BOM after leading whitespace (§8.1.1) is recorded as
+//     ERR_SCHEMA and dropped, so parsing carries on past it.
+//   - A \uD800–\uDFFF escape inside a string (§8.1) is recorded as
+//     ERR_UTF8.  encoding/json would silently turn it into U+FFFD, so
+//     it must be caught before the decoder sees it.  Surrogate pairs
+//     are rejected too: JSON text is UTF-8, and surrogates only mean
+//     something in UTF-16.
+//   - More than MAX_CANON_BYTES (after the BOM) fails the read with
+//     ERR_LIMIT_SIZE once exactly the first MAX_CANON_BYTES have been
+//     passed on, however the input is chunked.
+//
+// Violations are recorded on the parser as soft errors; a failed read
+// is kept in readErr.
+type jsonStrictReader struct {
+	r   io.Reader
+	p   *jsonParser
+	buf []byte
+
+	out    []byte // checked bytes not yet returned
+	outPos int
+	n      int // checked bytes produced
+
+	lead bool   // still at leading whitespace
+	held []byte // leading bytes that may start a BOM
+
+	inString, escaped bool
+	hexLeft           int // \u digits still to read; 0 outside an escape
+	hexVal            int
+	surrogate         bool // a surrogate escape was recorded
+
+	// surrogateErr is the recorded surrogate escape until the parser
+	// places it (see placeSurrogate); surrogateAt is its end offset in
+	// the checked stream.
+	surrogateErr *MapError
+	surrogateAt  int64
+
+	bomErr    *MapError // the BOM violation, if recorded
+	overLimit bool      // the input exceeded MAX_CANON_BYTES
+
+	err     error // sticky: the underlying error, io.EOF included
+	readErr error // err when it is not io.EOF
+}
+
+func newJSONStrictReader(r io.Reader, p *jsonParser) *jsonStrictReader {
+	return &jsonStrictReader{r: r, p: p, lead: true}
+}
+
+// reset readies s to read r for p, keeping its buffers.
+func (s *jsonStrictReader) reset(r io.Reader, p *jsonParser) {
+	*s = jsonStrictReader{r: r, p: p, lead: true, buf: s.buf, out: s.out[:0]}
+}
+
+func (s *jsonStrictReader) Read(p []byte) (int, error) {
+	for s.outPos == len(s.out) {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.buf == nil {
+			s.buf = make([]byte, jsonStrictReadSize)
+		}
+		s.out, s.outPos = s.out[:0], 0
+		// Never read more than one byte past the limit.
+		n, err := s.r.Read(s.buf[:min(len(s.buf), MaxCanonBytes+1-s.n)])
+		for _, b := range s.buf[:n] {
+			s.feed(b)
+		}
+		if err != nil {
+			s.flushHeld()
+		}
+		if over := s.n - MaxCanonBytes; over > 0 {
+			// Pass on exactly the first MAX_CANON_BYTES, then fail.
+			s.out = s.out[:len(s.out)-over]
+			s.overLimit = true
+			s.fail(newErr(ErrLimitSize, "input exceeds MAX_CANON_BYTES"))
+		} else if err != nil {
+			s.fail(err)
+		}
+	}
+	n := copy(p, s.out[s.outPos:])
+	s.outPos += n
+	return n, nil
+}
+
+func (s *jsonStrictReader) fail(err error) {
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	if err != io.EOF {
+		s.readErr = err
+	}
+}
+
+// feed runs one input byte through the BOM check.
+func (s *jsonStrictReader) feed(b byte) {
+	if s.lead {
+		switch {
+		case len(s.held) == 0 && (b == ' ' || b == '\t' || b == '\n' || b == '\r'):
+			s.scan(b)
+			return
+		case b == utf8BOM[len(s.held)]:
+			s.held = append(s.held, b)
+			if len(s.held) == len(utf8BOM) {
+				s.bomErr = newErr(ErrSchema, "UTF-8 BOM rejected")
+				s.p.record(s.bomErr)
+				s.held, s.lead = nil, false
+			}
+			return
+		}
+		s.flushHeld()
+	}
+	s.scan(b)
+}
+
+// flushHeld ends the leading-whitespace stage, passing on bytes that
+// turned out not to be a BOM.
+func (s *jsonStrictReader) flushHeld() {
+	held := s.held
+	s.held, s.lead = nil, false
+	for _, b := range held {
+		s.scan(b)
+	}
+}
+
+// scan runs one byte through the surrogate-escape check and emits it.
+func (s *jsonStrictReader) scan(b byte) {
+	s.out = append(s.out, b)
+	s.n++
+
+	switch {
+	case s.hexLeft > 0:
+		d := hexDigit(b)
+		if d < 0 {
+			// Not a valid escape; the decoder reports the syntax error.
+			s.hexLeft = 0
+			break
+		}
+		s.hexVal = s.hexVal<<4 | d
+		s.hexLeft--
+		if s.hexLeft == 0 && s.hexVal >= 0xD800 && s.hexVal <= 0xDFFF && !s.surrogate {
+			s.surrogate = true
+			s.surrogateErr = newErr(ErrUTF8, fmt.Sprintf("surrogate escape \\u%04X", s.hexVal))
+			s.surrogateAt = int64(s.n)
+			s.p.record(s.surrogateErr)
+		}
+		return
+	case s.escaped:
+		s.escaped = false
+		if b == 'u' {
+			s.hexLeft, s.hexVal = 4, 0
+		}
+		return
+	}
+
+	switch {
+	case !s.inString:
+		s.inString = b == '"'
+	case b == '\\':
+		s.escaped = true
+	case b == '"':
+		s.inString = false
+	}
+}
+
+// drain reads the rest of the input through the checks, up to the
+// limit, discarding it.
+func (s *jsonStrictReader) drain() {
+	var buf [512]byte
+	for {
+		if _, err := s.Read(buf[:]); err != nil {
+			return
+		}
+	}
+}
+
+// oversizeSoft returns the soft violations that stand alongside
+// ERR_LIMIT_SIZE for an oversized input: only the BOM, which is found
+// in the leading bytes.  Anything else was found in a prefix whose
+// extent depends on where parsing stopped, so it is dropped, as if
+// the size had been checked before parsing began.
+func (s *jsonStrictReader) oversizeSoft() []*MapError {
+	if s.bomErr == nil {
+		return nil
+	}
+	return []*MapError{s.bomErr}
+}
+
+func hexDigit(b byte) int {
+	switch {
+	case '0' <= b && b <= '9':
+		return int(b - '0')
+	case 'a' <= b && b <= 'f':
+		return int(b-'a') + 10
+	case 'A' <= b && b <= 'F':
+		return int(b-'A') + 10
+	}
+	return -1
+}
@@ -0,0 +1,63 @@
+package map1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// MIDFromListStreaming returns the MID of the LIST of the values items
+// yields, in order: the same MID, or error, as MIDFull of that List,
+// without building it.  items has the shape of a Go 1.23
+// iter.Seq[Value] and is ranged over once.
+//
+// MCF puts a LIST's count before its elements, so the stream cannot be
+// hashed blind; instead each element is encoded as it arrives and only
+// the encoded bytes are kept, which CANON_BYTES bounds at
+// MAX_CANON_BYTES.  Past MaxListEntries elements it stops drawing from
+// items and reports ERR_LIMIT_SIZE.
+func MIDFromListStreaming(items func(yield func(Value) bool)) (string, error) {
+	var body bytes.Buffer
+	var errs []*MapError
+	n, over := 0, false
+	items(func(v Value) bool {
+		n++
+		if n > MaxListEntries {
+			return false
+		}
+		// Elements sit at depth 1, inside the root LIST.
+		b, err := mcfEncode(v, 1)
+		if err != nil {
+			// Keep going: a later element may hold a violation that
+			// outranks this one, or the count may breach the limit.
+			errs = append(errs, err.(*MapError))
+			return true
+		}
+		if !over && len(errs) == 0 {
+			body.Write(b)
+			if len(canonHdr)+5+body.Len() > MaxCanonBytes {
+				over = true
+				body = bytes.Buffer{}
+			}
+		}
+		return true
+	})
+
+	switch {
+	case n > MaxListEntries:
+		return "", newErr(ErrLimitSize, "list entry count exceeds limit")
+	case len(errs) > 0:
+		return "", reportedError(errs, nil)
+	case over:
+		return "", newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+
+	h := sha256.New()
+	h.Write(canonHdr)
+	var head bytes.Buffer
+	head.WriteByte(tagList)
+	writeU32BE(&head, uint32(n))
+	h.Write(head.Bytes())
+	h.Write(body.Bytes())
+	return DefaultMIDPrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
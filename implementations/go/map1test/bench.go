@@ -0,0 +1,167 @@
+package map1test
+
+import (
+	"fmt"
+	"testing"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+)
+
+// Fixture is a named benchmark input.
+type Fixture struct {
+	Name  string
+	Value map1.Value
+}
+
+// Fixtures returns the benchmark corpus: SmallValue, MediumValue and
+// DeepValue, in that order.  The values are rebuilt on every call and
+// are the same on every call, so results from different builds and
+// machines are comparable.
+func Fixtures() []Fixture {
+	return []Fixture{
+		{"small", SmallValue()},
+		{"medium", MediumValue()},
+		{"deep", DeepValue()},
+	}
+}
+
+// SmallValue is a typical request descriptor: a dozen fields of every
+// type, one level of nesting.
+func SmallValue() map1.Value {
+	return map1.NewMap(
+		map1.MapEntry{Key: "action", Value: map1.String("deploy")},
+		map1.MapEntry{Key: "target", Value: map1.String("prod-us-east-1")},
+		map1.MapEntry{Key: "version", Value: map1.String("2.14.0")},
+		map1.MapEntry{Key: "replicas", Value: map1.Integer(6)},
+		map1.MapEntry{Key: "timeout_ms", Value: map1.Integer(30000)},
+		map1.MapEntry{Key: "dry_run", Value: map1.Bool(false)},
+		map1.MapEntry{Key: "checksum", Value: map1.Bytes(fixtureBytes(32, 1))},
+		map1.MapEntry{Key: "tags", Value: map1.List{map1.String("canary"), map1.String("blue"), map1.String("v2")}},
+		map1.MapEntry{Key: "owner", Value: map1.NewMap(
+			map1.MapEntry{Key: "team", Value: map1.String("platform")},
+			map1.MapEntry{Key: "oncall", Value: map1.String("sre-primary")},
+		)},
+		map1.MapEntry{Key: "limits", Value: map1.NewMap(
+			map1.MapEntry{Key: "cpu_milli", Value: map1.Integer(2000)},
+			map1.MapEntry{Key: "mem_mib", Value: map1.Integer(4096)},
+		)},
+	)
+}
+
+// MediumValue is a flat MAP of 4096 entries cycling through STRING,
+// INTEGER, BOOLEAN, BYTES and a small nested MAP, about 134 KiB of
+// CANON_BYTES.
+func MediumValue() map1.Value {
+	const n = 4096
+	entries := make([]map1.MapEntry, n)
+	for i := range entries {
+		var v map1.Value
+		switch i % 5 {
+		case 0:
+			v = map1.String(fmt.Sprintf("value-%06d", i))
+		case 1:
+			v = map1.Integer(int64(i) * 7919)
+		case 2:
+			v = map1.Bool(i%2 == 0)
+		case 3:
+			v = map1.Bytes(fixtureBytes(16, i))
+		default:
+			v = map1.NewMap(
+				map1.MapEntry{Key: "id", Value: map1.Integer(int64(i))},
+				map1.MapEntry{Key: "name", Value: map1.String(fmt.Sprintf("item-%d", i))},
+			)
+		}
+		entries[i] = map1.MapEntry{Key: fmt.Sprintf("key-%06d", i), Value: v}
+	}
+	return map1.NewMap(entries...)
+}
+
+// DeepValue nests MAPs exactly MAX_DEPTH deep, each level carrying a
+// few scalar siblings beside the next level.
+func DeepValue() map1.Value {
+	v := map1.Value(map1.NewMap(map1.MapEntry{Key: "leaf", Value: map1.String("bottom")}))
+	for depth := map1.MaxDepth - 1; depth > 0; depth-- {
+		v = map1.NewMap(
+			map1.MapEntry{Key: "depth", Value: map1.Integer(int64(depth))},
+			map1.MapEntry{Key: "label", Value: map1.String(fmt.Sprintf("level-%d", depth))},
+			map1.MapEntry{Key: "next", Value: v},
+		)
+	}
+	return v
+}
+
+// fixtureBytes returns n deterministic bytes seeded by seed.
+func fixtureBytes(n, seed int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(seed*31 + i*17)
+	}
+	return b
+}
+
+// RunBenchmarks runs BenchmarkEncode, BenchmarkDecode and BenchmarkMID
+// over every fixture as sub-benchmarks named "<op>/<fixture>".  Call it
+// from a Benchmark function in your own module to measure your build
+// against the same shapes.
+func RunBenchmarks(b *testing.B) {
+	for _, op := range []struct {
+		name string
+		fn   func(*testing.B, map1.Value)
+	}{
+		{"encode", BenchmarkEncode},
+		{"decode", BenchmarkDecode},
+		{"mid", BenchmarkMID},
+	} {
+		for _, f := range Fixtures() {
+			b.Run(op.name+"/"+f.Name, func(b *testing.B) { op.fn(b, f.Value) })
+		}
+	}
+}
+
+// BenchmarkEncode measures map1.CanonBytesFromValue on v.
+func BenchmarkEncode(b *testing.B, v map1.Value) {
+	canon := mustCanon(b, v)
+	b.SetBytes(int64(len(canon)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := map1.CanonBytesFromValue(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecode measures map1.DecodeCanonBytes on the CANON_BYTES of v.
+func BenchmarkDecode(b *testing.B, v map1.Value) {
+	canon := mustCanon(b, v)
+	b.SetBytes(int64(len(canon)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := map1.DecodeCanonBytes(canon); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMID measures map1.MIDFromValue on v.
+func BenchmarkMID(b *testing.B, v map1.Value) {
+	canon := mustCanon(b, v)
+	b.SetBytes(int64(len(canon)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := map1.MIDFromValue(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func mustCanon(b *testing.B, v map1.Value) []byte {
+	b.Helper()
+	canon, err := map1.CanonBytesFromValue(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return canon
+}
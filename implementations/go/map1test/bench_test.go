@@ -0,0 +1,36 @@
+package map1test_test
+
+import (
+	"bytes"
+	"testing"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+	"github.com/map-protocol/map1/implementations/go/map1test"
+)
+
+func TestFixtures(t *testing.T) {
+	again := map1test.Fixtures()
+	for i, f := range map1test.Fixtures() {
+		canon, err := map1.CanonBytesFromValue(f.Value)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if b, _ := map1.CanonBytesFromValue(again[i].Value); !bytes.Equal(canon, b) {
+			t.Errorf("%s: fixture is not deterministic", f.Name)
+		}
+	}
+
+	if n := len(map1test.MediumValue().(*map1.Map).Keys); n != 4096 {
+		t.Errorf("medium has %d keys, want 4096", n)
+	}
+	// DeepValue sits exactly at MAX_DEPTH: one more level is too deep.
+	deeper := map1.NewMap(map1.MapEntry{Key: "x", Value: map1test.DeepValue()})
+	_, err := map1.CanonBytesFromValue(deeper)
+	if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrLimitDepth {
+		t.Errorf("deep + 1: err = %v, want %s", err, map1.ErrLimitDepth)
+	}
+}
+
+func BenchmarkFixtures(b *testing.B) {
+	map1test.RunBenchmarks(b)
+}
@@ -0,0 +1,170 @@
+// Package map1test provides test infrastructure for MAP v1
+// implementations: the conformance vector file schema and helpers to
+// generate new vector corpora from Go values, and a fixed benchmark
+// corpus with helpers to run it.
+package map1test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+)
+
+// File names used by the shared conformance suite.
+const (
+	VectorsFileName  = "conformance_vectors_v11.json"
+	ExpectedFileName = "conformance_expected_v11.json"
+)
+
+// VectorsGlob matches every vectors file in a directory.  Each
+// conformance_vectors_<name>.json pairs with conformance_expected_<name>.json.
+const VectorsGlob = "conformance_vectors_*.json"
+
+// VectorEntry is one input in a vectors file.
+type VectorEntry struct {
+	TestID   string   `json:"test_id"`
+	Mode     string   `json:"mode"`
+	InputB64 string   `json:"input_b64"`
+	Pointers []string `json:"pointers,omitempty"`
+
+	// Source is the vectors file the entry was loaded from, set by
+	// LoadDir for error messages.
+	Source string `json:"-"`
+}
+
+// VectorsFile is the top-level schema of a vectors file.
+type VectorsFile struct {
+	Meta    json.RawMessage `json:"meta"`
+	Vectors []VectorEntry   `json:"vectors"`
+}
+
+// ExpectedFile is the top-level schema of an expected-results file.
+type ExpectedFile struct {
+	Meta     json.RawMessage        `json:"meta"`
+	Expected map[string]ExpectedVal `json:"expected"`
+}
+
+// ExpectedVal is the expected outcome of one vector: a MID or an error code.
+type ExpectedVal struct {
+	MID string `json:"mid,omitempty"`
+	Err string `json:"err,omitempty"`
+}
+
+var defaultMeta = json.RawMessage(`{"spec_version": "` + map1.SpecVersion + `"}`)
+
+// NewVectorInput encodes v to CANON_BYTES and returns them base64-encoded,
+// as the input_b64 of a "canon_bytes" vector, together with the MID to
+// record as its expected outcome.  Errors are those of MIDFull.
+func NewVectorInput(v map1.Value) (inputB64 string, expectedMID string, err error) {
+	canon, mid, err := map1.CanonBytesAndMIDFull(v)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(canon), mid, nil
+}
+
+// WriteVectors encodes each value to CANON_BYTES and writes a vectors /
+// expected pair into dir, in the schema the conformance runners read.
+// Every vector uses mode "canon_bytes"; the map key is its test_id.
+// Vectors are written in test_id order so output is reproducible.
+func WriteVectors(dir string, values map[string]map1.Value) error {
+	ids := make([]string, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	vf := VectorsFile{Meta: defaultMeta, Vectors: make([]VectorEntry, 0, len(ids))}
+	ef := ExpectedFile{Meta: defaultMeta, Expected: make(map[string]ExpectedVal, len(ids))}
+	for _, id := range ids {
+		input, mid, err := NewVectorInput(values[id])
+		if err != nil {
+			return err
+		}
+		vf.Vectors = append(vf.Vectors, VectorEntry{
+			TestID:   id,
+			Mode:     "canon_bytes",
+			InputB64: input,
+		})
+		ef.Expected[id] = ExpectedVal{MID: mid}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(dir, VectorsFileName), vf); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(dir, ExpectedFileName), ef)
+}
+
+// LoadDir reads every vectors file matching VectorsGlob in dir together
+// with its expected-results file and merges them, in file-name order.
+// A vectors file without its expected file, a vector without an
+// expected outcome and a test_id defined twice are errors.
+func LoadDir(dir string) ([]VectorEntry, map[string]ExpectedVal, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, VectorsGlob))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("map1test: no %s in %s", VectorsGlob, dir)
+	}
+	sort.Strings(paths)
+
+	var vecs []VectorEntry
+	expected := make(map[string]ExpectedVal)
+	seen := make(map[string]string)
+	for _, path := range paths {
+		name := filepath.Base(path)
+		expName := "conformance_expected_" + strings.TrimPrefix(name, "conformance_vectors_")
+
+		var vf VectorsFile
+		if err := readJSON(path, &vf); err != nil {
+			return nil, nil, err
+		}
+		var ef ExpectedFile
+		if err := readJSON(filepath.Join(dir, expName), &ef); err != nil {
+			return nil, nil, err
+		}
+		for _, v := range vf.Vectors {
+			if prev, dup := seen[v.TestID]; dup {
+				return nil, nil, fmt.Errorf("map1test: test_id %s in both %s and %s", v.TestID, prev, name)
+			}
+			exp, ok := ef.Expected[v.TestID]
+			if !ok {
+				return nil, nil, fmt.Errorf("map1test: %s: no expected outcome for %s in %s", name, v.TestID, expName)
+			}
+			seen[v.TestID] = name
+			v.Source = name
+			vecs = append(vecs, v)
+			expected[v.TestID] = exp
+		}
+	}
+	return vecs, expected, nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("map1test: %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,103 @@
+package map1test_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+	"github.com/map-protocol/map1/implementations/go/map1test"
+)
+
+func TestWriteVectors(t *testing.T) {
+	dir := t.TempDir()
+	values := map[string]map1.Value{
+		"GEN_B": map1.List{map1.Integer(1)},
+		"GEN_A": map1.NewMap(map1.MapEntry{Key: "k", Value: map1.String("v")}),
+	}
+	if err := map1test.WriteVectors(dir, values); err != nil {
+		t.Fatal(err)
+	}
+
+	var vf map1test.VectorsFile
+	var ef map1test.ExpectedFile
+	for name, dst := range map[string]any{map1test.VectorsFileName: &vf, map1test.ExpectedFileName: &ef} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(vf.Vectors) != 2 || vf.Vectors[0].TestID != "GEN_A" {
+		t.Fatalf("unexpected vectors: %+v", vf.Vectors)
+	}
+	for _, v := range vf.Vectors {
+		raw, _ := base64.StdEncoding.DecodeString(v.InputB64)
+		mid, err := map1.MIDFromCanonBytes(raw)
+		if err != nil || v.Mode != "canon_bytes" || mid != ef.Expected[v.TestID].MID {
+			t.Errorf("%s: mode=%s mid=%s err=%v, expected %s", v.TestID, v.Mode, mid, err, ef.Expected[v.TestID].MID)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := map1test.WriteVectors(dir, map[string]map1.Value{"GEN_A": map1.Integer(1)}); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("conformance_vectors_xml.json", `{"vectors":[{"test_id":"XML_A","mode":"xml_full","input_b64":""}]}`)
+	write("conformance_expected_xml.json", `{"expected":{"XML_A":{"err":"ERR_CANON_MCF"}}}`)
+
+	vecs, expected, err := map1test.LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != 2 || len(expected) != 2 {
+		t.Fatalf("got %d vectors, %d expected; want 2, 2", len(vecs), len(expected))
+	}
+	if vecs[0].TestID != "GEN_A" || vecs[0].Source != map1test.VectorsFileName || vecs[1].Source != "conformance_vectors_xml.json" {
+		t.Errorf("unexpected order or sources: %+v", vecs)
+	}
+
+	// A test_id defined in two files is rejected.
+	write("conformance_vectors_xml.json", `{"vectors":[{"test_id":"GEN_A","mode":"xml_full","input_b64":""}]}`)
+	write("conformance_expected_xml.json", `{"expected":{"GEN_A":{"err":"ERR_CANON_MCF"}}}`)
+	if _, _, err := map1test.LoadDir(dir); err == nil {
+		t.Error("duplicate test_id: expected error")
+	}
+
+	// So is a vectors file without its expected file.
+	if err := os.Remove(filepath.Join(dir, "conformance_expected_xml.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := map1test.LoadDir(dir); err == nil {
+		t.Error("missing expected file: expected error")
+	}
+}
+
+func TestNewVectorInput(t *testing.T) {
+	v := map1.NewMap(map1.MapEntry{Key: "k", Value: map1.Bool(true)})
+	input, mid, err := map1test.NewVectorInput(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := map1.MIDFromCanonBytes(raw); err != nil || got != mid || mid != map1.MustMIDFull(v) {
+		t.Errorf("got %s %v, want %s", got, err, mid)
+	}
+	if _, _, err := map1test.NewVectorInput(map1.String("\xff")); err == nil || err.(*map1.MapError).Code != map1.ErrUTF8 {
+		t.Errorf("invalid value: got %v, want ERR_UTF8", err)
+	}
+}
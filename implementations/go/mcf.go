@@ -0,0 +1,61 @@
+package map1
+
+import "fmt"
+
+// Headerless MCF API.
+//
+// These functions expose the framing layer (§3.2) without CANON_HDR,
+// for callers embedding canonical values inside their own containers.
+// MCF bytes alone are NOT a MID input: a MID is always computed over
+// CANON_HDR || MCF (§5.3).  Prepend the header (or use the CanonBytes*
+// functions) before hashing.
+
+// MCFBytes encodes v to MCF without the CANON_HDR prefix.  Validation
+// is identical to CanonBytesFromValue, including MAX_CANON_BYTES
+// (measured as if the header were present), so the result can always
+// be promoted to valid CANON_BYTES.
+func MCFBytes(v Value) ([]byte, error) {
+	body, err := mcfEncode(v, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(canonHdr)+len(body) > MaxCanonBytes {
+		return nil, newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	return body, nil
+}
+
+// DecodeMCF decodes one headerless MCF value from the start of buf and
+// returns it with the number of bytes consumed.  Bytes after the value
+// are left for the caller; use the consumed count to continue.
+//
+// On error the int is the offset of the tag byte of the innermost value
+// the reported violation concerns (for a missing tag, where the tag
+// should have been), whatever the tag or failure.
+func DecodeMCF(buf []byte) (Value, int, error) {
+	return mcfDecodeOne(buf, 0, 0)
+}
+
+// DecodeMCFStream decodes back-to-back headerless MCF values until buf
+// is exhausted.  Each value is validated exactly as DecodeMCF would.
+// A value that fails to decode — including a truncated final value or
+// trailing garbage — is an error carrying its index, its start offset
+// and the offset of the violation (as DecodeMCF reports it).
+func DecodeMCFStream(buf []byte) ([]Value, error) {
+	var vals []Value
+	off := 0
+	for off < len(buf) {
+		v, end, err := mcfDecodeOne(buf, off, 0)
+		if err != nil {
+			me := err.(*MapError)
+			return nil, &MapError{
+				Code: me.Code,
+				Msg:  fmt.Sprintf("%s at offset %d (value %d at offset %d)", me.Msg, end, len(vals), off),
+				Path: me.Path,
+			}
+		}
+		vals = append(vals, v)
+		off = end
+	}
+	return vals, nil
+}
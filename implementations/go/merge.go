@@ -0,0 +1,213 @@
+package map1
+
+import "sort"
+
+// Merge overlays overlay onto base.  Where both hold a MAP under the
+// same key the two are merged recursively; anywhere else the overlay
+// value replaces the base value, so LISTs are replaced, not
+// concatenated.  If either root is not a MAP the result is overlay.
+//
+// Unmerged subtrees are shared with the inputs, not copied.  Duplicate
+// keys within either input are kept, so the result fails to encode
+// exactly as the input would.
+//
+// Where both MAPs are marked Sorted (as Canonicalize and the decoder
+// leave them) and really are in order, they are merged in one linear
+// pass and the result is marked Sorted too, so repeated layering never
+// re-sorts.
+func Merge(base, overlay Value) Value {
+	bm, ok1 := base.(*Map)
+	om, ok2 := overlay.(*Map)
+	if !ok1 || !ok2 {
+		return overlay
+	}
+	if bm.Sorted && om.Sorted {
+		if out, ok := mergeSorted(bm, om); ok {
+			return out
+		}
+	}
+	out := &Map{
+		Keys:   append([]string(nil), bm.Keys...),
+		Values: append([]Value(nil), bm.Values...),
+	}
+	inBase := make(map[string]int, len(bm.Keys))
+	for i := len(bm.Keys) - 1; i >= 0; i-- {
+		inBase[bm.Keys[i]] = i
+	}
+	seen := make(map[string]bool, len(om.Keys))
+	for i, k := range om.Keys {
+		j, ok := inBase[k]
+		if ok && !seen[k] {
+			out.Values[j] = Merge(out.Values[j], om.Values[i])
+		} else {
+			out.Keys = append(out.Keys, k)
+			out.Values = append(out.Values, om.Values[i])
+		}
+		seen[k] = true
+	}
+	return out
+}
+
+// mergeSorted merges two maps marked Sorted in O(n+m), like a merge
+// sort's merge step.  ok is false if the output is not strictly
+// ascending, which happens exactly when an input is not — a wrong mark
+// or a duplicate key — and Merge then takes the general path so the
+// result fails to encode as the input would.
+func mergeSorted(bm, om *Map) (*Map, bool) {
+	n := len(bm.Keys) + len(om.Keys)
+	out := &Map{Keys: make([]string, 0, n), Values: make([]Value, 0, n), Sorted: true}
+	for i, j := 0, 0; i < len(bm.Keys) || j < len(om.Keys); {
+		var k string
+		var v Value
+		switch c := mergeSortedCmp(bm, i, om, j); {
+		case c < 0:
+			k, v = bm.Keys[i], bm.Values[i]
+			i++
+		case c > 0:
+			k, v = om.Keys[j], om.Values[j]
+			j++
+		default:
+			k, v = om.Keys[j], Merge(bm.Values[i], om.Values[j])
+			i++
+			j++
+		}
+		if last := len(out.Keys) - 1; last >= 0 && CompareKeys(out.Keys[last], k) >= 0 {
+			return nil, false
+		}
+		out.Keys = append(out.Keys, k)
+		out.Values = append(out.Values, v)
+	}
+	return out, true
+}
+
+// mergeSortedCmp is mergeCmp over maps already in key order.
+func mergeSortedCmp(bm *Map, i int, om *Map, j int) int {
+	switch {
+	case i >= len(bm.Keys):
+		return 1
+	case j >= len(om.Keys):
+		return -1
+	}
+	return CompareKeys(bm.Keys[i], om.Keys[j])
+}
+
+// MIDFromMerged returns MIDFull(Merge(base, overlay)) without building
+// the merged tree: at each level where both sides are MAPs it walks the
+// union of their keys in canonical order, preferring overlay, and
+// encodes straight into the output.  Errors are identical to the
+// materialized form; on any violation the merge is materialized to
+// find the §6.2 winner.
+func MIDFromMerged(base, overlay Value) (string, error) {
+	var buf encBuf
+	buf.Write(canonHdr)
+	if err := encodeMerged(&buf, base, overlay, 0); err != nil || buf.Len() > MaxCanonBytes {
+		return MIDFull(Merge(base, overlay))
+	}
+	return midOfCanon(buf.Bytes()), nil
+}
+
+// encodeMerged writes MCF(Merge(base, overlay)).  Any error just means
+// "take the slow path"; its code is not reported.
+func encodeMerged(buf *encBuf, base, overlay Value, depth int) error {
+	bm, ok1 := base.(*Map)
+	om, ok2 := overlay.(*Map)
+	if !ok1 || !ok2 {
+		return mcfEncodeTo(buf, overlay, depth)
+	}
+	if depth+1 > MaxDepth {
+		return newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
+	}
+	bi, err := sortedKeyIndex(bm)
+	if err != nil {
+		return err
+	}
+	oi, err := sortedKeyIndex(om)
+	if err != nil {
+		return err
+	}
+
+	// First pass counts the union for the entry-count prefix.
+	n := 0
+	for i, j := 0, 0; i < len(bi) || j < len(oi); n++ {
+		switch c := mergeCmp(bm, bi, i, om, oi, j); {
+		case c < 0:
+			i++
+		case c > 0:
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	if n > MaxMapEntries {
+		return newErr(ErrLimitSize, "map entry count exceeds limit")
+	}
+
+	buf.WriteByte(tagMap)
+	writeU32BE(&buf.Buffer, uint32(n))
+	for i, j := 0, 0; i < len(bi) || j < len(oi); {
+		var err error
+		switch c := mergeCmp(bm, bi, i, om, oi, j); {
+		case c < 0:
+			writeMergedKey(buf, bm.Keys[bi[i]])
+			err = mcfEncodeTo(buf, bm.Values[bi[i]], depth+1)
+			i++
+		case c > 0:
+			writeMergedKey(buf, om.Keys[oi[j]])
+			err = mcfEncodeTo(buf, om.Values[oi[j]], depth+1)
+			j++
+		default:
+			writeMergedKey(buf, om.Keys[oi[j]])
+			err = encodeMerged(buf, bm.Values[bi[i]], om.Values[oi[j]], depth+1)
+			i++
+			j++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeCmp compares the next base key against the next overlay key; an
+// exhausted side compares greater than anything.
+func mergeCmp(bm *Map, bi []int, i int, om *Map, oi []int, j int) int {
+	switch {
+	case i >= len(bi):
+		return 1
+	case j >= len(oi):
+		return -1
+	}
+	return CompareKeys(bm.Keys[bi[i]], om.Keys[oi[j]])
+}
+
+func writeMergedKey(buf *encBuf, key string) {
+	buf.WriteByte(tagString)
+	writeU32BE(&buf.Buffer, uint32(len(key)))
+	buf.WriteString(key)
+}
+
+// sortedKeyIndex returns m's entry indices in canonical key order,
+// validating keys as the encoder would.
+func sortedKeyIndex(m *Map) ([]int, error) {
+	idx := make([]int, len(m.Keys))
+	for i, k := range m.Keys {
+		if err := validateUTF8Scalar([]byte(k)); err != nil {
+			return nil, err
+		}
+		if err := checkPayloadLen(len(k)); err != nil {
+			return nil, err
+		}
+		idx[i] = i
+	}
+	less := func(a, b int) bool { return m.Keys[idx[a]] < m.Keys[idx[b]] }
+	if !sort.SliceIsSorted(idx, less) {
+		sort.Slice(idx, less)
+	}
+	for i := 1; i < len(idx); i++ {
+		if m.Keys[idx[i-1]] == m.Keys[idx[i]] {
+			return nil, newErr(ErrDupKey, "duplicate key")
+		}
+	}
+	return idx, nil
+}
@@ -0,0 +1,216 @@
+package map1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// Merkle identity
+//
+// A MerkleMID is a DIFFERENT identity from the MID.  It is NOT equal to
+// MIDFull for the same value, is not defined by the MAP v1.1 spec, and
+// must never be compared against or substituted for a "map1:" MID.  It
+// exists so that a large descriptor can be re-identified after a single
+// field changes by rehashing only the path from that field to the root.
+//
+// Layout.  Every node hashes to H(node) = sha256("MAP1M\0" || body):
+//
+//   - scalar:  body = MCF(scalar)  (same bytes the flat encoder emits)
+//   - LIST:    body = 0x03 || u32be(n) || for each item: BYTES(H(item))
+//   - MAP:     body = 0x04 || u32be(n) || for each entry in canonical
+//     key order: STRING(key) || BYTES(H(value))
+//
+// where BYTES(h) is the MCF BYTES framing of the 32-byte digest.  The
+// "MAP1M\0" prefix keeps node hashes disjoint from flat MIDs.  The
+// MerkleMID is "map1merkle:" + hex_lower(H(root)).
+//
+// Validation matches the flat encoder (UTF-8, duplicate keys, depth and
+// entry-count limits) except MAX_CANON_BYTES, which bounds the flat
+// encoding and has no meaning here.
+
+// MerkleMID is a Merkle-layout identifier.  See the layout notes above.
+type MerkleMID string
+
+var merkleHdr = []byte{0x4D, 0x41, 0x50, 0x31, 0x4D, 0x00} // "MAP1M\0"
+
+const merklePrefix = "map1merkle:"
+
+// MerkleTree caches per-node hashes of a value so that UpdateMID can
+// recompute only the path to the root.  A MerkleTree is not safe for
+// concurrent use.
+type MerkleTree struct {
+	root *merkleNode
+}
+
+type merkleNode struct {
+	kind     byte     // tagList, tagMap, or 0 for a scalar
+	keys     []string // MAP only, canonical order
+	children []*merkleNode
+	hash     [32]byte
+}
+
+// NewMerkleTree builds the Merkle tree for v.
+func NewMerkleTree(v Value) (*MerkleTree, error) {
+	root, err := buildMerkle(v, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &MerkleTree{root: root}, nil
+}
+
+// MID returns the tree's current MerkleMID.
+func (t *MerkleTree) MID() MerkleMID {
+	return MerkleMID(merklePrefix + hex.EncodeToString(t.root.hash[:]))
+}
+
+// UpdateMID replaces the node at pointer with newLeaf (any value,
+// scalar or container) and returns the new root MerkleMID.  Only the
+// nodes on the path are rehashed.
+//
+// pointer is RFC 6901; unlike BIND, LIST elements are addressed by
+// decimal index.  The pointer must name an existing node — entries
+// cannot be added or removed this way (ERR_SCHEMA).  On error the tree
+// is unchanged.
+func UpdateMID(tree *MerkleTree, pointer string, newLeaf Value) (MerkleMID, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+	path := make([]*merkleNode, 0, len(tokens)+1)
+	slots := make([]int, 0, len(tokens))
+	cur := tree.root
+	for _, tok := range tokens {
+		idx, err := cur.childIndex(tok)
+		if err != nil {
+			return "", err
+		}
+		path = append(path, cur)
+		slots = append(slots, idx)
+		cur = cur.children[idx]
+	}
+
+	leaf, err := buildMerkle(newLeaf, len(tokens))
+	if err != nil {
+		return "", err
+	}
+	if len(path) == 0 {
+		tree.root = leaf
+		return tree.MID(), nil
+	}
+	path[len(path)-1].children[slots[len(slots)-1]] = leaf
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].rehash()
+	}
+	return tree.MID(), nil
+}
+
+func (n *merkleNode) childIndex(tok string) (int, error) {
+	switch n.kind {
+	case tagMap:
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= tok })
+		if i < len(n.keys) && n.keys[i] == tok {
+			return i, nil
+		}
+		return 0, newErr(ErrSchema, "pointer does not match an existing key")
+	case tagList:
+		return parseListIndex(tok, len(n.children))
+	default:
+		return 0, newErr(ErrSchema, "pointer traverses a scalar")
+	}
+}
+
+func buildMerkle(v Value, depth int) (*merkleNode, error) {
+	switch val := v.(type) {
+
+	case List:
+		if depth+1 > MaxDepth {
+			return nil, newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
+		}
+		if len(val) > MaxListEntries {
+			return nil, newErr(ErrLimitSize, "list entry count exceeds limit")
+		}
+		n := &merkleNode{kind: tagList, children: make([]*merkleNode, len(val))}
+		for i, item := range val {
+			child, err := buildMerkle(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			n.children[i] = child
+		}
+		n.rehash()
+		return n, nil
+
+	case *Map:
+		if depth+1 > MaxDepth {
+			return nil, newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
+		}
+		if len(val.Keys) > MaxMapEntries {
+			return nil, newErr(ErrLimitSize, "map entry count exceeds limit")
+		}
+		order := make([]int, len(val.Keys))
+		for i, k := range val.Keys {
+			if err := validateUTF8Scalar([]byte(k)); err != nil {
+				return nil, err
+			}
+			if err := checkPayloadLen(len(k)); err != nil {
+				return nil, err
+			}
+			order[i] = i
+		}
+		// Go string comparison is bytewise, i.e. canonical order (§3.5).
+		sort.Slice(order, func(i, j int) bool { return val.Keys[order[i]] < val.Keys[order[j]] })
+		n := &merkleNode{
+			kind:     tagMap,
+			keys:     make([]string, len(order)),
+			children: make([]*merkleNode, len(order)),
+		}
+		for i, j := range order {
+			if i > 0 && val.Keys[j] == n.keys[i-1] {
+				return nil, newErr(ErrDupKey, "duplicate key")
+			}
+			n.keys[i] = val.Keys[j]
+		}
+		for i, j := range order {
+			child, err := buildMerkle(val.Values[j], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			n.children[i] = child
+		}
+		n.rehash()
+		return n, nil
+
+	default:
+		body, err := mcfEncode(v, depth)
+		if err != nil {
+			return nil, err
+		}
+		n := &merkleNode{}
+		h := sha256.New()
+		h.Write(merkleHdr)
+		h.Write(body)
+		h.Sum(n.hash[:0])
+		return n, nil
+	}
+}
+
+// rehash recomputes a container's hash from its children's hashes.
+func (n *merkleNode) rehash() {
+	var buf bytes.Buffer
+	buf.Write(merkleHdr)
+	buf.WriteByte(n.kind)
+	writeU32BE(&buf, uint32(len(n.children)))
+	for i, c := range n.children {
+		if n.kind == tagMap {
+			buf.WriteByte(tagString)
+			writeU32BE(&buf, uint32(len(n.keys[i])))
+			buf.WriteString(n.keys[i])
+		}
+		buf.WriteByte(tagBytes)
+		writeU32BE(&buf, uint32(len(c.hash)))
+		buf.Write(c.hash[:])
+	}
+	n.hash = sha256.Sum256(buf.Bytes())
+}
@@ -4,12 +4,19 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 )
 
 // CanonBytesFromValue encodes a canonical-model value to CANON_BYTES.
 // CANON_BYTES = CANON_HDR || MCF(root_value)  (§5.2)
 func CanonBytesFromValue(v Value) ([]byte, error) {
-	body, err := mcfEncode(v, 0)
+	return CanonBytesFromValueWithOptions(v, EncodeOptions{})
+}
+
+// CanonBytesFromValueWithOptions is CanonBytesFromValue with encoder
+// options.
+func CanonBytesFromValueWithOptions(v Value, opts EncodeOptions) ([]byte, error) {
+	body, err := mcfEncodeOpts(v, 0, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -25,33 +32,99 @@ func CanonBytesFromValue(v Value) ([]byte, error) {
 // MIDFromValue computes a MID from a canonical-model value.
 // MID = "map1:" + hex_lower(sha256(CANON_BYTES))  (§5.3)
 func MIDFromValue(v Value) (string, error) {
-	canon, err := CanonBytesFromValue(v)
+	return MIDFromValuePrefix(v, DefaultMIDPrefix)
+}
+
+// MIDFromValueWithOptions is MIDFromValue with encoder options.  With
+// any extension enabled the result is a MID over extended bytes, which
+// other MAP v1.1 implementations cannot reproduce.
+func MIDFromValueWithOptions(v Value, opts EncodeOptions) (string, error) {
+	canon, err := CanonBytesFromValueWithOptions(v, opts)
 	if err != nil {
 		return "", err
 	}
-	return "map1:" + sha256hex(canon), nil
+	return midOfCanon(canon), nil
 }
 
 // MIDFromCanonBytes validates pre-built CANON_BYTES and returns MID.
 // This is the "fast-path" entry point (§3.7): fully validates the binary
 // structure but hashes the input bytes directly rather than re-encoding.
 func MIDFromCanonBytes(canon []byte) (string, error) {
+	// The header outranks everything and costs nothing to check, so it
+	// goes before the size limit short-circuit (§6.2).
+	if !bytes.HasPrefix(canon, canonHdr) {
+		return "", newErr(ErrCanonHdr, "bad CANON_HDR")
+	}
 	if len(canon) > MaxCanonBytes {
 		return "", newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
 	}
+	// Exactly one root MCF value, no trailing bytes (§3.7.f).
+	if _, err := decodeRoot(canon, len(canonHdr)); err != nil {
+		return "", err
+	}
+	return midOfCanon(canon), nil
+}
+
+// DecodeCanonBytes validates CANON_BYTES exactly as MIDFromCanonBytes
+// does and returns the decoded root value.
+func DecodeCanonBytes(canon []byte) (Value, error) {
+	if !bytes.HasPrefix(canon, canonHdr) {
+		return nil, newErr(ErrCanonHdr, "bad CANON_HDR")
+	}
+	if len(canon) > MaxCanonBytes {
+		return nil, newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	return decodeRoot(canon, len(canonHdr))
+}
+
+// DecodeCanonBytesWithOptions is DecodeCanonBytes with decoder options.
+func DecodeCanonBytesWithOptions(canon []byte, opts DecodeOptions) (Value, error) {
 	if !bytes.HasPrefix(canon, canonHdr) {
-		return "", newErr(ErrCanonHdr, "bad CANON_HDR")
+		return nil, newErr(ErrCanonHdr, "bad CANON_HDR")
+	}
+	if len(canon) > MaxCanonBytes {
+		return nil, newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	d := mcfDecoder{opts: opts}
+	v, err := d.root(canon, len(canonHdr))
+	if err == nil && v == nil {
+		return nil, newErr(ErrCanonMCF, "extension tag at root")
 	}
-	off := len(canonHdr)
-	_, end, err := mcfDecodeOne(canon, off, 0)
+	return v, err
+}
+
+// RecanonicalizeCanonBytes repairs CANON_BYTES whose only defect is
+// MAP keys out of canonical order, as written by a producer that does
+// not sort: it decodes in with DecodeOptions.TolerateUnsortedKeys and
+// re-encodes the result canonically.  The output differs from in
+// wherever a map was unsorted, and its MID is the spec-correct MID of
+// the value in describes — not the hash of in, which no conformant
+// implementation would accept.  Canonical input comes back unchanged.
+// Every other violation, duplicate keys included, is rejected as
+// DecodeCanonBytes would.
+func RecanonicalizeCanonBytes(in []byte) ([]byte, error) {
+	v, err := DecodeCanonBytesWithOptions(in, DecodeOptions{TolerateUnsortedKeys: true})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	// Exactly one root MCF value, no trailing bytes (§3.7.f).
-	if end != len(canon) {
-		return "", newErr(ErrCanonMCF, "trailing bytes after MCF root")
+	return CanonBytesFromValue(v)
+}
+
+// CanonVersion reports the framing major version a CANON_BYTES blob
+// claims in its header: "MAP" + ASCII digit + NUL (Appendix A6).  It
+// does not validate anything past the header.  A header that does not
+// match that shape is ERR_CANON_HDR.
+func CanonVersion(canon []byte) (int, error) {
+	if len(canon) < len(canonHdr) ||
+		!bytes.Equal(canon[:3], canonHdr[:3]) ||
+		canon[4] != canonHdr[4] {
+		return 0, newErr(ErrCanonHdr, "bad CANON_HDR")
+	}
+	d := canon[3]
+	if d < '1' || d > '9' {
+		return 0, newErr(ErrCanonHdr, "bad framing version in CANON_HDR")
 	}
-	return "map1:" + sha256hex(canon), nil
+	return int(d - '0'), nil
 }
 
 // ── FULL projection API (§7) ────────────────────────────────
@@ -70,7 +143,35 @@ func CanonBytesBind(descriptor Value, pointers []string) ([]byte, error) {
 	return CanonBytesFromValue(proj)
 }
 
-// MIDFull computes MID over the full descriptor (§7.2).
+// CanonBytesAt returns CANON_BYTES for the subtree of descriptor that
+// pointer (RFC 6901) names, encoded as a standalone root.  Unlike BIND,
+// the pointer may step into a LIST by decimal index.  A pointer that
+// names no node is ERR_SCHEMA; "" names descriptor itself.  Only the
+// subtree is validated, so it can be hashed even where the rest of
+// descriptor would not encode.
+func CanonBytesAt(descriptor Value, pointer string) ([]byte, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := resolvePointer(descriptor, tokens)
+	if err != nil {
+		return nil, err
+	}
+	return CanonBytesFromValue(sub)
+}
+
+// MIDAt is the MID of CanonBytesAt(descriptor, pointer).
+func MIDAt(descriptor Value, pointer string) (string, error) {
+	canon, err := CanonBytesAt(descriptor, pointer)
+	if err != nil {
+		return "", err
+	}
+	return midOfCanon(canon), nil
+}
+
+// MIDFull computes MID over the full descriptor (§7.2).  FULL accepts
+// any root value, scalars included; only BIND requires a MAP root.
 func MIDFull(descriptor Value) (string, error) {
 	return MIDFromValue(descriptor)
 }
@@ -84,6 +185,193 @@ func MIDBind(descriptor Value, pointers []string) (string, error) {
 	return MIDFromValue(proj)
 }
 
+// MIDBindMulti computes one MID over a slice of fields drawn from
+// several descriptors.  descriptors[i] is projected by pointerSets[i]
+// exactly as MIDBind would, and the projections are hashed as a LIST in
+// input order: MID(LIST[proj_0, proj_1, …]).
+//
+// A pointer set that matches nothing contributes an empty MAP at its
+// position (rule 3) rather than being dropped, so positions stay stable.
+// Any BIND error aborts the whole computation.  The LIST adds one level
+// of nesting, so each projection may be at most MaxDepth-1 deep.
+func MIDBindMulti(descriptors []Value, pointerSets [][]string) (string, error) {
+	if len(descriptors) != len(pointerSets) {
+		return "", newErr(ErrSchema, "descriptor and pointer set counts differ")
+	}
+	projs := make(List, len(descriptors))
+	for i, d := range descriptors {
+		proj, err := BindProject(d, pointerSets[i])
+		if err != nil {
+			return "", err
+		}
+		projs[i] = proj
+	}
+	return MIDFromValue(projs)
+}
+
+// MIDBindCompiled is MIDBind with a precompiled pointer set.  The
+// result matches MIDBind for the same pointers.
+func MIDBindCompiled(descriptor Value, cp *CompiledPointers) (string, error) {
+	proj, err := BindProjectCompiled(descriptor, cp)
+	if err != nil {
+		return "", err
+	}
+	return MIDFromValue(proj)
+}
+
+// CanonBytesAndMIDFull returns CANON_BYTES and MID for FULL projection
+// from a single encode.  Outputs match CanonBytesFull and MIDFull.
+func CanonBytesAndMIDFull(descriptor Value) ([]byte, string, error) {
+	canon, err := CanonBytesFromValue(descriptor)
+	if err != nil {
+		return nil, "", err
+	}
+	return canon, midOfCanon(canon), nil
+}
+
+// MIDFullWithSize is MIDFull that also returns the length of the
+// CANON_BYTES it hashed, header included, from the same encode.
+func MIDFullWithSize(descriptor Value) (mid string, size int, err error) {
+	canon, err := CanonBytesFromValue(descriptor)
+	if err != nil {
+		return "", 0, err
+	}
+	return midOfCanon(canon), len(canon), nil
+}
+
+// CanonBytesAndMIDBind returns CANON_BYTES and MID for BIND projection
+// from a single encode.  Outputs match CanonBytesBind and MIDBind.
+func CanonBytesAndMIDBind(descriptor Value, pointers []string) ([]byte, string, error) {
+	proj, err := BindProject(descriptor, pointers)
+	if err != nil {
+		return nil, "", err
+	}
+	return CanonBytesAndMIDFull(proj)
+}
+
+// MIDFromValues returns the MID of the LIST of values, in argument
+// order: the identity of an ordered collection, however each element
+// was produced.  Order is significant — swapping two arguments changes
+// the MID — and no arguments hash as the empty LIST.  An element
+// error is reported as MIDFromValue would for the LIST.
+func MIDFromValues(values ...Value) (string, error) {
+	return MIDFromValue(List(values))
+}
+
+// MIDEachListElement returns the MID of each element of a root LIST,
+// each element treated as a standalone root (§5.3).  The root itself
+// must be a LIST (ERR_SCHEMA otherwise).
+func MIDEachListElement(v Value) ([]string, error) {
+	list, ok := v.(List)
+	if !ok {
+		return nil, newErr(ErrSchema, "root must be a LIST")
+	}
+	mids := make([]string, len(list))
+	for i, item := range list {
+		mid, err := MIDFromValue(item)
+		if err != nil {
+			return nil, err
+		}
+		mids[i] = mid
+	}
+	return mids, nil
+}
+
+// MIDFromListSlice returns the MID of elements [start, end) of a root
+// LIST, hashed as a new LIST standing as its own root: identity for one
+// page of a large array.  The slice is re-rooted: its MID is that of a
+// LIST of just those elements and does not match the parent's (unless
+// the range covers the whole LIST).  The root must be
+// a LIST and 0 <= start <= end <= len; anything else is ERR_SCHEMA.
+// Elements outside the range are not validated.
+func MIDFromListSlice(v Value, start, end int) (string, error) {
+	list, ok := v.(List)
+	if !ok {
+		return "", newErr(ErrSchema, "root must be a LIST")
+	}
+	if start < 0 || start > end || end > len(list) {
+		return "", newErr(ErrSchema, fmt.Sprintf("slice [%d:%d] out of range for LIST of %d", start, end, len(list)))
+	}
+	return MIDFromValue(list[start:end])
+}
+
+// MIDEachMapValue returns, for a root MAP, the MID of each top-level
+// value keyed by its key.  Only the first level is visited.  The root
+// must be a MAP (ERR_SCHEMA otherwise); its keys are checked as the
+// encoder would check them.
+func MIDEachMapValue(v Value) (map[string]string, error) {
+	m, ok := v.(*Map)
+	if !ok {
+		return nil, newErr(ErrSchema, "root must be a MAP")
+	}
+	mids := make(map[string]string, len(m.Keys))
+	for i, k := range m.Keys {
+		if err := validateUTF8Scalar([]byte(k)); err != nil {
+			return nil, err
+		}
+		if _, dup := mids[k]; dup {
+			return nil, newErr(ErrDupKey, "duplicate key")
+		}
+		mid, err := MIDFromValue(m.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		mids[k] = mid
+	}
+	return mids, nil
+}
+
+// MustMIDFull is MIDFull for known-good inputs; it panics on error.
+// Meant for tests and package initialization.
+func MustMIDFull(descriptor Value) string {
+	mid, err := MIDFull(descriptor)
+	if err != nil {
+		panic(err)
+	}
+	return mid
+}
+
+// MustCanonBytesFull is CanonBytesFull for known-good inputs; it
+// panics on error.
+func MustCanonBytesFull(descriptor Value) []byte {
+	canon, err := CanonBytesFull(descriptor)
+	if err != nil {
+		panic(err)
+	}
+	return canon
+}
+
+// MustMIDFromCanonBytes is MIDFromCanonBytes for known-good inputs; it
+// panics on error.
+func MustMIDFromCanonBytes(canon []byte) string {
+	mid, err := MIDFromCanonBytes(canon)
+	if err != nil {
+		panic(err)
+	}
+	return mid
+}
+
+// DefaultMIDPrefix is the scheme label of a MAP v1 MID (§5.3).
+const DefaultMIDPrefix = "map1:"
+
+// MIDFromValuePrefix is MIDFromValue with a caller-chosen label in
+// place of "map1:", for private namespacing.  The digest is unchanged,
+// so the hex part equals that of the map1: MID over the same value.
+// The prefix is used verbatim.  The result is not a MAP v1 MID: MID
+// methods and ParseMIDString reject it.
+func MIDFromValuePrefix(v Value, prefix string) (string, error) {
+	canon, err := CanonBytesFromValue(v)
+	if err != nil {
+		return "", err
+	}
+	return prefix + sha256hex(canon), nil
+}
+
+// midOfCanon returns the map1: MID of canon.
+func midOfCanon(canon []byte) string {
+	return DefaultMIDPrefix + sha256hex(canon)
+}
+
 func sha256hex(data []byte) string {
 	h := sha256.Sum256(data)
 	return hex.EncodeToString(h[:])
@@ -0,0 +1,100 @@
+package map1
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MID is a MAP identifier: "map1:" followed by 64 lowercase hex digits
+// (§5.3).  The *Typed functions return it in place of a bare string.
+type MID string
+
+// ParseMID splits a MID into its scheme and SHA-256 digest.  mid must
+// be "map1:" followed by exactly 64 lowercase hex digits (§5.3); the
+// scheme is then always "map1".  Anything else is ERR_SCHEMA, with a
+// message saying what is wrong.  It is the one MID parser: the MID
+// methods and ParseMIDString are built on it.
+func ParseMID(mid string) (scheme string, digest [32]byte, err error) {
+	scheme, hexDigest, ok := strings.Cut(mid, ":")
+	if !ok {
+		return "", digest, newErr(ErrSchema, "malformed MID: no scheme")
+	}
+	if scheme+":" != DefaultMIDPrefix {
+		return "", digest, newErr(ErrSchema, "malformed MID: unknown scheme "+strconv.Quote(scheme))
+	}
+	if len(hexDigest) != hex.EncodedLen(len(digest)) {
+		return "", digest, newErr(ErrSchema, fmt.Sprintf("malformed MID: digest has %d hex digits, want %d", len(hexDigest), hex.EncodedLen(len(digest))))
+	}
+	for i := 0; i < len(hexDigest); i++ {
+		if c := hexDigest[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", digest, newErr(ErrSchema, fmt.Sprintf("malformed MID: %q at digest offset %d is not a lowercase hex digit", c, i))
+		}
+	}
+	hex.Decode(digest[:], []byte(hexDigest))
+	return scheme, digest, nil
+}
+
+// ParseMIDString checks that s is a well-formed MID.  A malformed
+// string is ERR_SCHEMA, as from ParseMID.
+func ParseMIDString(s string) (MID, error) {
+	if _, _, err := ParseMID(s); err != nil {
+		return "", err
+	}
+	return MID(s), nil
+}
+
+// Valid reports whether m is "map1:" + 64 lowercase hex digits.
+func (m MID) Valid() bool {
+	_, _, err := ParseMID(string(m))
+	return err == nil
+}
+
+// Digest returns the 32-byte SHA-256 digest m carries, or nil if m is
+// not valid.
+func (m MID) Digest() []byte {
+	_, d, err := ParseMID(string(m))
+	if err != nil {
+		return nil
+	}
+	return d[:]
+}
+
+// Short returns the prefix and first 12 hex digits, for logs and
+// display.  It is not unique and must not be used as an identifier.
+func (m MID) Short() string {
+	if len(m) <= len(DefaultMIDPrefix)+12 {
+		return string(m)
+	}
+	return string(m)[:len(DefaultMIDPrefix)+12]
+}
+
+// Equal compares two MIDs in constant time.
+func (m MID) Equal(other MID) bool {
+	return subtle.ConstantTimeCompare([]byte(m), []byte(other)) == 1
+}
+
+// String implements fmt.Stringer.
+func (m MID) String() string {
+	return string(m)
+}
+
+// MIDFullTyped is MIDFull returning a MID.
+func MIDFullTyped(descriptor Value) (MID, error) {
+	mid, err := MIDFull(descriptor)
+	return MID(mid), err
+}
+
+// MIDBindTyped is MIDBind returning a MID.
+func MIDBindTyped(descriptor Value, pointers []string) (MID, error) {
+	mid, err := MIDBind(descriptor, pointers)
+	return MID(mid), err
+}
+
+// MIDFromCanonBytesTyped is MIDFromCanonBytes returning a MID.
+func MIDFromCanonBytesTyped(canon []byte) (MID, error) {
+	mid, err := MIDFromCanonBytes(canon)
+	return MID(mid), err
+}
@@ -0,0 +1,62 @@
+// Package midctx computes the MID of an RPC request and carries it in
+// a context.Context, for use as a request-level idempotency key.
+//
+// It has no RPC dependencies.  The request is turned into JSON text by
+// a caller-supplied MarshalFunc and hashed with map1.MIDFullJSON, so
+// for protobuf messages pass protojson.Marshal: a structpb.Struct then
+// maps field for field onto a MAP.  Its numbers are doubles on the
+// wire, so only integral values survive; a fraction, exponent or null
+// is ERR_TYPE, as in any JSON-STRICT input.
+//
+// For gRPC servers, the grpcmid module provides a ready-made unary
+// server interceptor built on this package; it is kept out of this
+// module so that it alone depends on gRPC.
+package midctx
+
+import (
+	"context"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+)
+
+// MarshalFunc renders a request message as JSON text.
+type MarshalFunc func(req any) ([]byte, error)
+
+// Handler is the shape of a unary RPC handler.
+type Handler func(ctx context.Context, req any) (any, error)
+
+type midKey struct{}
+
+// NewContext returns a copy of ctx carrying mid.
+func NewContext(ctx context.Context, mid string) context.Context {
+	return context.WithValue(ctx, midKey{}, mid)
+}
+
+// FromContext returns the MID stored in ctx by NewContext or Unary.
+func FromContext(ctx context.Context) (string, bool) {
+	mid, ok := ctx.Value(midKey{}).(string)
+	return mid, ok
+}
+
+// RequestMID returns the FULL MID of req as rendered by marshal.  An
+// error from marshal is returned as is; otherwise errors are those of
+// map1.MIDFullJSON.
+func RequestMID(req any, marshal MarshalFunc) (string, error) {
+	raw, err := marshal(req)
+	if err != nil {
+		return "", err
+	}
+	return map1.MIDFullJSON(raw)
+}
+
+// Unary computes the MID of req and calls handler with it stored in
+// ctx.  If the MID cannot be computed, handler is not called and the
+// error is returned: a request with no idempotency key must not run as
+// if it had one.
+func Unary(ctx context.Context, req any, marshal MarshalFunc, handler Handler) (any, error) {
+	mid, err := RequestMID(req, marshal)
+	if err != nil {
+		return nil, err
+	}
+	return handler(NewContext(ctx, mid), req)
+}
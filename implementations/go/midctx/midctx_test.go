@@ -0,0 +1,65 @@
+package midctx_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+	"github.com/map-protocol/map1/implementations/go/midctx"
+)
+
+func TestUnary(t *testing.T) {
+	req := map[string]any{"op": "charge", "amount": 1200}
+	want, err := map1.MIDFullJSON([]byte(`{"amount":1200,"op":"charge"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	resp, err := midctx.Unary(context.Background(), req, json.Marshal, func(ctx context.Context, r any) (any, error) {
+		mid, ok := midctx.FromContext(ctx)
+		if !ok {
+			t.Fatal("no MID in handler context")
+		}
+		got = mid
+		return "ok", nil
+	})
+	if err != nil || resp != "ok" {
+		t.Fatalf("Unary = %v, %v", resp, err)
+	}
+	if got != want {
+		t.Errorf("MID = %s, want %s", got, want)
+	}
+}
+
+func TestUnaryRejects(t *testing.T) {
+	called := false
+	handler := func(ctx context.Context, r any) (any, error) {
+		called = true
+		return nil, nil
+	}
+
+	// A fractional number has no INTEGER form.
+	_, err := midctx.Unary(context.Background(), map[string]any{"amount": 12.5}, json.Marshal, handler)
+	if me, ok := err.(*map1.MapError); !ok || me.Code != map1.ErrType {
+		t.Errorf("fraction: err = %v, want %s", err, map1.ErrType)
+	}
+
+	boom := errors.New("boom")
+	_, err = midctx.Unary(context.Background(), nil, func(any) ([]byte, error) { return nil, boom }, handler)
+	if err != boom {
+		t.Errorf("marshal error: err = %v, want %v", err, boom)
+	}
+
+	if called {
+		t.Error("handler called without a MID")
+	}
+}
+
+func TestFromContextEmpty(t *testing.T) {
+	if mid, ok := midctx.FromContext(context.Background()); ok {
+		t.Errorf("FromContext = %q, true on a bare context", mid)
+	}
+}
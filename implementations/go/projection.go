@@ -1,6 +1,11 @@
 package map1
 
-import "strings"
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
 // FullProject returns the descriptor unchanged (§2.2).
 func FullProject(descriptor Value) Value {
@@ -26,8 +31,123 @@ func BindProject(descriptor Value, pointers []string) (Value, error) {
 	if !ok {
 		return nil, newErr(ErrSchema, "BIND root must be a MAP")
 	}
+	parsed, err := parsePointerSet(pointers)
+	if err != nil {
+		return nil, err
+	}
+	return bindApply(root, parsed, effectivePaths(parsed))
+}
+
+// CompiledPointers is a BIND pointer set parsed and analysed once
+// (rules a, b, d) for reuse across many descriptors.
+type CompiledPointers struct {
+	parsed    []parsedPtr
+	effective [][]string
+}
+
+// CompilePointers parses pointers for repeated BIND projections.  It
+// fails with the same pointer-set errors BindProject would report.
+func CompilePointers(pointers []string) (*CompiledPointers, error) {
+	parsed, err := parsePointerSet(pointers)
+	if err != nil {
+		return nil, err
+	}
+	return &CompiledPointers{parsed: parsed, effective: effectivePaths(parsed)}, nil
+}
+
+// BindProjectCompiled is BindProject with a precompiled pointer set.
+func BindProjectCompiled(descriptor Value, cp *CompiledPointers) (Value, error) {
+	root, ok := descriptor.(*Map)
+	if !ok {
+		return nil, newErr(ErrSchema, "BIND root must be a MAP")
+	}
+	return bindApply(root, cp.parsed, cp.effective)
+}
+
+// BindValidate runs every BIND check from BindProject — pointer
+// parsing, duplicate pointers, LIST traversal and match status — and
+// returns the error BindProject would, without building the projection.
+// A pointer set that matches nothing is valid (it projects to an empty
+// MAP, rule 3).
+func BindValidate(descriptor Value, pointers []string) error {
+	root, ok := descriptor.(*Map)
+	if !ok {
+		return newErr(ErrSchema, "BIND root must be a MAP")
+	}
+	parsed, err := parsePointerSet(pointers)
+	if err != nil {
+		return err
+	}
+	_, err = bindMatch(root, parsed)
+	return err
+}
+
+// PointerWarning is a BindLint finding: a pointer that is legal but
+// probably not what the caller meant.
+type PointerWarning struct {
+	Pointer string
+	Reason  string
+}
 
-	// Rule (b): no duplicate pointer strings.
+// BindLint flags suspicious pointers without failing: empty reference
+// tokens ("/a//b"), a trailing slash ("/a/b/", which selects the key ""
+// under b rather than b itself), pointers that do not parse, and
+// pointers made redundant by a shorter one in the set (rule d drops
+// them silently).  BindProject's behavior is unaffected; it is up to
+// the caller what to do with the findings.
+func BindLint(pointers []string) []PointerWarning {
+	var warns []PointerWarning
+	var parsed []parsedPtr
+	for _, ptr := range pointers {
+		tokens, err := parsePointer(ptr)
+		if err != nil {
+			warns = append(warns, PointerWarning{ptr, "does not parse: " + err.(*MapError).Msg})
+			continue
+		}
+		switch {
+		case len(tokens) > 0 && tokens[len(tokens)-1] == "":
+			warns = append(warns, PointerWarning{ptr, "trailing slash selects the empty key"})
+		case containsEmpty(tokens):
+			warns = append(warns, PointerWarning{ptr, "empty reference token"})
+		}
+		parsed = append(parsed, parsedPtr{raw: ptr, tokens: tokens})
+	}
+	for _, pp := range parsed {
+		for _, other := range parsed {
+			if tokensPrefix(other.tokens, pp.tokens) {
+				by := other.raw
+				if by == "" {
+					by = `"" (the whole descriptor)`
+				}
+				warns = append(warns, PointerWarning{pp.raw, "redundant: subsumed by " + by})
+				break
+			}
+		}
+	}
+	return warns
+}
+
+func containsEmpty(tokens []string) bool {
+	for _, t := range tokens {
+		if t == "" {
+			return true
+		}
+	}
+	return false
+}
+
+type parsedPtr struct {
+	raw    string
+	tokens []string
+}
+
+// parsePointerSet applies the descriptor-independent rules: (b) no
+// duplicate pointer strings, then (a) parse every pointer.
+func parsePointerSet(pointers []string) ([]parsedPtr, error) {
+	// Rule (b): no duplicate pointer strings.  Comparing strings is
+	// comparing token paths: RFC 6901 escaping is injective ("~" only
+	// as "~0", "/" only as "~1", a bare "~" is invalid), so no two
+	// valid spellings decode to the same path.
 	seen := make(map[string]bool, len(pointers))
 	for _, p := range pointers {
 		if seen[p] {
@@ -37,10 +157,6 @@ func BindProject(descriptor Value, pointers []string) (Value, error) {
 	}
 
 	// Rule (a): parse all pointers up front.
-	type parsedPtr struct {
-		raw    string
-		tokens []string
-	}
 	parsed := make([]parsedPtr, len(pointers))
 	for i, ptr := range pointers {
 		tokens, err := parsePointer(ptr)
@@ -49,12 +165,34 @@ func BindProject(descriptor Value, pointers []string) (Value, error) {
 		}
 		parsed[i] = parsedPtr{raw: ptr, tokens: tokens}
 	}
+	return parsed, nil
+}
 
-	// Walk each pointer to determine match status.
-	type matchedPath struct {
-		tokens []string
+// bindApply projects root by an already-parsed pointer set whose
+// effective (non-subsumed) paths have been computed.
+func bindApply(root *Map, parsed []parsedPtr, effective [][]string) (Value, error) {
+	anyMatch, err := bindMatch(root, parsed)
+	if err != nil {
+		return nil, err
+	}
+	if !anyMatch {
+		return EmptyMap(), nil // Rule (3)
 	}
-	var matched []matchedPath
+
+	// Rule (e): if any pointer is "", result is full descriptor.
+	for _, pp := range parsed {
+		if pp.raw == "" {
+			return root, nil
+		}
+	}
+
+	return projectPaths(root, effective)
+}
+
+// bindMatch walks each pointer to determine match status and applies
+// rule (c): reports whether anything matched, and fails closed if some
+// but not all pointers matched.
+func bindMatch(root *Map, parsed []parsedPtr) (bool, error) {
 	anyMatch := false
 	anyUnmatched := false
 
@@ -64,86 +202,72 @@ func BindProject(descriptor Value, pointers []string) (Value, error) {
 			anyMatch = true
 			continue
 		}
-		cur := Value(root)
-		ok := true
-		for _, tok := range pp.tokens {
-			// Rule (4): LIST traversal forbidden.
-			if _, isList := cur.(List); isList {
-				return nil, newErr(ErrSchema, "BIND cannot traverse LIST")
-			}
-			m, isMap := cur.(*Map)
-			if !isMap {
-				ok = false
-				break
-			}
-			found := false
-			for j, k := range m.Keys {
-				if k == tok {
-					cur = m.Values[j]
-					found = true
-					break
-				}
-			}
-			if !found {
-				ok = false
-				break
-			}
+		_, ok, err := resolvePath(root, pp.tokens)
+		if err != nil {
+			return false, err
 		}
 		if ok {
 			anyMatch = true
-			matched = append(matched, matchedPath{tokens: pp.tokens})
 		} else {
 			anyUnmatched = true
 		}
 	}
 
 	// Rule (c): unmatched pointer handling.
-	if !anyMatch {
-		return EmptyMap(), nil // Rule (3)
-	}
-	if anyUnmatched {
-		return nil, newErr(ErrSchema, "unmatched pointer in set")
+	if anyMatch && anyUnmatched {
+		return true, newErr(ErrSchema, "unmatched pointer in set")
 	}
+	return anyMatch, nil
+}
 
-	// Rule (e): if any pointer is "", result is full descriptor.
-	for _, pp := range parsed {
-		if pp.raw == "" {
-			return root, nil
+// resolvePath follows tokens from root through MAP levels.  ok is false
+// if the path does not exist.  Rule (4): reaching a LIST with tokens
+// left is ERR_SCHEMA.
+func resolvePath(root *Map, tokens []string) (Value, bool, error) {
+	cur := Value(root)
+	for _, tok := range tokens {
+		if _, isList := cur.(List); isList {
+			return nil, false, newErr(ErrSchema, "BIND cannot traverse LIST")
+		}
+		m, isMap := cur.(*Map)
+		if !isMap {
+			return nil, false, nil
+		}
+		v := mapGet(m, tok)
+		if v == nil {
+			return nil, false, nil
 		}
+		cur = v
 	}
+	return cur, true, nil
+}
 
-	// Rule (d): discard subsumed pointers.
-	effective := make([][]string, 0, len(matched))
-	for _, mp := range matched {
+// effectivePaths applies rule (d): discard pointers subsumed by a
+// shorter pointer in the set.  Only meaningful once every pointer is
+// known to match.
+func effectivePaths(parsed []parsedPtr) [][]string {
+	effective := make([][]string, 0, len(parsed))
+	for _, pp := range parsed {
 		subsumed := false
-		for _, other := range matched {
-			if len(other.tokens) < len(mp.tokens) {
-				if tokensPrefix(other.tokens, mp.tokens) {
-					subsumed = true
-					break
-				}
+		for _, other := range parsed {
+			if tokensPrefix(other.tokens, pp.tokens) {
+				subsumed = true
+				break
 			}
 		}
 		if !subsumed {
-			effective = append(effective, mp.tokens)
+			effective = append(effective, pp.tokens)
 		}
 	}
+	return effective
+}
 
-	// Build projected tree — rule (1) omit-siblings, rule (2) minimal structure.
+// projectPaths builds the projected tree — rule (1) omit-siblings,
+// rule (2) minimal structure.  Every path must resolve in root.
+func projectPaths(root *Map, effective [][]string) (Value, error) {
 	projected := &Map{}
 	for _, toks := range effective {
-		cur := Value(root)
-		// Resolve the leaf value.
-		for _, tok := range toks {
-			m := cur.(*Map) // safe — we already validated the path
-			for j, k := range m.Keys {
-				if k == tok {
-					cur = m.Values[j]
-					break
-				}
-			}
-		}
-		leaf := cur
+		leaf, _, _ := resolvePath(root, toks) // safe — we already validated the path
 
 		// Walk the projected tree, creating nested Maps as needed.
 		target := projected
@@ -211,6 +335,87 @@ func parsePointer(ptr string) ([]string, error) {
 	return tokens, nil
 }
 
+// ParseURIFragmentPointer converts a URI-fragment JSON Pointer
+// (RFC 6901 §6, e.g. "#/users/0/na%20me") into the plain string form
+// BIND accepts: the leading '#' is stripped and percent-escapes are
+// decoded before the usual ~0/~1 token rules apply.  A missing '#',
+// a bad percent escape, non-UTF-8 result or invalid pointer is
+// ERR_SCHEMA.
+func ParseURIFragmentPointer(frag string) (string, error) {
+	rest, ok := strings.CutPrefix(frag, "#")
+	if !ok {
+		return "", newErr(ErrSchema, "URI fragment pointer must start with '#'")
+	}
+	ptr, err := url.PathUnescape(rest)
+	if err != nil {
+		return "", newErr(ErrSchema, "bad percent escape in pointer")
+	}
+	if !utf8.ValidString(ptr) {
+		return "", newErr(ErrSchema, "pointer is not valid UTF-8")
+	}
+	if _, err := parsePointer(ptr); err != nil {
+		return "", err
+	}
+	return ptr, nil
+}
+
+// resolvePointer follows tokens from v with read semantics: unlike
+// BIND, a LIST is entered by decimal index.  A path that does not
+// exist is ERR_SCHEMA; a key that occurs more than once along it is
+// ERR_DUP_KEY, since the pointer is then ambiguous.
+func resolvePointer(v Value, tokens []string) (Value, error) {
+	for _, tok := range tokens {
+		switch cur := v.(type) {
+		case *Map:
+			found := false
+			for i, k := range cur.Keys {
+				if k != tok {
+					continue
+				}
+				if found {
+					return nil, newErr(ErrDupKey, "pointer names a duplicate key")
+				}
+				v, found = cur.Values[i], true
+			}
+			if !found {
+				return nil, newErr(ErrSchema, "pointer does not match an existing key")
+			}
+		case List:
+			i, err := parseListIndex(tok, len(cur))
+			if err != nil {
+				return nil, err
+			}
+			v = cur[i]
+		default:
+			return nil, newErr(ErrSchema, "pointer traverses a scalar")
+		}
+	}
+	return v, nil
+}
+
+// parseListIndex parses an RFC 6901 array index — "0" or digits without
+// a leading zero — into a LIST of n items.
+func parseListIndex(tok string, n int) (int, error) {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return 0, newErr(ErrSchema, "bad list index in pointer")
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || i >= n {
+		return 0, newErr(ErrSchema, "list index out of range")
+	}
+	return i, nil
+}
+
+// escapePointerToken applies RFC 6901 escaping to a single reference
+// token: "~" → "~0", "/" → "~1".  Order matters — "~" goes first.
+func escapePointerToken(tok string) string {
+	if !strings.ContainsAny(tok, "~/") {
+		return tok
+	}
+	tok = strings.ReplaceAll(tok, "~", "~0")
+	return strings.ReplaceAll(tok, "/", "~1")
+}
+
 // tokensPrefix returns true if a is a strict prefix of b.
 func tokensPrefix(a, b []string) bool {
 	if len(a) >= len(b) {
@@ -0,0 +1,88 @@
+package map1_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	map1 "github.com/map-protocol/map1/implementations/go"
+)
+
+// randValue builds a random valid tree.  Keys are drawn from a small
+// alphabet (including a multi-byte rune) so sorting is exercised and
+// collisions are skipped rather than producing duplicates.
+func randValue(r *rand.Rand, depth int) map1.Value {
+	kind := r.Intn(6)
+	if depth >= 6 && kind >= 2 {
+		kind = r.Intn(2)
+	}
+	switch kind {
+	case 0:
+		return map1.Integer(r.Int63() - r.Int63())
+	case 1:
+		return map1.Bool(r.Intn(2) == 0)
+	case 2:
+		return map1.String(randKey(r))
+	case 3:
+		b := make([]byte, r.Intn(8))
+		r.Read(b)
+		return map1.Bytes(b)
+	case 4:
+		l := make(map1.List, r.Intn(5))
+		for i := range l {
+			l[i] = randValue(r, depth+1)
+		}
+		return l
+	default:
+		m := &map1.Map{}
+		seen := map[string]bool{}
+		for i := r.Intn(6); i > 0; i-- {
+			k := randKey(r)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			m.Keys = append(m.Keys, k)
+			m.Values = append(m.Values, randValue(r, depth+1))
+		}
+		return m
+	}
+}
+
+func randKey(r *rand.Rand) string {
+	const alphabet = "abAB~/é\x00"
+	runes := []rune(alphabet)
+	out := make([]rune, r.Intn(4))
+	for i := range out {
+		out[i] = runes[r.Intn(len(runes))]
+	}
+	return string(out)
+}
+
+// TestDecodeEncodeIdentity checks decode∘encode is the identity on
+// canonical values and that re-encoding is byte-identical.
+func TestDecodeEncodeIdentity(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		v := randValue(r, 0)
+		canon, err := map1.CanonBytesFull(v)
+		if err != nil {
+			t.Fatalf("case %d: encode: %v", i, err)
+		}
+		decoded, err := map1.DecodeCanonBytes(canon)
+		if err != nil {
+			t.Fatalf("case %d: decode: %v", i, err)
+		}
+		want, err := map1.Canonicalize(v)
+		if err != nil {
+			t.Fatalf("case %d: canonicalize: %v", i, err)
+		}
+		if !map1.Equal(decoded, want) || !map1.Equal(v, want) {
+			t.Fatalf("case %d: decoded value differs from canonical form", i)
+		}
+		again, err := map1.CanonBytesFull(decoded)
+		if err != nil || !bytes.Equal(again, canon) {
+			t.Fatalf("case %d: re-encode differs (%v)", i, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package map1
+
+import "sync"
+
+// SafeMap is a *Map guarded by a sync.RWMutex, for descriptors that
+// are mutated and hashed from different goroutines.
+//
+// Only the top level is guarded: values stored in a SafeMap must be
+// treated as immutable once stored.  Replace a nested value with Set
+// rather than mutating it in place.
+type SafeMap struct {
+	mu sync.RWMutex
+	m  Map
+}
+
+// NewSafeMap creates a SafeMap from entries.  Like NewMap it does not
+// sort or validate.
+func NewSafeMap(entries ...MapEntry) *SafeMap {
+	return &SafeMap{m: *NewMap(entries...)}
+}
+
+// Set stores val under key, replacing any existing entry.
+func (s *SafeMap) Set(key string, val Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mapSet(&s.m, key, val)
+}
+
+// Get returns the value stored under key.
+func (s *SafeMap) Get(key string) (Value, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v := mapGet(&s.m, key)
+	return v, v != nil
+}
+
+// Delete removes the entry for key, if present.
+func (s *SafeMap) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.m.Keys {
+		if k == key {
+			s.m.Keys = append(s.m.Keys[:i], s.m.Keys[i+1:]...)
+			s.m.Values = append(s.m.Values[:i], s.m.Values[i+1:]...)
+			return
+		}
+	}
+}
+
+// Snapshot returns a point-in-time *Map.  The top-level slices are
+// copied; nested values are shared.
+func (s *SafeMap) Snapshot() *Map {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &Map{
+		Keys:   append([]string(nil), s.m.Keys...),
+		Values: append([]Value(nil), s.m.Values...),
+	}
+}
+
+// MID computes the FULL MID of the current contents.
+func (s *SafeMap) MID() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return MIDFull(&s.m)
+}
@@ -0,0 +1,183 @@
+package map1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// MIDFromCanonBytesFast is MIDFromCanonBytes without materializing the
+// decoded tree.  Structure is checked by cursor movement only — tags,
+// length prefixes, key order/uniqueness (compared in place), UTF-8 and
+// limits — then the input is hashed.  It accepts and rejects exactly
+// the same inputs, with the same codes, as MIDFromCanonBytes.
+func MIDFromCanonBytesFast(canon []byte) (string, error) {
+	if err := scanCanonBytes(canon); err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(canon)
+	var out [len(DefaultMIDPrefix) + 2*sha256.Size]byte
+	copy(out[:], DefaultMIDPrefix)
+	hex.Encode(out[len(DefaultMIDPrefix):], h[:])
+	return string(out[:]), nil
+}
+
+// VerifyMIDCanonBytes reports whether canon is valid CANON_BYTES whose
+// MID is mid.  Validation is the MIDFromCanonBytesFast scan and the
+// digest is compared in constant time, with no allocation on success
+// or mismatch — the path for verifiers checking content against a MID
+// at high rates.  A malformed mid is ERR_SCHEMA (see ParseMID); invalid
+// canon is the error MIDFromCanonBytes would return.
+func VerifyMIDCanonBytes(canon []byte, mid string) (bool, error) {
+	_, want, err := ParseMID(mid)
+	if err != nil {
+		return false, err
+	}
+	if err := scanCanonBytes(canon); err != nil {
+		return false, err
+	}
+	got := sha256.Sum256(canon)
+	return subtle.ConstantTimeCompare(got[:], want[:]) == 1, nil
+}
+
+// scanCanonBytes validates CANON_BYTES exactly as MIDFromCanonBytes
+// does, without decoding.
+func scanCanonBytes(canon []byte) error {
+	if !bytes.HasPrefix(canon, canonHdr) {
+		return newErr(ErrCanonHdr, "bad CANON_HDR")
+	}
+	if len(canon) > MaxCanonBytes {
+		return newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+	}
+	var d mcfDecoder
+	end, err := d.scanOne(canon, len(canonHdr), 0)
+	if err == nil && end != len(canon) {
+		err = trailingBytesErr(canon, end)
+	}
+	return reportedError(d.soft, err)
+}
+
+// scanOne validates one MCF value at off and returns the offset just
+// past it, or on a hard error the offset locating it.  Checks, their
+// order, which violations are soft and error offsets mirror decodeOne
+// exactly; keep the two in sync.
+func (d *mcfDecoder) scanOne(buf []byte, off int, depth int) (int, error) {
+	start := off
+	if off >= len(buf) {
+		return start, newErr(ErrCanonMCF, "truncated tag")
+	}
+	tag := buf[off]
+	off++
+
+	switch tag {
+
+	case tagString:
+		n, newOff, err := readU32BE(buf, off)
+		if err != nil {
+			return start, err
+		}
+		off = newOff
+		if !payloadFits(buf, off, n) {
+			return start, newErr(ErrCanonMCF, "truncated string payload")
+		}
+		if err := validateUTF8Scalar(buf[off : off+int(n)]); err != nil {
+			d.addSoft(err.(*MapError), start)
+		}
+		return off + int(n), nil
+
+	case tagBytes:
+		n, newOff, err := readU32BE(buf, off)
+		if err != nil {
+			return start, err
+		}
+		off = newOff
+		if !payloadFits(buf, off, n) {
+			return start, newErr(ErrCanonMCF, "truncated bytes payload")
+		}
+		return off + int(n), nil
+
+	case tagList:
+		if depth+1 > MaxDepth {
+			return start, newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
+		}
+		count, newOff, err := readU32BE(buf, off)
+		if err != nil {
+			return start, err
+		}
+		off = newOff
+		if count > MaxListEntries {
+			return start, newErr(ErrLimitSize, "list entry count exceeds limit")
+		}
+		for i := uint32(0); i < count; i++ {
+			if off, err = d.scanOne(buf, off, depth+1); err != nil {
+				return off, err
+			}
+		}
+		return off, nil
+
+	case tagMap:
+		if depth+1 > MaxDepth {
+			return start, newErr(ErrLimitDepth, "depth exceeds MAX_DEPTH")
+		}
+		count, newOff, err := readU32BE(buf, off)
+		if err != nil {
+			return start, err
+		}
+		off = newOff
+		if count > MaxMapEntries {
+			return start, newErr(ErrLimitSize, "map entry count exceeds limit")
+		}
+		var prevKey []byte
+		for i := uint32(0); i < count; i++ {
+			keyOff := off
+			if off >= len(buf) {
+				return keyOff, newErr(ErrCanonMCF, "truncated map key tag")
+			}
+			stringKey := buf[off] == tagString
+			if !stringKey {
+				d.addSoft(badKeyTag(buf, off), keyOff)
+			}
+			keyStart := off + 1 + 4
+			if off, err = d.scanOne(buf, off, depth+1); err != nil {
+				return off, err
+			}
+			if stringKey {
+				kb := buf[keyStart:off]
+				if prevKey != nil {
+					cmp := bytes.Compare(prevKey, kb)
+					if cmp == 0 {
+						d.addSoft(newErr(ErrDupKey, "duplicate key in MCF"), keyOff)
+					}
+					if cmp > 0 {
+						d.addSoft(newErr(ErrKeyOrder, "key order violation in MCF"), keyOff)
+					}
+				}
+				prevKey = kb
+			}
+			if off, err = d.scanOne(buf, off, depth+1); err != nil {
+				return off, err
+			}
+		}
+		return off, nil
+
+	case tagBoolean:
+		if off >= len(buf) {
+			return start, newErr(ErrCanonMCF, "truncated boolean payload")
+		}
+		if buf[off] != 0x00 && buf[off] != 0x01 {
+			return start, newErr(ErrCanonMCF, "invalid boolean payload")
+		}
+		return off + 1, nil
+
+	case tagInteger:
+		if off+8 > len(buf) {
+			return start, newErr(ErrCanonMCF, "truncated integer payload")
+		}
+		// Any 8 bytes are a valid int64.
+		return off + 8, nil
+
+	default:
+		return start, newErr(ErrCanonMCF, "unknown MCF tag")
+	}
+}
@@ -0,0 +1,28 @@
+package map1
+
+// SignedCanonBytes computes the CANON_BYTES of v once and passes them to
+// signer, returning both, so what is signed is exactly what is stored
+// or sent.  The signature scheme (Ed25519, HMAC, …) is the caller's; an
+// error from signer is returned as is, with no canon.
+func SignedCanonBytes(v Value, signer func([]byte) ([]byte, error)) (canon, sig []byte, err error) {
+	canon, err = CanonBytesFromValue(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err = signer(canon)
+	if err != nil {
+		return nil, nil, err
+	}
+	return canon, sig, nil
+}
+
+// VerifyCanonSignature validates canon exactly as MIDFromCanonBytes does
+// and, if it is well-formed CANON_BYTES, reports verifier(canon, sig).
+// A signature over malformed bytes never verifies: the validation error
+// is returned and verifier is not called.
+func VerifyCanonSignature(canon, sig []byte, verifier func(canon, sig []byte) bool) (bool, error) {
+	if err := scanCanonBytes(canon); err != nil {
+		return false, err
+	}
+	return verifier(canon, sig), nil
+}
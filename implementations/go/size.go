@@ -0,0 +1,53 @@
+package map1
+
+// EncodedSize returns len(CanonBytesFull(v)) without encoding, or the
+// error CanonBytesFull would report.
+func EncodedSize(v Value) (int, error) {
+	w := &validator{}
+	size := len(canonHdr) + w.walk(v, "", 0)
+	if size > MaxCanonBytes {
+		w.add(ErrLimitSize, "", "canon bytes exceed MAX_CANON_BYTES")
+	}
+	if err := reportedError(w.errs, nil); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// SizeBreakdown attributes v's CANON_BYTES size to its top-level
+// entries, to find the culprit behind an ERR_LIMIT_SIZE.  Each key
+// "/<escaped key>" maps to the bytes its entry costs (key framing plus
+// the encoded value); "" holds the header and root container framing.
+// The values sum to the encoded size.  A non-MAP root is reported
+// whole under "".
+//
+// Size limits, entry counts included, are not enforced — that is the
+// point — so every entry is sized in full.  Any other violation is
+// returned as the encoder would report it.
+func SizeBreakdown(v Value) (map[string]int, error) {
+	w := &validator{sizeAll: true}
+	total := len(canonHdr) + w.walk(v, "", 0)
+	out := make(map[string]int)
+	m, ok := v.(*Map)
+	if !ok {
+		out[""] = total
+	} else {
+		// Size each entry on its own; violations came from the walk above.
+		out[""] = len(canonHdr) + 5
+		sz := &validator{sizeAll: true}
+		for i, k := range m.Keys {
+			p := "/" + escapePointerToken(k)
+			out[p] = 5 + len(k) + sz.walk(m.Values[i], p, 1)
+		}
+	}
+	var errs []*MapError
+	for _, e := range w.errs {
+		if e.Code != ErrLimitSize {
+			errs = append(errs, e)
+		}
+	}
+	if err := reportedError(errs, nil); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,119 @@
+package map1
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ContentStore persists canonical values addressed by their MID.
+type ContentStore interface {
+	// Put stores v and returns its FULL MID.  Storing a value that is
+	// already present is a no-op.
+	Put(v Value) (string, error)
+	// Get returns the value stored under mid.  Missing objects yield
+	// an error matching fs.ErrNotExist.
+	Get(mid string) (Value, error)
+	// Has reports whether an object for mid is present.
+	Has(mid string) (bool, error)
+}
+
+// FileStore is a git-style loose object store: the CANON_BYTES for
+// "map1:abcdef…" live at <root>/ab/cdef….  Writes are atomic (temp
+// file + rename) and deduplicated.
+type FileStore struct {
+	root string
+}
+
+var _ ContentStore = (*FileStore)(nil)
+
+// NewFileStore returns a FileStore rooted at dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{root: dir}, nil
+}
+
+// objectPath maps a MID to its file path, rejecting anything that is
+// not "map1:" + 64 lowercase hex so a MID can never escape the root.
+func (s *FileStore) objectPath(mid string) (string, error) {
+	if _, err := ParseMIDString(mid); err != nil {
+		return "", err
+	}
+	digest := mid[len(DefaultMIDPrefix):]
+	return filepath.Join(s.root, digest[:2], digest[2:]), nil
+}
+
+// Put implements ContentStore.
+func (s *FileStore) Put(v Value) (string, error) {
+	canon, mid, err := CanonBytesAndMIDFull(v)
+	if err != nil {
+		return "", err
+	}
+	path, _ := s.objectPath(mid)
+	if _, err := os.Stat(path); err == nil {
+		return mid, nil
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if _, err := tmp.Write(canon); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return mid, nil
+}
+
+// Get implements ContentStore.  The stored bytes are fully validated
+// and re-hashed; an object whose content does not match its name is
+// reported as corrupt rather than returned.
+func (s *FileStore) Get(mid string) (Value, error) {
+	path, err := s.objectPath(mid)
+	if err != nil {
+		return nil, err
+	}
+	canon, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	got, err := MIDFromCanonBytes(canon)
+	if err != nil {
+		return nil, err
+	}
+	if got != mid {
+		return nil, fmt.Errorf("map1: object %s is corrupt (content hashes to %s)", mid, got)
+	}
+	return decodeRoot(canon, len(canonHdr))
+}
+
+// Has implements ContentStore.
+func (s *FileStore) Has(mid string) (bool, error) {
+	path, err := s.objectPath(mid)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,142 @@
+package map1
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+// ValidateAll checks v against every encode-time rule and returns all
+// violations, each tagged with the JSON Pointer of the offending node.
+// Returns nil if v would encode cleanly.  A MAP or LIST over its entry
+// limit ends the walk: its contents and anything after it are not
+// checked.
+//
+// This is the linter entry point.  The encode and MID functions report
+// a single error, the one MultiError.Code() names under §6.2.
+func ValidateAll(v Value) *MultiError {
+	w := &validator{}
+	size := len(canonHdr) + w.walk(v, "", 0)
+	if size > MaxCanonBytes {
+		w.add(ErrLimitSize, "", "canon bytes exceed MAX_CANON_BYTES")
+	}
+	if len(w.errs) == 0 {
+		return nil
+	}
+	return &MultiError{errs: w.errs}
+}
+
+type validator struct {
+	opts EncodeOptions
+	errs []*MapError
+	// stop is set once a container breaches an entry-count limit.
+	// Walking it would mean unbounded work, so validation ends there
+	// (§6.2 safety short-circuit).
+	stop bool
+	// sizeAll disables the short-circuit so every node is sized, for
+	// SizeBreakdown, whose input is expected to be over the limits.
+	sizeAll bool
+}
+
+func (w *validator) add(code, path, msg string) {
+	w.errs = append(w.errs, &MapError{Code: code, Msg: msg, Path: path})
+}
+
+// walk records violations under v and returns its MCF size in bytes.
+// Depth semantics mirror mcfEncodeTo.  A container that breaches
+// MaxDepth is reported once and not descended into.
+func (w *validator) walk(v Value, path string, depth int) int {
+	if w.stop {
+		return 0
+	}
+	switch val := v.(type) {
+
+	case Bool:
+		return 2
+
+	case Integer:
+		return 9
+
+	case Null:
+		if !w.opts.AllowNull {
+			w.add(ErrType, path, "NULL is an extension (EncodeOptions.AllowNull)")
+		}
+		return 5
+
+	case String:
+		if err := validateUTF8Scalar([]byte(val)); err != nil {
+			w.add(ErrUTF8, path, err.(*MapError).Msg)
+		}
+		return 5 + len(val)
+
+	case Bytes:
+		return 5 + len(val)
+
+	case List:
+		if depth+1 > MaxDepth {
+			w.add(ErrLimitDepth, path, "depth exceeds MAX_DEPTH")
+			return 5
+		}
+		if len(val) > MaxListEntries {
+			w.add(ErrLimitSize, path, "list entry count exceeds limit")
+			if !w.sizeAll {
+				w.stop = true
+				return 5
+			}
+		}
+		size := 5
+		for i, item := range val {
+			size += w.walk(item, path+"/"+strconv.Itoa(i), depth+1)
+		}
+		return size
+
+	case *Map:
+		if depth+1 > MaxDepth {
+			w.add(ErrLimitDepth, path, "depth exceeds MAX_DEPTH")
+			return 5
+		}
+		if len(val.Keys) > MaxMapEntries {
+			w.add(ErrLimitSize, path, "map entry count exceeds limit")
+			if !w.sizeAll {
+				w.stop = true
+				return 5
+			}
+		}
+		// Visit entries in canonical key order so reports are stable
+		// regardless of construction order.
+		order := make([]int, len(val.Keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return val.Keys[order[i]] < val.Keys[order[j]]
+		})
+		size := 5
+		var prev []byte
+		for n, i := range order {
+			k := val.Keys[i]
+			kb := []byte(k)
+			childPath := path + "/" + escapePointerToken(k)
+			if err := validateUTF8Scalar(kb); err != nil {
+				w.add(ErrUTF8, childPath, "key: "+err.(*MapError).Msg)
+			}
+			if k == "" && w.opts.RejectEmptyKeys {
+				w.add(ErrSchema, childPath, "empty MAP key (RejectEmptyKeys)")
+			}
+			if n > 0 && bytes.Equal(prev, kb) {
+				w.add(ErrDupKey, childPath, "duplicate key")
+				// The duplicate's value is never encoded but may still
+				// hold a violation that outranks ERR_DUP_KEY.
+				w.walk(val.Values[i], childPath, depth+1)
+				continue
+			}
+			prev = kb
+			size += 5 + len(kb) + w.walk(val.Values[i], childPath, depth+1)
+		}
+		return size
+
+	default:
+		w.add(ErrSchema, path, "unsupported value type")
+		return 0
+	}
+}
@@ -11,9 +11,20 @@
 // validation of pre-serialized CANON_BYTES.  A JSON-STRICT adapter (§8)
 // converts raw UTF-8 JSON into the canonical model.
 //
+// Concurrency: every package-level function is safe for concurrent use.
+// Encoding only reads its input, so many goroutines may hash the same
+// Value tree at once — provided nobody mutates that tree (including a
+// *Map's Keys/Values slices) while it is being encoded.  Code that must
+// mutate a shared map should use SafeMap.
+//
 // Zero external dependencies beyond the standard library.
 package map1
 
+import (
+	"sort"
+	"strings"
+)
+
 // Value is a canonical model value.  Concrete types:
 //
 //   - String  (STRING, §3.1)
@@ -22,6 +33,8 @@ package map1
 //   - Map     (MAP, §3.1)
 //   - Bool    (BOOLEAN, §3.1 — v1.1)
 //   - Integer (INTEGER, §3.1 — v1.1)
+//
+// plus Null, an opt-in extension outside MAP v1.1.
 type Value interface {
 	mapValue() // sealed marker — only types in this package implement Value
 }
@@ -37,9 +50,17 @@ type List []Value
 
 // Map is a MAP v1 MAP value.  Ordered key/value pairs with unique string keys.
 // Keys are stored as raw strings; ordering/uniqueness enforced at encode time.
+//
+// Sorted marks Keys as being in canonical order (§3.5), as Canonicalize,
+// Merge and the MCF decoder leave them.  The encoder writes a marked
+// map straight from Keys and Values, skipping the key copy and sort,
+// and Merge merges two marked maps in one pass.  Both verify the order
+// in O(n) as they go and fall back to sorting if the mark is stale, so
+// a marked map whose keys were since changed still encodes correctly.
 type Map struct {
 	Keys   []string
 	Values []Value
+	Sorted bool
 }
 
 // Bool is a MAP v1 BOOLEAN value (v1.1).
@@ -48,12 +69,21 @@ type Bool bool
 // Integer is a MAP v1 INTEGER value (v1.1).  Signed 64-bit.
 type Integer int64
 
+// Null is the NULL extension value: present but empty, distinct from an
+// absent MAP entry and from an empty STRING or BYTES.  It is NOT part
+// of MAP v1.1 and other implementations cannot hash it, so it is
+// ERR_TYPE unless enabled: EncodeOptions.AllowNull to encode,
+// DecodeOptions.AllowNull to decode, and LenientOptions.NullAsNull to
+// read JSON null as Null.
+type Null struct{}
+
 func (String) mapValue()  {}
 func (Bytes) mapValue()   {}
 func (List) mapValue()    {}
 func (*Map) mapValue()    {}
 func (Bool) mapValue()    {}
 func (Integer) mapValue() {}
+func (Null) mapValue()    {}
 
 // MapEntry is a convenience type for building Map values.
 type MapEntry struct {
@@ -75,7 +105,70 @@ func NewMap(entries ...MapEntry) *Map {
 	return m
 }
 
+// FindDuplicateKeys returns each key that appears more than once in m,
+// in order of first appearance, or nil if the keys are unique.  Such a
+// map fails to encode with ERR_DUP_KEY; this reports it at build time.
+// Only m's own keys are checked, not nested maps.
+func (m *Map) FindDuplicateKeys() []string {
+	seen := make(map[string]int, len(m.Keys))
+	var dups []string
+	for _, k := range m.Keys {
+		seen[k]++
+		if seen[k] == 2 {
+			dups = append(dups, k)
+		}
+	}
+	return dups
+}
+
 // EmptyMap returns a Map with zero entries.
 func EmptyMap() *Map {
 	return &Map{}
 }
+
+// EmptyList returns a List with zero elements.  Its MID differs from
+// those of EmptyMap(), String("") and Bytes(nil): each empty value
+// keeps its own type tag.
+func EmptyList() List {
+	return List{}
+}
+
+// CompareKeys compares two MAP keys in canonical key order (§3.5):
+// unsigned bytewise over their UTF-8 encodings, shorter-is-less on a
+// common prefix.  It returns -1, 0 or +1.  This is the order the
+// encoder emits and the decoder enforces; tooling that sorts or merges
+// keys should use it rather than assume an ordering of its own.
+func CompareKeys(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+// AllKeys returns every MAP key used anywhere in v, at any depth and
+// inside LISTs, de-duplicated and sorted by CompareKeys, or nil if v
+// holds no MAP keys.  Keys are returned as is, valid or not.
+func AllKeys(v Value) []string {
+	set := map[string]bool{}
+	collectKeys(v, set)
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return CompareKeys(keys[i], keys[j]) < 0 })
+	return keys
+}
+
+func collectKeys(v Value, set map[string]bool) {
+	switch val := v.(type) {
+	case List:
+		for _, item := range val {
+			collectKeys(item, set)
+		}
+	case *Map:
+		for i, k := range val.Keys {
+			set[k] = true
+			collectKeys(val.Values[i], set)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package map1
+
+import "io"
+
+// CanonWriter forwards CANON_BYTES to an underlying writer while
+// enforcing MAX_CANON_BYTES as bytes accumulate.  The first write that
+// would take the running total past the limit is refused whole and
+// fails with ERR_LIMIT_SIZE; so does every later write.  An error from
+// the underlying writer is likewise sticky.
+//
+// On error the underlying writer has received a prefix of the output,
+// which the caller must discard.
+type CanonWriter struct {
+	w   io.Writer
+	n   int
+	err error
+}
+
+// NewCanonWriter returns a CanonWriter writing to w.
+func NewCanonWriter(w io.Writer) *CanonWriter {
+	return &CanonWriter{w: w}
+}
+
+// Write implements io.Writer.
+func (c *CanonWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.n+len(p) > MaxCanonBytes {
+		c.err = newErr(ErrLimitSize, "canon bytes exceed MAX_CANON_BYTES")
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += n
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// Written returns the number of bytes forwarded so far.
+func (c *CanonWriter) Written() int {
+	return c.n
+}
+
+// Err returns the sticky error, if any.
+func (c *CanonWriter) Err() error {
+	return c.err
+}
+
+// EncodeCanonBytes streams CANON_BYTES for v to w without holding the
+// whole encoding in memory: output is written through a CanonWriter
+// (w itself if it already is one) in chunks as it is produced, so an
+// oversized value fails with ERR_LIMIT_SIZE as soon as the limit is
+// crossed.  Wrap slow writers in a bufio.Writer.
+//
+// Other violations are reported as CanonBytesFull would report them.
+// Once the size limit is hit encoding stops (§6.2 safety short-circuit)
+// and ERR_LIMIT_SIZE is returned.  On any error w may already have
+// received part of the output.
+func EncodeCanonBytes(w io.Writer, v Value) error {
+	cw, ok := w.(*CanonWriter)
+	if !ok {
+		cw = NewCanonWriter(w)
+	}
+	buf := encBuf{sink: cw}
+	buf.Write(canonHdr)
+	if err := mcfEncodeTo(&buf, v, 0); err != nil {
+		if cw.err != nil {
+			return cw.err
+		}
+		w := &validator{}
+		w.walk(v, "", 0)
+		return reportedError(w.errs, err)
+	}
+	return buf.flush()
+}
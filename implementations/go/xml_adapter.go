@@ -0,0 +1,188 @@
+package map1
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// XML adapter.
+//
+// Maps an XML document onto the canonical model:
+//
+//   - The document is a MAP with one entry: root tag → root element.
+//   - An element with neither attributes nor child elements is its
+//     text content as a STRING, verbatim ("" if empty).
+//   - Any other element is a MAP.  Each child element appears under its
+//     tag; repeated sibling tags become a LIST in document order (so a
+//     tag seen once is a bare value, seen twice a LIST of two).
+//     Attributes go in a nested MAP under the reserved key "@attrs";
+//     text beside attributes but without child elements goes under
+//     "#text", verbatim.  Neither key can collide with an XML name.  A
+//     repeated attribute is ERR_DUP_KEY.
+//   - Whitespace-only text in an element with attributes or child
+//     elements is insignificant and dropped, so indentation never
+//     changes the MID.  Attribute order never matters (MAP keys are
+//     sorted).
+//   - Only elements that become MAPs (and the LISTs and "@attrs" MAPs
+//     they hold) count against MAX_DEPTH; a text-only element is a
+//     STRING and adds no depth.
+//   - Mixed content (non-whitespace text alongside child elements) is
+//     ERR_SCHEMA.
+//   - Namespaces are not supported: any prefixed or xmlns-declared name
+//     is ERR_SCHEMA.  DOCTYPE/DTD directives are ERR_SCHEMA.  Comments
+//     and processing instructions are ignored.
+//   - Malformed XML is ERR_CANON_MCF.
+
+const (
+	xmlAttrsKey = "@attrs"
+	xmlTextKey  = "#text"
+)
+
+// ValueFromXML converts an XML document to a canonical value.
+func ValueFromXML(raw []byte) (Value, error) {
+	if len(raw) > MaxCanonBytes {
+		return nil, newErr(ErrLimitSize, "input exceeds MAX_CANON_BYTES")
+	}
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	dec.Strict = true
+
+	var root Value
+	var rootTag string
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, newErr(ErrCanonMCF, "XML parse error: "+err.Error())
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil {
+				return nil, newErr(ErrCanonMCF, "multiple XML root elements")
+			}
+			v, err := xmlElement(dec, t, 2) // document MAP is depth 1
+			if err != nil {
+				return nil, err
+			}
+			root, rootTag = v, t.Name.Local
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) != 0 {
+				return nil, newErr(ErrCanonMCF, "text outside XML root element")
+			}
+		case xml.Directive:
+			return nil, newErr(ErrSchema, "XML directives (DOCTYPE) not supported")
+		}
+	}
+	if root == nil {
+		return nil, newErr(ErrCanonMCF, "no XML root element")
+	}
+	return NewMap(MapEntry{Key: rootTag, Value: root}), nil
+}
+
+// MIDFullXML computes the FULL MID of an XML document.
+func MIDFullXML(raw []byte) (string, error) {
+	v, err := ValueFromXML(raw)
+	if err != nil {
+		return "", err
+	}
+	return MIDFromValue(v)
+}
+
+func xmlCheckName(n xml.Name) error {
+	if n.Space != "" || n.Local == "xmlns" {
+		return newErr(ErrSchema, "XML namespaces not supported")
+	}
+	return nil
+}
+
+// xmlElement converts the element opened by start.  depth is the
+// container depth this element would occupy if it becomes a MAP; it is
+// only checked once the element is known to become one.
+func xmlElement(dec *xml.Decoder, start xml.StartElement, depth int) (Value, error) {
+	if err := xmlCheckName(start.Name); err != nil {
+		return nil, err
+	}
+
+	var attrs *Map
+	if len(start.Attr) > 0 {
+		// The element is a MAP holding the "@attrs" MAP one level down.
+		if depth+1 > MaxDepth {
+			return nil, newErr(ErrLimitDepth, "exceeds MAX_DEPTH")
+		}
+		attrs = &Map{}
+		for _, a := range start.Attr {
+			if err := xmlCheckName(a.Name); err != nil {
+				return nil, err
+			}
+			attrs.Keys = append(attrs.Keys, a.Name.Local)
+			attrs.Values = append(attrs.Values, String(a.Value))
+		}
+	}
+
+	var text strings.Builder
+	var childTags []string
+	children := map[string][]Value{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, newErr(ErrCanonMCF, "XML parse error: "+err.Error())
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			// A child element makes this element a MAP.
+			if depth > MaxDepth {
+				return nil, newErr(ErrLimitDepth, "exceeds MAX_DEPTH")
+			}
+			// A repeated tag becomes a LIST, one level deeper; the
+			// encoder checks that extra level.
+			v, err := xmlElement(dec, t, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			tag := t.Name.Local
+			if _, seen := children[tag]; !seen {
+				childTags = append(childTags, tag)
+			}
+			children[tag] = append(children[tag], v)
+		case xml.CharData:
+			text.Write(t)
+		case xml.Directive:
+			return nil, newErr(ErrSchema, "XML directives (DOCTYPE) not supported")
+		case xml.EndElement:
+			return xmlAssemble(text.String(), attrs, childTags, children)
+		}
+	}
+}
+
+func xmlAssemble(text string, attrs *Map, childTags []string, children map[string][]Value) (Value, error) {
+	hasText := strings.TrimSpace(text) != ""
+	if len(childTags) > 0 && hasText {
+		return nil, newErr(ErrSchema, "XML mixed content not supported")
+	}
+	if attrs == nil && len(childTags) == 0 {
+		return String(text), nil
+	}
+	m := &Map{}
+	if attrs != nil {
+		m.Keys = append(m.Keys, xmlAttrsKey)
+		m.Values = append(m.Values, attrs)
+		if len(childTags) == 0 && hasText {
+			m.Keys = append(m.Keys, xmlTextKey)
+			m.Values = append(m.Values, String(text))
+		}
+	}
+	for _, tag := range childTags {
+		vals := children[tag]
+		m.Keys = append(m.Keys, tag)
+		if len(vals) == 1 {
+			m.Values = append(m.Values, vals[0])
+		} else {
+			m.Values = append(m.Values, List(vals))
+		}
+	}
+	return m, nil
+}